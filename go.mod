@@ -4,19 +4,20 @@ go 1.15
 
 require (
 	github.com/genius v0.0.0-00010101000000-000000000000
-	github.com/google/go-cmp v0.5.5 // indirect
-	github.com/google/uuid v1.2.0 // indirect
 	github.com/observerward v0.0.0-00010101000000-000000000000
 	github.com/prometheus/client_golang v1.10.0
+	github.com/prometheus/client_model v0.2.0
 	github.com/prometheus/common v0.18.0
 	github.com/spf13/cobra v1.1.1
-	github.com/stretchr/testify v1.7.0 // indirect
 	k8s.io/api v0.20.0
 	k8s.io/apimachinery v0.20.0
 	k8s.io/client-go v0.20.0
 	k8s.io/component-base v0.20.0
+	k8s.io/component-helpers v0.20.0
 	k8s.io/klog/v2 v2.4.0
+	k8s.io/kube-scheduler v0.0.0
 	k8s.io/kubernetes v1.20.0
+	sigs.k8s.io/yaml v1.2.0
 )
 
 replace (
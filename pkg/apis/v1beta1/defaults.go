@@ -0,0 +1,190 @@
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"time"
+)
+
+const (
+	DefaultMetricsSource          = "prometheus"
+	DefaultRefreshInterval        = 5 * time.Second
+	DefaultLabelPrefix            = "genius/"
+	DefaultReservationGracePeriod = time.Minute
+	DefaultGPUResourceName        = "nvidia.com/gpu"
+	DefaultModelRegistryNamespace = "kube-system"
+	DefaultModelRegistryName      = "genius-gpu-models"
+	DefaultMaxStaleness           = time.Minute
+	DefaultStalePolicy            = StalePolicyUnschedulable
+	DefaultNonGPUPodPolicy        = NonGPUPodPolicyNone
+	DefaultSnapshotTTL            = 5 * time.Minute
+	DefaultRetryAttempts          = 3
+	DefaultRetryBackoff           = 500 * time.Millisecond
+	DefaultFailureThreshold       = 5
+	DefaultOpenDuration           = 30 * time.Second
+	DefaultGangTimeout            = time.Minute
+	DefaultStaticWeight           = 1
+	DefaultDynamicWeight          = 2
+
+	DefaultMemoryWeight             = 2
+	DefaultMultiprocessorWeight     = 2
+	DefaultSharedDecoderWeight      = 1
+	DefaultSharedEncoderWeight      = 1
+	DefaultBandwidthWeight          = 2
+	DefaultFreeMemoryWeight         = 2
+	DefaultPowerWeight              = 1
+	DefaultEncoderUtilizationWeight = 1
+	DefaultDecoderUtilizationWeight = 1
+
+	DefaultPodMaxScoreWeight = 10
+
+	DefaultMaxUncorrectableECCErrors = 0
+	DefaultMaxCorrectableECCErrors   = 100
+	DefaultDegradedTemperature       = 85
+	DefaultUnhealthyTemperature      = 95
+	DefaultDegradedPenalty           = 50
+)
+
+// DefaultIgnoredXIDs are the XID errors caused by the applications rather
+// than the GPUs, such as the illegal memory accesses and the out-of-memory.
+var DefaultIgnoredXIDs = []uint64{13, 31, 43, 45, 63}
+
+// SetDefaultsGeniusArgs sets the default values of the unset fields.
+func SetDefaultsGeniusArgs(args *GeniusArgs) {
+	if args.MetricsSource == "" {
+		args.MetricsSource = DefaultMetricsSource
+	}
+	if args.RefreshInterval == nil {
+		args.RefreshInterval = &metav1.Duration{Duration: DefaultRefreshInterval}
+	}
+	if args.ReservationGracePeriod == nil {
+		args.ReservationGracePeriod = &metav1.Duration{Duration: DefaultReservationGracePeriod}
+	}
+	if args.GPUResourceName == "" {
+		args.GPUResourceName = DefaultGPUResourceName
+	}
+	if args.NonGPUPodPolicy == "" {
+		args.NonGPUPodPolicy = DefaultNonGPUPodPolicy
+	}
+	if args.LabelPrefix == nil {
+		prefix := DefaultLabelPrefix
+		args.LabelPrefix = &prefix
+	}
+
+	if args.ModelRegistry.Namespace == "" {
+		args.ModelRegistry.Namespace = DefaultModelRegistryNamespace
+	}
+	if args.ModelRegistry.Name == "" {
+		args.ModelRegistry.Name = DefaultModelRegistryName
+	}
+
+	setDefaultsScoreWeights(&args.ScoreWeights)
+	if args.PodScoreWeights.Enabled == nil {
+		t := true
+		args.PodScoreWeights.Enabled = &t
+	}
+	if args.PodScoreWeights.MaxWeight == nil {
+		w := int64(DefaultPodMaxScoreWeight)
+		args.PodScoreWeights.MaxWeight = &w
+	}
+
+	setDefaultsHealthArgs(&args.Health)
+	if args.Staleness.MaxAge == nil {
+		args.Staleness.MaxAge = &metav1.Duration{Duration: DefaultMaxStaleness}
+	}
+	if args.Staleness.Policy == "" {
+		args.Staleness.Policy = DefaultStalePolicy
+	}
+	setDefaultsResilienceArgs(&args.Resilience)
+	if args.Gang.Timeout == nil {
+		args.Gang.Timeout = &metav1.Duration{Duration: DefaultGangTimeout}
+	}
+
+	for _, enabled := range []**bool{
+		&args.Filters.GPUNumber,
+		&args.Filters.MemoryEach,
+		&args.Filters.MemoryTotal,
+		&args.Filters.Model,
+		&args.Filters.Attributes,
+		&args.Filters.Capability,
+		&args.Filters.CUDAVersion,
+	} {
+		if *enabled == nil {
+			t := true
+			*enabled = &t
+		}
+	}
+}
+
+func setDefaultsHealthArgs(args *HealthArgs) {
+	if args.Enabled == nil {
+		t := true
+		args.Enabled = &t
+	}
+	if args.IgnoredXIDs == nil {
+		args.IgnoredXIDs = append([]uint64(nil), DefaultIgnoredXIDs...)
+	}
+	if args.MaxUncorrectableECCErrors == nil {
+		n := uint64(DefaultMaxUncorrectableECCErrors)
+		args.MaxUncorrectableECCErrors = &n
+	}
+	if args.MaxCorrectableECCErrors == nil {
+		n := uint64(DefaultMaxCorrectableECCErrors)
+		args.MaxCorrectableECCErrors = &n
+	}
+	if args.DegradedTemperature == nil {
+		t := uint(DefaultDegradedTemperature)
+		args.DegradedTemperature = &t
+	}
+	if args.UnhealthyTemperature == nil {
+		t := uint(DefaultUnhealthyTemperature)
+		args.UnhealthyTemperature = &t
+	}
+	if args.DegradedPenalty == nil {
+		p := int64(DefaultDegradedPenalty)
+		args.DegradedPenalty = &p
+	}
+}
+
+func setDefaultsResilienceArgs(args *ResilienceArgs) {
+	if args.SnapshotTTL == nil {
+		args.SnapshotTTL = &metav1.Duration{Duration: DefaultSnapshotTTL}
+	}
+	if args.RetryAttempts == nil {
+		n := DefaultRetryAttempts
+		args.RetryAttempts = &n
+	}
+	if args.RetryBackoff == nil {
+		args.RetryBackoff = &metav1.Duration{Duration: DefaultRetryBackoff}
+	}
+	if args.FailureThreshold == nil {
+		n := DefaultFailureThreshold
+		args.FailureThreshold = &n
+	}
+	if args.OpenDuration == nil {
+		args.OpenDuration = &metav1.Duration{Duration: DefaultOpenDuration}
+	}
+}
+
+func setDefaultsScoreWeights(weights *ScoreWeights) {
+	for _, weight := range []struct {
+		value        **int64
+		defaultValue int64
+	}{
+		{&weights.Static, DefaultStaticWeight},
+		{&weights.Dynamic, DefaultDynamicWeight},
+		{&weights.Memory, DefaultMemoryWeight},
+		{&weights.Multiprocessor, DefaultMultiprocessorWeight},
+		{&weights.SharedDecoder, DefaultSharedDecoderWeight},
+		{&weights.SharedEncoder, DefaultSharedEncoderWeight},
+		{&weights.Bandwidth, DefaultBandwidthWeight},
+		{&weights.FreeMemory, DefaultFreeMemoryWeight},
+		{&weights.Power, DefaultPowerWeight},
+		{&weights.EncoderUtilization, DefaultEncoderUtilizationWeight},
+		{&weights.DecoderUtilization, DefaultDecoderUtilizationWeight},
+	} {
+		if *weight.value == nil {
+			w := weight.defaultValue
+			*weight.value = &w
+		}
+	}
+}
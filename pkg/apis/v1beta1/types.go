@@ -0,0 +1,282 @@
+// Package v1beta1 contains the versioned arguments of the Genius plugin,
+// which are set in the pluginConfig of the KubeSchedulerConfiguration.
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	GroupName = "genius.io"
+	Version   = "v1beta1"
+	Kind      = "GeniusArgs"
+)
+
+// GeniusArgs holds the arguments of the Genius plugin.
+// The apiVersion and kind can be omitted, but if they are set they must be
+// "genius.io/v1beta1" and "GeniusArgs".
+type GeniusArgs struct {
+	metav1.TypeMeta `json:",inline"`
+
+	// MetricsSource is the name of the backend providing the GPU metrics,
+	// one of "prometheus", "observerward" and "dcgm". Defaults to "prometheus".
+	MetricsSource string `json:"metricsSource,omitempty"`
+	// RefreshInterval is the interval at which the GPU metrics are refreshed
+	// from the metrics source. Defaults to 5s.
+	RefreshInterval *metav1.Duration `json:"refreshInterval,omitempty"`
+	// ReservationGracePeriod is how long the GPUs reserved by a pod are
+	// accounted for after the reservation, regardless of the GPU metrics. The
+	// reservation is released once metrics collected after the grace period
+	// are available, since they then include the usage of the pod.
+	// Defaults to 1m.
+	ReservationGracePeriod *metav1.Duration `json:"reservationGracePeriod,omitempty"`
+	// LabelPrefix is the prefix of the pod labels Genius reads the GPU
+	// requirements from, such as "genius/gpu-number". Defaults to "genius/".
+	LabelPrefix *string `json:"labelPrefix,omitempty"`
+	// GPUResourceName is the extended resource of the GPUs advertised by the
+	// device plugin. The GPUs requested through it take precedence over the
+	// "gpu-number" label, and the GPUs of a node already requested through it
+	// are not counted as available. Defaults to "nvidia.com/gpu".
+	GPUResourceName string `json:"gpuResourceName,omitempty"`
+	// Prometheus specifies how the Prometheus server is found and connected
+	// when the metrics source is "prometheus".
+	Prometheus PrometheusArgs `json:"prometheus,omitempty"`
+	// Exporter specifies how the exporter pods are discovered when the GPU
+	// metrics are scraped from the exporters directly. Unset fields default
+	// to the values matching the selected exporter.
+	Exporter ExporterArgs `json:"exporter,omitempty"`
+	// ScoreWeights weights the static and dynamic scores of a node.
+	ScoreWeights ScoreWeights `json:"scoreWeights,omitempty"`
+	// PodScoreWeights bounds the score weights pods set through their annotations.
+	PodScoreWeights PodScoreWeightsArgs `json:"podScoreWeights,omitempty"`
+	// Filters enables or disables each GPU filter.
+	Filters FilterArgs `json:"filters,omitempty"`
+	// ModelRegistry specifies the ConfigMap mapping the GPU model aliases
+	// used by pods to the model names reported by the metrics source.
+	ModelRegistry ModelRegistryArgs `json:"modelRegistry,omitempty"`
+	// Health specifies how the health of the GPUs is told from their XID
+	// errors, ECC errors and temperature.
+	Health HealthArgs `json:"health,omitempty"`
+	// Staleness specifies how the nodes whose GPU metrics are out of date
+	// are scheduled.
+	Staleness StalenessArgs `json:"staleness,omitempty"`
+	// NonGPUPodPolicy is how the pods requesting no GPUs are placed, which are
+	// neither filtered nor scored by the GPU metrics. It is one of "none",
+	// which leaves them to the other plugins, and "avoidGPUNodes", which
+	// scores the nodes without GPUs higher. Defaults to "none".
+	NonGPUPodPolicy string `json:"nonGPUPodPolicy,omitempty"`
+	// Resilience specifies how the failures of the metrics source are retried
+	// and tolerated.
+	Resilience ResilienceArgs `json:"resilience,omitempty"`
+	// Gang specifies how the pods of a pod group are scheduled all together.
+	Gang GangArgs `json:"gang,omitempty"`
+}
+
+// GangArgs specifies the gang scheduling of the pod groups. The pods of a
+// group wait in the permit phase until the min-available of them have
+// reserved their nodes, and are bound together.
+type GangArgs struct {
+	// Timeout is how long a pod waits for the rest of its group, after which
+	// the whole group is rejected and retried. Defaults to 1m.
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+}
+
+// ResilienceArgs specifies how Genius keeps scheduling while the metrics
+// source is unavailable. The last known good snapshot is used within
+// SnapshotTTL. Past that, the GPUs are counted by the allocatable extended
+// resource of the nodes, the pods requiring more than a number of GPUs are
+// unschedulable, and the nodes are not scored by the GPU metrics.
+type ResilienceArgs struct {
+	// SnapshotTTL is how long the last known good snapshot is used after it
+	// was collected. Defaults to 5m.
+	SnapshotTTL *metav1.Duration `json:"snapshotTTL,omitempty"`
+	// RetryAttempts is the number of attempts of each refresh. Defaults to 3.
+	RetryAttempts *int `json:"retryAttempts,omitempty"`
+	// RetryBackoff is the delay before the first retry, which is doubled
+	// before each of the following ones. Defaults to 500ms.
+	RetryBackoff *metav1.Duration `json:"retryBackoff,omitempty"`
+	// FailureThreshold is the number of consecutive failed attempts which
+	// opens the circuit breaker of the metrics source. Defaults to 5.
+	FailureThreshold *int `json:"failureThreshold,omitempty"`
+	// OpenDuration is how long the circuit breaker stays open before a trial
+	// attempt is made. Defaults to 30s.
+	OpenDuration *metav1.Duration `json:"openDuration,omitempty"`
+}
+
+// The policies of the pods requesting no GPUs.
+const (
+	NonGPUPodPolicyNone          = "none"
+	NonGPUPodPolicyAvoidGPUNodes = "avoidGPUNodes"
+)
+
+// The policies of the nodes with stale GPU metrics.
+const (
+	// StalePolicyUnschedulable filters out the stale nodes.
+	StalePolicyUnschedulable = "unschedulable"
+	// StalePolicyStaticOnly keeps the stale nodes, but scores them by the
+	// static GPU attributes alone, since the dynamic metrics are out of date.
+	StalePolicyStaticOnly = "staticOnly"
+	// StalePolicyIgnore schedules the stale nodes as usual.
+	StalePolicyIgnore = "ignore"
+)
+
+// StalenessArgs specifies how stale the GPU metrics of a node can be. The age
+// of the metrics is measured from the latest sample of the node, which is
+// older than the query if its exporter has stopped reporting, since
+// Prometheus returns the last sample within the lookback window.
+type StalenessArgs struct {
+	// MaxAge is the age from which the GPU metrics of a node are stale.
+	// Defaults to 1m.
+	MaxAge *metav1.Duration `json:"maxAge,omitempty"`
+	// Policy is how the stale nodes are scheduled, one of "unschedulable",
+	// "staticOnly" and "ignore". Defaults to "unschedulable".
+	Policy string `json:"policy,omitempty"`
+}
+
+// HealthArgs holds the thresholds of the GPU health. Unhealthy GPUs are
+// excluded from the filters, and the nodes with degraded GPUs are scored lower.
+// The XID and ECC errors are only reported by the "dcgm" metrics source.
+type HealthArgs struct {
+	// Enabled enables the health check. Defaults to true.
+	Enabled *bool `json:"enabled,omitempty"`
+	// IgnoredXIDs are the XID errors which do not make a GPU unhealthy, such
+	// as the ones caused by the applications. Defaults to [13, 31, 43, 45, 63].
+	IgnoredXIDs []uint64 `json:"ignoredXIDs,omitempty"`
+	// MaxUncorrectableECCErrors is the number of the volatile uncorrectable
+	// ECC errors above which a GPU is unhealthy. Defaults to 0.
+	MaxUncorrectableECCErrors *uint64 `json:"maxUncorrectableECCErrors,omitempty"`
+	// MaxCorrectableECCErrors is the number of the volatile correctable ECC
+	// errors above which a GPU is degraded. Defaults to 100.
+	MaxCorrectableECCErrors *uint64 `json:"maxCorrectableECCErrors,omitempty"`
+	// DegradedTemperature is the temperature in °C from which a GPU is
+	// degraded. GPUs being thermally throttled are degraded as well. Defaults
+	// to 85, and 0 disables the check.
+	DegradedTemperature *uint `json:"degradedTemperature,omitempty"`
+	// UnhealthyTemperature is the temperature in °C from which a GPU is
+	// unhealthy. Defaults to 95, and 0 disables the check.
+	UnhealthyTemperature *uint `json:"unhealthyTemperature,omitempty"`
+	// DegradedPenalty is the percentage by which the score of a node is
+	// lowered if all of its GPUs are degraded, in proportion to the degraded
+	// GPUs otherwise. Defaults to 50.
+	DegradedPenalty *int64 `json:"degradedPenalty,omitempty"`
+}
+
+// ModelRegistryArgs specifies the ConfigMap of the GPU model aliases. The
+// ConfigMap is watched, so the aliases can be changed without restarting the
+// scheduler, and a missing ConfigMap results in no aliases.
+type ModelRegistryArgs struct {
+	// Namespace of the ConfigMap. Defaults to "kube-system".
+	Namespace string `json:"namespace,omitempty"`
+	// Name of the ConfigMap. Defaults to "genius-gpu-models".
+	Name string `json:"name,omitempty"`
+}
+
+// PrometheusArgs holds the arguments of the metrics source backed by Prometheus.
+// The explicit address takes precedence over the service discovery. If the
+// service cannot be found, the address of monitor.DefaultPromAddress is used.
+type PrometheusArgs struct {
+	// Address of the Prometheus HTTP API, such as "http://prometheus.prometheus.svc:9090".
+	Address string `json:"address,omitempty"`
+	// Service finds the Prometheus service through the Kubernetes API.
+	// Unset fields default to monitor.DefaultPrometheusServiceConfig.
+	Service PrometheusServiceArgs `json:"service,omitempty"`
+	// TLS configures the TLS connection to Prometheus.
+	TLS *TLSArgs `json:"tls,omitempty"`
+	// BearerTokenFile is the file the bearer token is read from. The file is
+	// read on every request, so the token can be rotated.
+	BearerTokenFile string `json:"bearerTokenFile,omitempty"`
+	// BasicAuth sets the basic authentication credentials.
+	BasicAuth *BasicAuthArgs `json:"basicAuth,omitempty"`
+}
+
+// PrometheusServiceArgs specifies the Prometheus service, either by name or
+// by label selector.
+type PrometheusServiceArgs struct {
+	Namespace     *string `json:"namespace,omitempty"`
+	Name          string  `json:"name,omitempty"`
+	LabelSelector string  `json:"labelSelector,omitempty"`
+	PortName      string  `json:"portName,omitempty"`
+	Scheme        string  `json:"scheme,omitempty"`
+}
+
+// TLSArgs configures the TLS connection to Prometheus.
+type TLSArgs struct {
+	CAFile             string `json:"caFile,omitempty"`
+	CertFile           string `json:"certFile,omitempty"`
+	KeyFile            string `json:"keyFile,omitempty"`
+	ServerName         string `json:"serverName,omitempty"`
+	InsecureSkipVerify bool   `json:"insecureSkipVerify,omitempty"`
+}
+
+// BasicAuthArgs sets the basic authentication credentials. The password is
+// read from a file, so that it can be mounted from a secret.
+type BasicAuthArgs struct {
+	Username     string `json:"username"`
+	PasswordFile string `json:"passwordFile"`
+}
+
+// ExporterArgs holds the arguments of the metrics sources scraping the GPU exporters.
+type ExporterArgs struct {
+	Namespace     *string `json:"namespace,omitempty"`
+	LabelSelector string  `json:"labelSelector,omitempty"`
+	Port          int     `json:"port,omitempty"`
+	Path          string  `json:"path,omitempty"`
+}
+
+// ScoreWeights weights the static and dynamic scores of a node, and each of
+// the GPU attributes and metrics the scores are computed from. Every weight
+// must be in the range of [0, MaxScoreWeight].
+// When several scheduler profiles enable Genius, each profile can set its
+// own weights in its pluginConfig.
+type ScoreWeights struct {
+	// Static weights the score of the intrinsic GPU attributes, such as memory
+	// size and multiprocessor count. Defaults to 1.
+	Static *int64 `json:"static,omitempty"`
+	// Dynamic weights the score of the GPU metrics changing over time, such as
+	// free memory and power usage. Defaults to 2.
+	Dynamic *int64 `json:"dynamic,omitempty"`
+
+	// The weights of the static attributes. Memory, Multiprocessor and
+	// Bandwidth default to 2, SharedDecoder and SharedEncoder default to 1.
+	Memory         *int64 `json:"memory,omitempty"`
+	Multiprocessor *int64 `json:"multiprocessor,omitempty"`
+	SharedDecoder  *int64 `json:"sharedDecoder,omitempty"`
+	SharedEncoder  *int64 `json:"sharedEncoder,omitempty"`
+	Bandwidth      *int64 `json:"bandwidth,omitempty"`
+
+	// The weights of the dynamic metrics. FreeMemory defaults to 2, and the
+	// others default to 1.
+	FreeMemory         *int64 `json:"freeMemory,omitempty"`
+	Power              *int64 `json:"power,omitempty"`
+	EncoderUtilization *int64 `json:"encoderUtilization,omitempty"`
+	DecoderUtilization *int64 `json:"decoderUtilization,omitempty"`
+}
+
+// PodScoreWeightsArgs bounds the score weights a pod sets through the
+// "score-weights" and "workload-profile" annotations.
+type PodScoreWeightsArgs struct {
+	// Enabled allows pods to override the score weights. Pods setting the
+	// annotations are unschedulable if it is disabled. Defaults to true.
+	Enabled *bool `json:"enabled,omitempty"`
+	// MaxWeight is the maximum of each weight a pod can set, and it caps the
+	// weights preset by the workload profiles. Defaults to 10.
+	MaxWeight *int64 `json:"maxWeight,omitempty"`
+}
+
+// FilterArgs enables or disables each GPU filter. All the filters are
+// enabled by default.
+type FilterArgs struct {
+	GPUNumber   *bool `json:"gpuNumber,omitempty"`
+	MemoryEach  *bool `json:"memoryEach,omitempty"`
+	MemoryTotal *bool `json:"memoryTotal,omitempty"`
+	Model       *bool `json:"model,omitempty"`
+	// Attributes checks the minimum multiprocessors and memory bandwidth of
+	// the GPUs required in the "gpu-requirements" annotation.
+	Attributes *bool `json:"attributes,omitempty"`
+	// Capability checks the minimum compute capability and the architectures
+	// of the GPUs, which are read from the exporter labels or the model registry.
+	Capability *bool `json:"capability,omitempty"`
+	// CUDAVersion checks that the driver of the node supports the CUDA
+	// version required by the pod.
+	CUDAVersion *bool `json:"cudaVersion,omitempty"`
+}
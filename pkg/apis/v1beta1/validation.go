@@ -0,0 +1,212 @@
+package v1beta1
+
+import (
+	"fmt"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	frameworkruntime "k8s.io/kubernetes/pkg/scheduler/framework/runtime"
+	"strings"
+)
+
+const (
+	// MaxScoreWeight is the maximum of each score weight.
+	MaxScoreWeight = 100
+)
+
+var (
+	validMetricsSources = sets.NewString("prometheus", "observerward", "dcgm")
+	validSchemes        = sets.NewString("http", "https")
+	validStalePolicies  = sets.NewString(StalePolicyUnschedulable, StalePolicyStaticOnly, StalePolicyIgnore)
+	validNonGPUPolicies = sets.NewString(NonGPUPodPolicyNone, NonGPUPodPolicyAvoidGPUNodes)
+)
+
+// DecodeGeniusArgs decodes the plugin args set in the scheduler configuration,
+// then defaults and validates them. A nil obj results in the default args.
+func DecodeGeniusArgs(obj runtime.Object) (*GeniusArgs, error) {
+	args := &GeniusArgs{}
+	if err := frameworkruntime.DecodeInto(obj, args); err != nil {
+		return nil, fmt.Errorf("decoding %v error: %v", Kind, err)
+	}
+	SetDefaultsGeniusArgs(args)
+	if err := ValidateGeniusArgs(args).ToAggregate(); err != nil {
+		return nil, fmt.Errorf("invalid %v: %v", Kind, err)
+	}
+	return args, nil
+}
+
+// ValidateGeniusArgs validates the defaulted args.
+func ValidateGeniusArgs(args *GeniusArgs) field.ErrorList {
+	var allErrs field.ErrorList
+
+	if args.APIVersion != "" && args.APIVersion != GroupName+"/"+Version {
+		allErrs = append(allErrs, field.NotSupported(field.NewPath("apiVersion"), args.APIVersion, []string{GroupName + "/" + Version}))
+	}
+	if args.Kind != "" && args.Kind != Kind {
+		allErrs = append(allErrs, field.NotSupported(field.NewPath("kind"), args.Kind, []string{Kind}))
+	}
+
+	if !validMetricsSources.Has(args.MetricsSource) {
+		allErrs = append(allErrs, field.NotSupported(field.NewPath("metricsSource"), args.MetricsSource, validMetricsSources.List()))
+	}
+	if args.RefreshInterval.Duration <= 0 {
+		allErrs = append(allErrs, field.Invalid(field.NewPath("refreshInterval"), args.RefreshInterval.Duration.String(), "must be positive"))
+	}
+	if args.ReservationGracePeriod.Duration < 0 {
+		allErrs = append(allErrs, field.Invalid(field.NewPath("reservationGracePeriod"), args.ReservationGracePeriod.Duration.String(), "must not be negative"))
+	}
+	for _, msg := range validation.IsQualifiedName(*args.LabelPrefix + "gpu-number") {
+		allErrs = append(allErrs, field.Invalid(field.NewPath("labelPrefix"), *args.LabelPrefix, msg))
+	}
+
+	for _, msg := range validation.IsQualifiedName(args.GPUResourceName) {
+		allErrs = append(allErrs, field.Invalid(field.NewPath("gpuResourceName"), args.GPUResourceName, msg))
+	}
+	if !strings.Contains(args.GPUResourceName, "/") {
+		allErrs = append(allErrs, field.Invalid(field.NewPath("gpuResourceName"), args.GPUResourceName, "must be a domain-prefixed extended resource name"))
+	}
+
+	for _, msg := range validation.IsDNS1123Label(args.ModelRegistry.Namespace) {
+		allErrs = append(allErrs, field.Invalid(field.NewPath("modelRegistry", "namespace"), args.ModelRegistry.Namespace, msg))
+	}
+	for _, msg := range validation.IsDNS1123Subdomain(args.ModelRegistry.Name) {
+		allErrs = append(allErrs, field.Invalid(field.NewPath("modelRegistry", "name"), args.ModelRegistry.Name, msg))
+	}
+
+	if !validNonGPUPolicies.Has(args.NonGPUPodPolicy) {
+		allErrs = append(allErrs, field.NotSupported(field.NewPath("nonGPUPodPolicy"), args.NonGPUPodPolicy, validNonGPUPolicies.List()))
+	}
+	if args.Staleness.MaxAge.Duration <= 0 {
+		allErrs = append(allErrs, field.Invalid(field.NewPath("staleness", "maxAge"), args.Staleness.MaxAge.Duration.String(), "must be positive"))
+	}
+	if !validStalePolicies.Has(args.Staleness.Policy) {
+		allErrs = append(allErrs, field.NotSupported(field.NewPath("staleness", "policy"), args.Staleness.Policy, validStalePolicies.List()))
+	}
+
+	if args.Gang.Timeout.Duration <= 0 {
+		allErrs = append(allErrs, field.Invalid(field.NewPath("gang", "timeout"), args.Gang.Timeout.Duration.String(), "must be positive"))
+	}
+	allErrs = append(allErrs, validateResilienceArgs(&args.Resilience, field.NewPath("resilience"))...)
+	allErrs = append(allErrs, validateHealthArgs(&args.Health, field.NewPath("health"))...)
+	allErrs = append(allErrs, validatePrometheusArgs(&args.Prometheus, field.NewPath("prometheus"))...)
+	allErrs = append(allErrs, validateExporterArgs(&args.Exporter, field.NewPath("exporter"))...)
+	allErrs = append(allErrs, validateScoreWeights(&args.ScoreWeights, field.NewPath("scoreWeights"))...)
+	if w := *args.PodScoreWeights.MaxWeight; w < 0 || w > MaxScoreWeight {
+		allErrs = append(allErrs, field.Invalid(field.NewPath("podScoreWeights", "maxWeight"), w,
+			fmt.Sprintf("must be in the range of [0, %v]", MaxScoreWeight)))
+	}
+	return allErrs
+}
+
+func validateResilienceArgs(args *ResilienceArgs, path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	if args.SnapshotTTL.Duration <= 0 {
+		allErrs = append(allErrs, field.Invalid(path.Child("snapshotTTL"), args.SnapshotTTL.Duration.String(), "must be positive"))
+	}
+	if *args.RetryAttempts < 1 {
+		allErrs = append(allErrs, field.Invalid(path.Child("retryAttempts"), *args.RetryAttempts, "must be at least 1"))
+	}
+	if args.RetryBackoff.Duration < 0 {
+		allErrs = append(allErrs, field.Invalid(path.Child("retryBackoff"), args.RetryBackoff.Duration.String(), "must not be negative"))
+	}
+	if *args.FailureThreshold < 1 {
+		allErrs = append(allErrs, field.Invalid(path.Child("failureThreshold"), *args.FailureThreshold, "must be at least 1"))
+	}
+	if args.OpenDuration.Duration <= 0 {
+		allErrs = append(allErrs, field.Invalid(path.Child("openDuration"), args.OpenDuration.Duration.String(), "must be positive"))
+	}
+	return allErrs
+}
+
+func validateHealthArgs(args *HealthArgs, path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	if degraded, unhealthy := *args.DegradedTemperature, *args.UnhealthyTemperature; degraded != 0 && unhealthy != 0 && unhealthy < degraded {
+		allErrs = append(allErrs, field.Invalid(path.Child("unhealthyTemperature"), unhealthy,
+			fmt.Sprintf("must not be lower than degradedTemperature %v", degraded)))
+	}
+	if p := *args.DegradedPenalty; p < 0 || p > 100 {
+		allErrs = append(allErrs, field.Invalid(path.Child("degradedPenalty"), p, "must be in the range of [0, 100]"))
+	}
+	return allErrs
+}
+
+func validatePrometheusArgs(args *PrometheusArgs, path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	servicePath := path.Child("service")
+	if args.Service.Name != "" && (args.Service.Namespace == nil || *args.Service.Namespace == "") {
+		allErrs = append(allErrs, field.Required(servicePath.Child("namespace"), "must be set along with the service name"))
+	}
+	allErrs = append(allErrs, validateLabelSelector(args.Service.LabelSelector, servicePath.Child("labelSelector"))...)
+	if args.Service.Scheme != "" && !validSchemes.Has(args.Service.Scheme) {
+		allErrs = append(allErrs, field.NotSupported(servicePath.Child("scheme"), args.Service.Scheme, validSchemes.List()))
+	}
+
+	if args.TLS != nil && (args.TLS.CertFile == "") != (args.TLS.KeyFile == "") {
+		allErrs = append(allErrs, field.Invalid(path.Child("tls"), "", "certFile and keyFile must be set together"))
+	}
+	if args.BasicAuth != nil {
+		if args.BasicAuth.Username == "" {
+			allErrs = append(allErrs, field.Required(path.Child("basicAuth", "username"), ""))
+		}
+		if args.BasicAuth.PasswordFile == "" {
+			allErrs = append(allErrs, field.Required(path.Child("basicAuth", "passwordFile"), ""))
+		}
+		if args.BearerTokenFile != "" {
+			allErrs = append(allErrs, field.Forbidden(path.Child("bearerTokenFile"), "cannot be set along with basicAuth"))
+		}
+	}
+	return allErrs
+}
+
+func validateExporterArgs(args *ExporterArgs, path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	allErrs = append(allErrs, validateLabelSelector(args.LabelSelector, path.Child("labelSelector"))...)
+	if args.Port != 0 {
+		for _, msg := range validation.IsValidPortNum(args.Port) {
+			allErrs = append(allErrs, field.Invalid(path.Child("port"), args.Port, msg))
+		}
+	}
+	return allErrs
+}
+
+func validateScoreWeights(weights *ScoreWeights, path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	for _, weight := range []struct {
+		name  string
+		value int64
+	}{
+		{"static", *weights.Static},
+		{"dynamic", *weights.Dynamic},
+		{"memory", *weights.Memory},
+		{"multiprocessor", *weights.Multiprocessor},
+		{"sharedDecoder", *weights.SharedDecoder},
+		{"sharedEncoder", *weights.SharedEncoder},
+		{"bandwidth", *weights.Bandwidth},
+		{"freeMemory", *weights.FreeMemory},
+		{"power", *weights.Power},
+		{"encoderUtilization", *weights.EncoderUtilization},
+		{"decoderUtilization", *weights.DecoderUtilization},
+	} {
+		if weight.value < 0 || weight.value > MaxScoreWeight {
+			allErrs = append(allErrs, field.Invalid(path.Child(weight.name), weight.value,
+				fmt.Sprintf("must be in the range of [0, %v]", MaxScoreWeight)))
+		}
+	}
+
+	staticTotal := *weights.Memory + *weights.Multiprocessor + *weights.SharedDecoder + *weights.SharedEncoder + *weights.Bandwidth
+	dynamicTotal := *weights.FreeMemory + *weights.Power + *weights.EncoderUtilization + *weights.DecoderUtilization
+	if *weights.Static*staticTotal == 0 && *weights.Dynamic*dynamicTotal == 0 {
+		allErrs = append(allErrs, field.Invalid(path, "", "at least one of the static and dynamic scores must have a positive weight"))
+	}
+	return allErrs
+}
+
+func validateLabelSelector(selector string, path *field.Path) field.ErrorList {
+	if _, err := labels.Parse(selector); err != nil {
+		return field.ErrorList{field.Invalid(path, selector, err.Error())}
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package v1beta1
+
+import (
+	"k8s.io/apimachinery/pkg/runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDecodeGeniusArgsDefaults(t *testing.T) {
+	for _, obj := range []runtime.Object{nil, &runtime.Unknown{Raw: []byte(`{}`)}} {
+		args, err := DecodeGeniusArgs(obj)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if args.MetricsSource != DefaultMetricsSource || args.RefreshInterval.Duration != DefaultRefreshInterval ||
+			*args.LabelPrefix != DefaultLabelPrefix {
+			t.Errorf("unexpected defaults: %+v", args)
+		}
+		if *args.ScoreWeights.Static != DefaultStaticWeight || *args.ScoreWeights.Dynamic != DefaultDynamicWeight ||
+			*args.ScoreWeights.Memory != DefaultMemoryWeight || *args.ScoreWeights.Power != DefaultPowerWeight {
+			t.Errorf("unexpected default weights: %+v", args.ScoreWeights)
+		}
+		if !*args.Filters.GPUNumber || !*args.Filters.MemoryEach || !*args.Filters.MemoryTotal || !*args.Filters.Model {
+			t.Errorf("expected all the filters to be enabled by default: %+v", args.Filters)
+		}
+		if !*args.Health.Enabled || len(args.Health.IgnoredXIDs) != len(DefaultIgnoredXIDs) ||
+			*args.Health.UnhealthyTemperature != DefaultUnhealthyTemperature || *args.Health.DegradedPenalty != DefaultDegradedPenalty {
+			t.Errorf("unexpected default health args: %+v", args.Health)
+		}
+		if args.Staleness.MaxAge.Duration != DefaultMaxStaleness || args.Staleness.Policy != StalePolicyUnschedulable {
+			t.Errorf("unexpected default staleness args: %+v", args.Staleness)
+		}
+	}
+}
+
+func TestDecodeGeniusArgs(t *testing.T) {
+	raw := `
+apiVersion: genius.io/v1beta1
+kind: GeniusArgs
+metricsSource: dcgm
+refreshInterval: 10s
+labelPrefix: gpu.example.com/
+scoreWeights:
+  static: 0
+filters:
+  model: false
+`
+	args, err := DecodeGeniusArgs(&runtime.Unknown{Raw: []byte(raw), ContentType: runtime.ContentTypeYAML})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if args.MetricsSource != "dcgm" || args.RefreshInterval.Duration != 10*time.Second || *args.LabelPrefix != "gpu.example.com/" {
+		t.Errorf("unexpected args: %+v", args)
+	}
+	if *args.ScoreWeights.Static != 0 || *args.ScoreWeights.Dynamic != DefaultDynamicWeight {
+		t.Errorf("unexpected weights: %v, %v", *args.ScoreWeights.Static, *args.ScoreWeights.Dynamic)
+	}
+	if *args.Filters.Model || !*args.Filters.GPUNumber {
+		t.Errorf("unexpected filters: %+v", args.Filters)
+	}
+}
+
+func TestDecodeGeniusArgsInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		err  string
+	}{
+		{"kind", `{"kind": "NodeResourcesFitArgs"}`, "kind"},
+		{"metrics source", `{"metricsSource": "influxdb"}`, "metricsSource"},
+		{"refresh interval", `{"refreshInterval": "-1s"}`, "refreshInterval"},
+		{"label prefix", `{"labelPrefix": "genius//"}`, "labelPrefix"},
+		{"service namespace", `{"prometheus": {"service": {"name": "prometheus-k8s"}}}`, "prometheus.service.namespace"},
+		{"tls", `{"prometheus": {"tls": {"certFile": "/etc/genius/tls.crt"}}}`, "prometheus.tls"},
+		{"basic auth", `{"prometheus": {"basicAuth": {"username": "genius"}}}`, "prometheus.basicAuth.passwordFile"},
+		{"exporter port", `{"exporter": {"port": 70000}}`, "exporter.port"},
+		{"negative weight", `{"scoreWeights": {"dynamic": -1}}`, "scoreWeights.dynamic"},
+		{"weight out of range", `{"scoreWeights": {"bandwidth": 101}}`, "scoreWeights.bandwidth"},
+		{"gpu resource without domain", `{"gpuResourceName": "gpu"}`, "gpuResourceName"},
+		{"negative grace period", `{"reservationGracePeriod": "-1s"}`, "reservationGracePeriod"},
+		{"pod max weight out of range", `{"podScoreWeights": {"maxWeight": -1}}`, "podScoreWeights.maxWeight"},
+		{"model registry name", `{"modelRegistry": {"name": "GPU_Models"}}`, "modelRegistry.name"},
+		{"health temperatures", `{"health": {"degradedTemperature": 90, "unhealthyTemperature": 80}}`, "health.unhealthyTemperature"},
+		{"health penalty", `{"health": {"degradedPenalty": 101}}`, "health.degradedPenalty"},
+		{"stale max age", `{"staleness": {"maxAge": "0s"}}`, "staleness.maxAge"},
+		{"stale policy", `{"staleness": {"policy": "evict"}}`, "staleness.policy"},
+		{"snapshot ttl", `{"resilience": {"snapshotTTL": "0s"}}`, "resilience.snapshotTTL"},
+		{"retry attempts", `{"resilience": {"retryAttempts": 0}}`, "resilience.retryAttempts"},
+		{"failure threshold", `{"resilience": {"failureThreshold": 0}}`, "resilience.failureThreshold"},
+		{"gang timeout", `{"gang": {"timeout": "0s"}}`, "gang.timeout"},
+		{"non-gpu pod policy", `{"nonGPUPodPolicy": "requireCPUNodes"}`, "nonGPUPodPolicy"},
+		{"zero weights", `{"scoreWeights": {"static": 0, "dynamic": 0}}`, "scoreWeights"},
+		{"zero metric weights", `{"scoreWeights": {"static": 0, "freeMemory": 0, "power": 0, "encoderUtilization": 0, "decoderUtilization": 0}}`, "scoreWeights"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := DecodeGeniusArgs(&runtime.Unknown{Raw: []byte(test.raw)})
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("expected an error on %v, got %v", test.err, err)
+			}
+		})
+	}
+}
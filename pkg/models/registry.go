@@ -0,0 +1,229 @@
+// Package models maps the label-safe aliases of GPU models, such as
+// "gtx1080ti", to the model names reported by the metrics sources, such as
+// "GeForce GTX 1080 Ti". The aliases are loaded from a ConfigMap.
+package models
+
+import (
+	"container/list"
+	"fmt"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+	"regexp"
+	"sigs.k8s.io/yaml"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	// ConfigMapKey is the key of the ConfigMap data holding the models.
+	ConfigMapKey = "models.yaml"
+	// maxCachedPatterns bounds the number of compiled selectors cached, since
+	// the selectors are set by the pods.
+	maxCachedPatterns = 256
+)
+
+// Model describes the GPU model of an alias.
+type Model struct {
+	// Names are the exact model names, compared case-insensitively.
+	Names []string `json:"names,omitempty"`
+	// Patterns are the regular expressions matching the model names,
+	// compared case-insensitively.
+	Patterns []string `json:"patterns,omitempty"`
+
+	// Optional metadata of the model.
+	Architecture      string `json:"architecture,omitempty"`
+	ComputeCapability string `json:"computeCapability,omitempty"`
+	Tier              string `json:"tier,omitempty"`
+}
+
+// Config is the content of the ConfigMap, keyed by alias.
+type Config struct {
+	Models map[string]Model `json:"models"`
+}
+
+type entry struct {
+	alias    string
+	model    Model
+	patterns []*regexp.Regexp
+}
+
+func (e *entry) matches(name string) bool {
+	for _, n := range e.model.Names {
+		if strings.EqualFold(n, name) {
+			return true
+		}
+	}
+	for _, p := range e.patterns {
+		if p.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// Registry holds the model aliases. It is safe for concurrent use, and a nil
+// registry has no aliases.
+type Registry struct {
+	lock    sync.RWMutex
+	entries map[string]*entry
+	// aliases are the sorted keys of entries.
+	aliases []string
+
+	// patterns caches the selectors which are not aliases, compiled as
+	// regular expressions, or nil if they do not compile. The least recently
+	// used ones are evicted beyond maxCachedPatterns.
+	patternLock sync.Mutex
+	patterns    map[string]*list.Element
+	recent      *list.List
+}
+
+type cachedPattern struct {
+	selector string
+	re       *regexp.Regexp
+}
+
+// NewRegistry returns an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		entries:  map[string]*entry{},
+		patterns: map[string]*list.Element{},
+		recent:   list.New(),
+	}
+}
+
+// Load replaces the aliases with the ones in the ConfigMap. The aliases with
+// invalid patterns are skipped, while if the ConfigMap cannot be parsed at
+// all, the aliases are left unchanged and an error is returned.
+func (r *Registry) Load(cm *v1.ConfigMap) error {
+	config := &Config{}
+	if err := yaml.UnmarshalStrict([]byte(cm.Data[ConfigMapKey]), config); err != nil {
+		return fmt.Errorf("parsing %v of configmap %v/%v error: %v", ConfigMapKey, cm.Namespace, cm.Name, err)
+	}
+
+	entries := map[string]*entry{}
+	var aliases []string
+	for alias, model := range config.Models {
+		e, err := newEntry(alias, model)
+		if err != nil {
+			klog.Errorf("skipping GPU model alias %q of configmap %v/%v: %v", alias, cm.Namespace, cm.Name, err)
+			continue
+		}
+		entries[alias] = e
+		aliases = append(aliases, alias)
+	}
+	sort.Strings(aliases)
+
+	r.lock.Lock()
+	r.entries, r.aliases = entries, aliases
+	r.lock.Unlock()
+	klog.Infof("loaded %v GPU model alias(es) from configmap %v/%v", len(aliases), cm.Namespace, cm.Name)
+	return nil
+}
+
+// Clear removes all the aliases, such as when the ConfigMap is deleted.
+func (r *Registry) Clear() {
+	r.lock.Lock()
+	r.entries, r.aliases = map[string]*entry{}, nil
+	r.lock.Unlock()
+}
+
+func newEntry(alias string, model Model) (*entry, error) {
+	if len(model.Names) == 0 && len(model.Patterns) == 0 {
+		return nil, fmt.Errorf("neither names nor patterns are set")
+	}
+	e := &entry{alias: alias, model: model}
+	for _, p := range model.Patterns {
+		re, err := regexp.Compile("(?i)" + p)
+		if err != nil {
+			return nil, fmt.Errorf("compiling pattern %q error: %v", p, err)
+		}
+		e.patterns = append(e.patterns, re)
+	}
+	return e, nil
+}
+
+// Lookup returns the model of the alias.
+func (r *Registry) Lookup(alias string) (*Model, bool) {
+	if r == nil {
+		return nil, false
+	}
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	e, ok := r.entries[alias]
+	if !ok {
+		return nil, false
+	}
+	return &e.model, true
+}
+
+// Find returns the alias and the model matching the model name. If several
+// aliases match, the first one in alphabetical order is returned.
+func (r *Registry) Find(name string) (string, *Model, bool) {
+	if r == nil {
+		return "", nil, false
+	}
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	for _, alias := range r.aliases {
+		if e := r.entries[alias]; e.matches(name) {
+			return alias, &e.model, true
+		}
+	}
+	return "", nil, false
+}
+
+// Match judges whether the GPU model name is selected by the selector of a
+// pod. A selector naming an alias matches the names and patterns of the
+// alias. Any other selector is a case-insensitive regular expression, or a
+// case-insensitive substring if it is not a valid regular expression.
+func (r *Registry) Match(selector, name string) bool {
+	if r != nil {
+		r.lock.RLock()
+		e, ok := r.entries[selector]
+		r.lock.RUnlock()
+		if ok {
+			return e.matches(name)
+		}
+	}
+
+	var re *regexp.Regexp
+	if r != nil {
+		re = r.compiledSelector(selector)
+	} else {
+		re = compileSelector(selector)
+	}
+	if re == nil {
+		return strings.Contains(strings.ToLower(name), strings.ToLower(selector))
+	}
+	return re.MatchString(name)
+}
+
+// compiledSelector returns the compiled selector from the cache, compiling
+// and caching it if it is not there.
+func (r *Registry) compiledSelector(selector string) *regexp.Regexp {
+	r.patternLock.Lock()
+	defer r.patternLock.Unlock()
+	if e, ok := r.patterns[selector]; ok {
+		r.recent.MoveToFront(e)
+		return e.Value.(*cachedPattern).re
+	}
+
+	re := compileSelector(selector)
+	r.patterns[selector] = r.recent.PushFront(&cachedPattern{selector: selector, re: re})
+	if r.recent.Len() > maxCachedPatterns {
+		oldest := r.recent.Back()
+		r.recent.Remove(oldest)
+		delete(r.patterns, oldest.Value.(*cachedPattern).selector)
+	}
+	return re
+}
+
+func compileSelector(selector string) *regexp.Regexp {
+	re, err := regexp.Compile("(?i)" + selector)
+	if err != nil {
+		klog.V(3).Infof("GPU model selector %q is not a valid regular expression, matching it as a substring", selector)
+		return nil
+	}
+	return re
+}
@@ -0,0 +1,116 @@
+package models
+
+import (
+	"fmt"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"testing"
+)
+
+func newConfigMap(data string) *v1.ConfigMap {
+	return &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "kube-system", Name: "genius-gpu-models"},
+		Data:       map[string]string{ConfigMapKey: data},
+	}
+}
+
+const testModels = `
+models:
+  a100-80g:
+    names: ["A100-SXM4-80GB", "A100 80GB PCIe"]
+    architecture: ampere
+    computeCapability: "8.0"
+    tier: datacenter
+  t4:
+    patterns: ["^Tesla T4$"]
+    computeCapability: "7.5"
+  broken:
+    patterns: ["(a100"]
+  empty: {}
+`
+
+func TestRegistryLoad(t *testing.T) {
+	r := NewRegistry()
+	if err := r.Load(newConfigMap(testModels)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if model, ok := r.Lookup("a100-80g"); !ok || model.Architecture != "ampere" || model.ComputeCapability != "8.0" || model.Tier != "datacenter" {
+		t.Errorf("unexpected model of a100-80g: %+v, %v", model, ok)
+	}
+	for _, alias := range []string{"broken", "empty"} {
+		if _, ok := r.Lookup(alias); ok {
+			t.Errorf("expected the invalid alias %v to be skipped", alias)
+		}
+	}
+	if alias, model, ok := r.Find("tesla t4"); !ok || alias != "t4" || model.ComputeCapability != "7.5" {
+		t.Errorf("unexpected model of Tesla T4: %v, %+v, %v", alias, model, ok)
+	}
+	if _, _, ok := r.Find("GeForce GTX 1080 Ti"); ok {
+		t.Errorf("expected no alias of GeForce GTX 1080 Ti")
+	}
+
+	// A malformed ConfigMap keeps the previous aliases.
+	if err := r.Load(newConfigMap("models: [")); err == nil {
+		t.Errorf("expected an error on the malformed configmap")
+	}
+	if _, ok := r.Lookup("t4"); !ok {
+		t.Errorf("expected the previous aliases to be kept")
+	}
+
+	r.Clear()
+	if _, ok := r.Lookup("t4"); ok {
+		t.Errorf("expected the aliases to be cleared")
+	}
+}
+
+func TestRegistryMatch(t *testing.T) {
+	r := NewRegistry()
+	if err := r.Load(newConfigMap(testModels)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		registry *Registry
+		selector string
+		model    string
+		want     bool
+	}{
+		{"alias name", r, "a100-80g", "a100 80gb pcie", true},
+		{"alias of another model", r, "a100-80g", "A100-SXM4-40GB", false},
+		{"alias pattern", r, "t4", "Tesla T4", true},
+		{"alias pattern anchored", r, "t4", "Tesla T40", false},
+		{"pattern", r, "a100-sxm4", "A100-SXM4-40GB", true},
+		{"invalid pattern as substring", r, "a100 (", "NVIDIA A100 (SXM4)", true},
+		{"nil registry pattern", nil, "t4", "Tesla T40", true},
+		{"nil registry invalid pattern", nil, "[t4", "Tesla T4", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.registry.Match(test.selector, test.model); got != test.want {
+				t.Errorf("expected %v, got %v", test.want, got)
+			}
+		})
+	}
+}
+
+func TestRegistryPatternCache(t *testing.T) {
+	r := NewRegistry()
+	for i := 0; i < 2*maxCachedPatterns; i++ {
+		r.Match(fmt.Sprintf("model-%v", i), "Tesla T4")
+		// The selector used again and again stays cached.
+		if !r.Match("^tesla", "Tesla T4") {
+			t.Fatalf("expected the cached selector to match")
+		}
+	}
+	if len(r.patterns) != maxCachedPatterns || r.recent.Len() != maxCachedPatterns {
+		t.Fatalf("expected %v selectors cached, got %v", maxCachedPatterns, len(r.patterns))
+	}
+	if _, ok := r.patterns["^tesla"]; !ok {
+		t.Errorf("expected the recently used selector to be cached")
+	}
+	if _, ok := r.patterns["model-0"]; ok {
+		t.Errorf("expected the least recently used selector to be evicted")
+	}
+}
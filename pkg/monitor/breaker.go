@@ -0,0 +1,165 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog/v2"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned by a guarded source while its circuit breaker
+// is open, without calling the source.
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// CircuitBreaker stops calling a failing backend for a while. It opens after
+// a number of consecutive failures, and once the open duration has passed, it
+// lets a single trial call through, which closes it on success or opens it
+// again on failure.
+type CircuitBreaker struct {
+	failureThreshold int
+	openDuration     time.Duration
+	// now is replaced in the tests.
+	now func() time.Time
+
+	lock     sync.Mutex
+	failures int
+	openedAt time.Time
+	trial    bool
+}
+
+// NewCircuitBreaker returns a closed circuit breaker, which opens after
+// failureThreshold consecutive failures and stays open for openDuration.
+func NewCircuitBreaker(failureThreshold int, openDuration time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{
+		failureThreshold: failureThreshold,
+		openDuration:     openDuration,
+		now:              time.Now,
+	}
+}
+
+// Allow returns ErrCircuitOpen if the call should not be made.
+func (b *CircuitBreaker) Allow() error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.openedAt.IsZero() {
+		return nil
+	}
+	if b.trial || b.now().Sub(b.openedAt) < b.openDuration {
+		return ErrCircuitOpen
+	}
+	b.trial = true
+	return nil
+}
+
+// Record records the result of a call allowed by the breaker.
+func (b *CircuitBreaker) Record(err error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	wasOpen := !b.openedAt.IsZero()
+	b.trial = false
+	if err == nil {
+		if wasOpen {
+			klog.Infof("circuit breaker is closed after a successful trial")
+		}
+		b.failures = 0
+		b.openedAt = time.Time{}
+		return
+	}
+
+	b.failures++
+	if wasOpen || b.failures >= b.failureThreshold {
+		if !wasOpen {
+			klog.Warningf("circuit breaker is opened for %v after %v consecutive failures", b.openDuration, b.failures)
+		}
+		b.openedAt = b.now()
+	}
+}
+
+// Open returns whether the breaker is open, including while a trial call
+// is in flight.
+func (b *CircuitBreaker) Open() bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return !b.openedAt.IsZero()
+}
+
+// GuardedSource retries the failed fetches of a metrics source with backoff,
+// and stops fetching from it through a circuit breaker while it keeps failing.
+type GuardedSource struct {
+	source  MetricsSource
+	backoff wait.Backoff
+	breaker *CircuitBreaker
+}
+
+var _ MetricsSource = &GuardedSource{}
+
+// NewGuardedSource guards source. A fetch is tried up to backoff.Steps times,
+// waiting backoff.Duration before the first retry, multiplied by
+// backoff.Factor before each of the following ones.
+func NewGuardedSource(source MetricsSource, backoff wait.Backoff, breaker *CircuitBreaker) *GuardedSource {
+	return &GuardedSource{
+		source:  source,
+		backoff: backoff,
+		breaker: breaker,
+	}
+}
+
+func (g *GuardedSource) Name() string {
+	return g.source.Name()
+}
+
+// Breaker returns the circuit breaker of the source.
+func (g *GuardedSource) Breaker() *CircuitBreaker {
+	return g.breaker
+}
+
+// Fetch fetches from the source until it succeeds, the attempts run out, the
+// breaker opens or ctx is done. The error of the last attempt is returned.
+func (g *GuardedSource) Fetch(ctx context.Context) (*Result, error) {
+	defer func() {
+		open := 0.0
+		if g.breaker.Open() {
+			open = 1
+		}
+		circuitOpen.WithLabelValues(g.Name()).Set(open)
+	}()
+
+	backoff, steps := g.backoff, g.backoff.Steps
+	var lastErr error
+	for attempt := 1; ; attempt++ {
+		if err := g.breaker.Allow(); err != nil {
+			if lastErr != nil {
+				return nil, fmt.Errorf("%v, the last attempt failed: %v", err, lastErr)
+			}
+			return nil, err
+		}
+		result, err := g.source.Fetch(ctx)
+		g.breaker.Record(err)
+		fetchAttempts.WithLabelValues(g.Name(), attemptResult(err)).Inc()
+		if err == nil {
+			return result, nil
+		}
+		lastErr = err
+		if attempt >= steps {
+			return nil, err
+		}
+
+		delay := backoff.Step()
+		klog.V(3).Infof("fetching GPU metrics from %v failed on attempt %v, retrying in %v: %v", g.Name(), attempt, delay, err)
+		select {
+		case <-ctx.Done():
+			return nil, err
+		case <-time.After(delay):
+		}
+	}
+}
+
+func attemptResult(err error) string {
+	if err != nil {
+		return "error"
+	}
+	return "success"
+}
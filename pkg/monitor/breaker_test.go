@@ -0,0 +1,85 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"testing"
+	"time"
+)
+
+// flakySource fails the first failures fetches.
+type flakySource struct {
+	failures int
+	fetches  int
+}
+
+func (f *flakySource) Name() string {
+	return "flaky"
+}
+
+func (f *flakySource) Fetch(ctx context.Context) (*Result, error) {
+	f.fetches++
+	if f.fetches <= f.failures {
+		return nil, errors.New("connection refused")
+	}
+	return &Result{}, nil
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	now := time.Now()
+	b := NewCircuitBreaker(2, time.Minute)
+	b.now = func() time.Time { return now }
+
+	b.Record(errors.New("timeout"))
+	if err := b.Allow(); err != nil {
+		t.Fatalf("expected the breaker to be closed after 1 failure, got %v", err)
+	}
+	b.Record(errors.New("timeout"))
+	if err := b.Allow(); err != ErrCircuitOpen {
+		t.Fatalf("expected the breaker to be open after 2 failures, got %v", err)
+	}
+
+	now = now.Add(time.Minute)
+	if err := b.Allow(); err != nil {
+		t.Fatalf("expected a trial after the open duration, got %v", err)
+	}
+	if err := b.Allow(); err != ErrCircuitOpen {
+		t.Fatalf("expected a single trial at a time, got %v", err)
+	}
+	b.Record(errors.New("timeout"))
+	if err := b.Allow(); err != ErrCircuitOpen || !b.Open() {
+		t.Fatalf("expected the breaker to be opened again by the failed trial, got %v", err)
+	}
+
+	now = now.Add(time.Minute)
+	if err := b.Allow(); err != nil {
+		t.Fatalf("expected a trial after the open duration, got %v", err)
+	}
+	b.Record(nil)
+	if b.Open() {
+		t.Fatalf("expected the breaker to be closed by the successful trial")
+	}
+}
+
+func TestGuardedSourceFetch(t *testing.T) {
+	backoff := wait.Backoff{Duration: time.Millisecond, Factor: 2, Steps: 3}
+
+	source := &flakySource{failures: 2}
+	guarded := NewGuardedSource(source, backoff, NewCircuitBreaker(5, time.Minute))
+	if _, err := guarded.Fetch(context.Background()); err != nil || source.fetches != 3 {
+		t.Errorf("expected success on the third attempt, got %v after %v attempts", err, source.fetches)
+	}
+
+	source = &flakySource{failures: 10}
+	guarded = NewGuardedSource(source, backoff, NewCircuitBreaker(5, time.Minute))
+	if _, err := guarded.Fetch(context.Background()); err == nil || source.fetches != 3 {
+		t.Errorf("expected failure after 3 attempts, got %v after %v attempts", err, source.fetches)
+	}
+	if _, err := guarded.Fetch(context.Background()); err == nil || source.fetches != 5 {
+		t.Errorf("expected the breaker to open after 5 attempts, got %v after %v attempts", err, source.fetches)
+	}
+	if _, err := guarded.Fetch(context.Background()); err != ErrCircuitOpen || source.fetches != 5 {
+		t.Errorf("expected no attempt while the breaker is open, got %v after %v attempts", err, source.fetches)
+	}
+}
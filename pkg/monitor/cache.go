@@ -0,0 +1,245 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"github.com/genius/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog/v2"
+	"k8s.io/kubernetes/pkg/scheduler/framework"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Snapshot is a point-in-time view of the GPU metrics in the cluster.
+// A snapshot is shared by all the scheduling cycles reading it, so it
+// must never be modified once published.
+type Snapshot struct {
+	Metrics *types.GPUMetricsWithProm
+	// Labels holds the labels of the GPUs in Metrics, such as the compute
+	// capability. It is never nil.
+	Labels types.GPULabels
+	// Health holds the health readings of the GPUs in Metrics. It is never nil.
+	Health GPUHealthReadings
+	// SampledAt holds the time of the latest sample of each node, see
+	// NodeSampledAt. It is never nil.
+	SampledAt map[string]time.Time
+	// Source is the name of the metrics source the snapshot was fetched from.
+	Source string
+	// Version is increased by one every time the snapshot is refreshed successfully.
+	Version uint64
+	// CollectedAt is the time at which the metrics were observed by the source,
+	// while UpdatedAt is the time at which the snapshot was published.
+	CollectedAt time.Time
+	UpdatedAt   time.Time
+}
+
+// Mode is how the GPU metrics are read while the metrics source is failing.
+type Mode int
+
+const (
+	// ModeNormal means that the latest refresh succeeded.
+	ModeNormal Mode = iota
+	// ModeDegraded means that the refreshes are failing, and the last known
+	// good snapshot is used, since it is within the TTL.
+	ModeDegraded
+	// ModeFallback means that there is no snapshot within the TTL, so the
+	// GPUs are counted by the allocatable resources of the nodes instead.
+	ModeFallback
+)
+
+func (m Mode) String() string {
+	switch m {
+	case ModeNormal:
+		return "normal"
+	case ModeDegraded:
+		return "degraded"
+	case ModeFallback:
+		return "fallback"
+	}
+	return fmt.Sprintf("Mode(%d)", int(m))
+}
+
+// Clone implements framework.StateData.
+func (m Mode) Clone() framework.StateData {
+	return m
+}
+
+// Cache keeps the latest GPU metrics in memory. The metrics are refreshed by
+// a stand-alone goroutine on a fixed interval, so that reading them in the
+// scheduling cycle does not depend on the round trips to the metrics source.
+type Cache struct {
+	source   MetricsSource
+	interval time.Duration
+	ttl      time.Duration
+
+	lock     sync.RWMutex
+	snapshot *Snapshot
+	// failing is true if the latest refresh failed.
+	failing bool
+}
+
+// NewCache returns a cache refreshing the metrics from source every interval.
+// The last known good snapshot is used for ttl after it was collected, even
+// if the following refreshes fail.
+func NewCache(source MetricsSource, interval, ttl time.Duration) *Cache {
+	return &Cache{
+		source:   source,
+		interval: interval,
+		ttl:      ttl,
+	}
+}
+
+// Run refreshes the metrics periodically until stopCh is closed. The first
+// refresh happens immediately.
+func (c *Cache) Run(stopCh <-chan struct{}) {
+	klog.Infof("starting GPU metrics cache, refreshing from %v every %v", c.source.Name(), c.interval)
+	wait.Until(c.refresh, c.interval, stopCh)
+}
+
+// Snapshot returns the latest GPU metrics snapshot, or nil if the metrics
+// have never been refreshed successfully.
+func (c *Cache) Snapshot() *Snapshot {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.snapshot
+}
+
+// Read returns the latest snapshot and the mode it is read in at now. The
+// snapshot is nil in ModeFallback.
+func (c *Cache) Read(now time.Time) (*Snapshot, Mode) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.read(now)
+}
+
+func (c *Cache) read(now time.Time) (*Snapshot, Mode) {
+	switch {
+	case c.snapshot == nil || now.Sub(c.snapshot.CollectedAt) > c.ttl:
+		return nil, ModeFallback
+	case c.failing:
+		return c.snapshot, ModeDegraded
+	}
+	return c.snapshot, ModeNormal
+}
+
+// refresh updates the metrics and publishes them as a new snapshot. If the
+// update fails, the previous snapshot is kept.
+func (c *Cache) refresh() {
+	result, err := c.source.Fetch(context.Background())
+	if err != nil {
+		klog.Errorf("refreshing GPU metrics from %v error: %v", c.source.Name(), err)
+		c.lock.Lock()
+		c.failing = true
+		snapshot, mode := c.read(time.Now())
+		c.lock.Unlock()
+
+		metricsMode.WithLabelValues(c.source.Name()).Set(float64(mode))
+		if mode == ModeFallback {
+			klog.Warningf("no GPU metrics from %v within %v, counting GPUs by the allocatable resources", c.source.Name(), c.ttl)
+		} else {
+			klog.Warningf("using the last GPU metrics collected from %v at %v", c.source.Name(), snapshot.CollectedAt)
+		}
+		return
+	}
+
+	c.lock.Lock()
+	c.failing = false
+	version := uint64(1)
+	if c.snapshot != nil {
+		version = c.snapshot.Version + 1
+	}
+	gpuLabels := result.Labels
+	if gpuLabels == nil {
+		gpuLabels = make(types.GPULabels)
+	}
+	health := result.Health
+	if health == nil {
+		health = make(GPUHealthReadings)
+	}
+	sampledAt := result.SampledAt
+	if sampledAt == nil {
+		sampledAt = make(map[string]time.Time)
+	}
+	c.snapshot = &Snapshot{
+		Metrics:     result.Metrics,
+		Labels:      gpuLabels,
+		Health:      health,
+		SampledAt:   sampledAt,
+		Source:      c.source.Name(),
+		Version:     version,
+		CollectedAt: result.CollectedAt,
+		UpdatedAt:   time.Now(),
+	}
+	c.lock.Unlock()
+
+	metricsMode.WithLabelValues(c.source.Name()).Set(float64(ModeNormal))
+	klog.V(3).Infof("GPU metrics cache refreshed, current version is %v", version)
+	logMetricsInfo(result.Metrics)
+}
+
+// NodeSampledAt returns the time of the latest sample of the node. The time
+// is capped by CollectedAt, which is used as well if the source reports no
+// sample time of the node.
+func (s *Snapshot) NodeSampledAt(nodename string) time.Time {
+	if ts, ok := s.SampledAt[nodename]; ok && ts.Before(s.CollectedAt) {
+		return ts
+	}
+	return s.CollectedAt
+}
+
+// StaleNodes holds the age of the GPU metrics of the stale nodes.
+// key: nodename
+type StaleNodes map[string]time.Duration
+
+// Clone implements framework.StateData. The stale nodes are never modified
+// once found, so they are shared rather than copied.
+func (s StaleNodes) Clone() framework.StateData {
+	return s
+}
+
+// Names returns the names of the stale nodes in the alphabetical order.
+func (s StaleNodes) Names() []string {
+	names := make([]string, 0, len(s))
+	for nodename := range s {
+		names = append(names, nodename)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// StaleNodes returns the nodes whose latest sample is older than maxAge when
+// the snapshot was collected. The age of the snapshot itself is not counted,
+// since it is bounded by the TTL of the cache.
+func (s *Snapshot) StaleNodes(maxAge time.Duration) StaleNodes {
+	stale := StaleNodes{}
+	for nodename := range *s.Metrics {
+		if age := s.CollectedAt.Sub(s.NodeSampledAt(nodename)); age > maxAge {
+			stale[nodename] = age
+		}
+	}
+	return stale
+}
+
+func logMetricsInfo(metrics *types.GPUMetricsWithProm) {
+	klog.V(3).Infof("updated GPU metrics info:\n")
+	for k, v := range *metrics {
+		klog.V(3).Infof("nodename: %v", k)
+		for _, g := range v.GPUs {
+			klog.V(3).Infof(" id: %v", g.StaticAttr.ID)
+			klog.V(3).Infof(" uuid: %v", g.StaticAttr.UUID)
+			klog.V(3).Infof(" model: %v", g.StaticAttr.Model)
+			klog.V(3).Infof(" decoder utilization: %v", g.DecoderUtilization)
+			klog.V(3).Infof(" encoder utilization: %v", g.EncoderUtilization)
+			klog.V(3).Infof(" memory utilization: %v", g.MemoryUtilization)
+			klog.V(3).Infof(" power usage: %v", g.Power)
+			klog.V(3).Infof(" used global memory: %v", g.UsedGlobalMemory)
+			klog.V(3).Infof(" free global memory: %v", g.FreeGlobalMemory)
+			klog.V(3).Infof(" memory size in MB: %v", g.StaticAttr.MemorySizeMB)
+			klog.V(3).Infof(" multiprocessor count: %v", g.StaticAttr.MultiprocessorCount)
+			klog.V(3).Infof(" shared decoder count: %v", g.StaticAttr.SharedDecoderCount)
+			klog.V(3).Infof(" shared encoder count: %v", g.StaticAttr.SharedEncoderCount)
+		}
+	}
+}
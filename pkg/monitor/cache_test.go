@@ -0,0 +1,113 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"github.com/genius/pkg/types"
+	"github.com/observerward/pkg/scraper"
+	"testing"
+	"time"
+)
+
+type fakeSource struct {
+	result *Result
+	err    error
+}
+
+func (f *fakeSource) Name() string {
+	return "fake"
+}
+
+func (f *fakeSource) Fetch(ctx context.Context) (*Result, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return f.result, nil
+}
+
+func TestCacheRefresh(t *testing.T) {
+	source := &fakeSource{
+		result: &Result{
+			Metrics: &types.GPUMetricsWithProm{
+				"node-1": &scraper.GPUMetrics{GPUs: []*scraper.MetricsSnapshotPerGPU{{}}},
+			},
+			CollectedAt: time.Now(),
+		},
+	}
+	c := NewCache(source, time.Second, time.Minute)
+
+	if c.Snapshot() != nil {
+		t.Fatalf("expected no snapshot before the first refresh")
+	}
+
+	c.refresh()
+	first := c.Snapshot()
+	if first == nil || first.Version != 1 || first.Source != "fake" {
+		t.Fatalf("expected snapshot of version 1, got %+v", first)
+	}
+
+	c.refresh()
+	if v := c.Snapshot().Version; v != 2 {
+		t.Fatalf("expected snapshot of version 2, got %v", v)
+	}
+
+	source.err = errors.New("prometheus unavailable")
+	c.refresh()
+	if v := c.Snapshot().Version; v != 2 {
+		t.Fatalf("expected the previous snapshot to be kept on failure, got version %v", v)
+	}
+	if first.Version != 1 || len(*first.Metrics) != 1 {
+		t.Fatalf("published snapshot has been modified: %+v", first)
+	}
+}
+
+func TestSnapshotStaleNodes(t *testing.T) {
+	collectedAt := time.Now()
+	snapshot := &Snapshot{
+		Metrics: &types.GPUMetricsWithProm{
+			"node-a": &scraper.GPUMetrics{},
+			"node-b": &scraper.GPUMetrics{},
+			"node-c": &scraper.GPUMetrics{},
+		},
+		SampledAt: map[string]time.Time{
+			"node-a": collectedAt.Add(-10 * time.Second),
+			"node-b": collectedAt.Add(-5 * time.Minute),
+		},
+		CollectedAt: collectedAt,
+	}
+
+	// node-c has no sample time, so it is as old as the snapshot.
+	stale := snapshot.StaleNodes(time.Minute)
+	if names := stale.Names(); len(names) != 1 || stale["node-b"] != 5*time.Minute {
+		t.Errorf("expected node-b to be stale, got %v", stale)
+	}
+
+	stale = snapshot.StaleNodes(5 * time.Second)
+	if names := stale.Names(); len(names) != 2 || names[0] != "node-a" || names[1] != "node-b" {
+		t.Errorf("expected node-a and node-b to be stale, got %v", names)
+	}
+}
+
+func TestCacheReadMode(t *testing.T) {
+	collectedAt := time.Now()
+	source := &fakeSource{result: &Result{Metrics: &types.GPUMetricsWithProm{}, CollectedAt: collectedAt}}
+	c := NewCache(source, time.Second, time.Minute)
+
+	if snapshot, mode := c.Read(collectedAt); snapshot != nil || mode != ModeFallback {
+		t.Errorf("expected fallback mode before the first refresh, got %v", mode)
+	}
+
+	c.refresh()
+	if snapshot, mode := c.Read(collectedAt.Add(time.Second)); snapshot == nil || mode != ModeNormal {
+		t.Errorf("expected normal mode after a refresh, got %v", mode)
+	}
+
+	source.err = errors.New("prometheus unavailable")
+	c.refresh()
+	if snapshot, mode := c.Read(collectedAt.Add(30 * time.Second)); snapshot == nil || mode != ModeDegraded {
+		t.Errorf("expected degraded mode within the ttl, got %v", mode)
+	}
+	if snapshot, mode := c.Read(collectedAt.Add(2 * time.Minute)); snapshot != nil || mode != ModeFallback {
+		t.Errorf("expected fallback mode past the ttl, got %v", mode)
+	}
+}
@@ -0,0 +1,134 @@
+package monitor
+
+import (
+	"fmt"
+	"github.com/genius/pkg/types"
+	"github.com/prometheus/common/model"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"strconv"
+)
+
+const (
+	// DCGMSourceName is the name of the metrics source which scrapes the
+	// NVIDIA dcgm-exporters directly.
+	DCGMSourceName = "dcgm"
+)
+
+var (
+	// DefaultDCGMConfig matches the dcgm-exporter helm chart.
+	DefaultDCGMConfig = ExporterConfig{
+		Namespace:     "",
+		LabelSelector: "app.kubernetes.io/name=dcgm-exporter",
+		Port:          9400,
+		Path:          "/metrics",
+	}
+)
+
+// The DCGM fields used by Genius, see
+// https://docs.nvidia.com/datacenter/dcgm/latest/dcgm-api/dcgm-api-field-ids.html.
+const (
+	dcgmFBFree         = "DCGM_FI_DEV_FB_FREE"
+	dcgmFBUsed         = "DCGM_FI_DEV_FB_USED"
+	dcgmPowerUsage     = "DCGM_FI_DEV_POWER_USAGE"
+	dcgmEncUtil        = "DCGM_FI_DEV_ENC_UTIL"
+	dcgmDecUtil        = "DCGM_FI_DEV_DEC_UTIL"
+	dcgmMemCopyUtil    = "DCGM_FI_DEV_MEM_COPY_UTIL"
+	dcgmGPUTemp        = "DCGM_FI_DEV_GPU_TEMP"
+	dcgmXIDErrors      = "DCGM_FI_DEV_XID_ERRORS"
+	dcgmECCDBEVolatile = "DCGM_FI_DEV_ECC_DBE_VOL_TOTAL"
+	dcgmECCSBEVolatile = "DCGM_FI_DEV_ECC_SBE_VOL_TOTAL"
+	dcgmThrottle       = "DCGM_FI_DEV_CLOCK_THROTTLE_REASONS"
+	// dcgmClocksEvent replaces dcgmThrottle since DCGM 3.2.
+	dcgmClocksEvent   = "DCGM_FI_DEV_CLOCKS_EVENT_REASONS"
+	dcgmGPULabel      = "gpu"
+	dcgmUUIDLabel     = "UUID"
+	dcgmModelLabel    = "modelName"
+	dcgmHostnameLabel = "Hostname"
+	// dcgmDriverLabel is attached by the dcgm-exporter of version 3.1 and later.
+	dcgmDriverLabel = "DCGM_FI_DRIVER_VERSION"
+)
+
+var (
+	dcgmMetricTypeMap = map[string]types.MetricType{
+		dcgmFBFree:      types.GPUFreeGlobalMemory,
+		dcgmFBUsed:      types.GPUUsedGlobalMemory,
+		dcgmPowerUsage:  types.GPUPowerUsage,
+		dcgmEncUtil:     types.GPUEncoderUtilization,
+		dcgmDecUtil:     types.GPUDecoderUtilization,
+		dcgmMemCopyUtil: types.GPUMemoryUtilization,
+		dcgmGPUTemp:     types.GPUTemperature,
+	}
+
+	// dcgmHealthFields set the health readings of a GPU.
+	dcgmHealthFields = map[string]func(*HealthReadings, uint64){
+		dcgmXIDErrors:      func(r *HealthReadings, v uint64) { r.XIDError = v },
+		dcgmECCDBEVolatile: func(r *HealthReadings, v uint64) { r.UncorrectableECCErrors = v },
+		dcgmECCSBEVolatile: func(r *HealthReadings, v uint64) { r.CorrectableECCErrors = v },
+		dcgmThrottle:       func(r *HealthReadings, v uint64) { r.ThrottleReasons = v },
+		dcgmClocksEvent:    func(r *HealthReadings, v uint64) { r.ThrottleReasons = v },
+	}
+)
+
+// NewDCGMSource returns a metrics source scraping the dcgm-exporters found by podLister.
+func NewDCGMSource(podLister corelisters.PodLister, config ExporterConfig) (*ExporterSource, error) {
+	return newExporterSource(DCGMSourceName, podLister, config, decodeDCGMVector)
+}
+
+// decodeDCGMVector maps the samples of dcgm-exporter into GPU snapshots.
+// The node of a sample is taken from the "kubernetes_node" label attached by
+// Prometheus if any, then from the node the exporter was scraped on, and at
+// last from the "Hostname" label of the exporter.
+// DCGM does not export the memory size of a GPU, so it is derived from the
+// free and used framebuffer memory.
+func decodeDCGMVector(nodename string, vector model.Vector) (*Result, error) {
+	b := newMetricsBuilder()
+	for _, sample := range vector {
+		name := string(sample.Metric[model.MetricNameLabel])
+		t, ok := dcgmMetricTypeMap[name]
+		setHealth, isHealth := dcgmHealthFields[name]
+		if !ok && !isHealth {
+			continue
+		}
+
+		node := string(sample.Metric[k8sNodeNameLabel])
+		if node == "" {
+			node = nodename
+		}
+		if node == "" {
+			node = string(sample.Metric[dcgmHostnameLabel])
+		}
+		if node == "" {
+			return nil, fmt.Errorf("cannot determine the node of sample %v", sample.Metric)
+		}
+		id, err := strconv.ParseUint(string(sample.Metric[dcgmGPULabel]), 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("parsing %q label of sample %v error: %v", dcgmGPULabel, sample.Metric, err)
+		}
+		val, err := sampleValue(sample.Value)
+		if err != nil {
+			return nil, fmt.Errorf("parsing value of sample %v error: %v", sample.Metric, err)
+		}
+
+		gpuSnapshot := b.gpu(node, uint(id))
+		gpuSnapshot.StaticAttr.UUID = string(sample.Metric[dcgmUUIDLabel])
+		gpuSnapshot.StaticAttr.Model = string(sample.Metric[dcgmModelLabel])
+		if ok {
+			setMetric(gpuSnapshot, t, val)
+		} else {
+			setHealth(b.health.readings(node, uint(id)), val)
+		}
+		b.addLabels(node, uint(id), sample.Metric)
+		b.observe(node, sample.Timestamp)
+		if driver := sample.Metric[dcgmDriverLabel]; driver != "" {
+			b.labels.Set(node, uint(id), types.DriverVersionLabel, string(driver))
+		}
+	}
+
+	result := b.build()
+	for _, gpuMetrics := range *result.Metrics {
+		for _, gpu := range gpuMetrics.GPUs {
+			gpu.StaticAttr.MemorySizeMB = gpu.FreeGlobalMemory + gpu.UsedGlobalMemory
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,85 @@
+package monitor
+
+import (
+	"context"
+	"github.com/genius/pkg/types"
+	"github.com/prometheus/common/model"
+	"testing"
+)
+
+func TestDCGMSourceFetch(t *testing.T) {
+	server, port := newExporterStub(t, "testdata/dcgm-metrics.txt")
+	defer server.Close()
+
+	config := DefaultDCGMConfig
+	config.Port = port
+	podLister := newPodLister(t,
+		newExporterPod("dcgm-exporter-kg7lt", "gpu-operator", "node-a", map[string]string{"app.kubernetes.io/name": "dcgm-exporter"}))
+
+	source, err := NewDCGMSource(podLister, config)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	result, err := source.Fetch(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	gpus := (*result.Metrics)["node-a"].GPUs
+	if len(gpus) != 2 {
+		t.Fatalf("expected 2 GPUs on node-a, got %v", len(gpus))
+	}
+
+	gpu := gpus[0]
+	if gpu.StaticAttr.ID != 0 || gpu.StaticAttr.UUID != "GPU-604ac76c-d9cf-fef3-62e9-d92044ab6e52" ||
+		gpu.StaticAttr.Model != "Tesla V100-SXM2-16GB" {
+		t.Errorf("unexpected static attributes of GPU 0: %+v", gpu.StaticAttr)
+	}
+	if gpu.FreeGlobalMemory != 6330 || gpu.UsedGlobalMemory != 9830 || gpu.StaticAttr.MemorySizeMB != 16160 {
+		t.Errorf("unexpected memory of GPU 0: %+v", gpu)
+	}
+	if gpu.Power != 213 || gpu.EncoderUtilization != 17 || gpu.DecoderUtilization != 23 ||
+		gpu.MemoryUtilization != 38 || gpu.Temperature != 61 {
+		t.Errorf("unexpected dynamic metrics of GPU 0: %+v", gpu)
+	}
+	if driver := result.Labels.Get("node-a", 0, types.DriverVersionLabel); driver != "535.104.05" {
+		t.Errorf("expected driver version 535.104.05 of GPU 0, got %q", driver)
+	}
+	if readings := result.Health.Get("node-a", 0); readings == nil || *readings != (HealthReadings{ThrottleReasons: 0x20}) {
+		t.Errorf("unexpected health readings of GPU 0: %+v", readings)
+	}
+	if readings := result.Health.Get("node-a", 1); readings == nil || *readings != (HealthReadings{XIDError: 79, UncorrectableECCErrors: 2}) {
+		t.Errorf("unexpected health readings of GPU 1: %+v", readings)
+	}
+}
+
+func TestDecodeDCGMVectorNode(t *testing.T) {
+	sample := func(labels model.Metric) *model.Sample {
+		labels[model.MetricNameLabel] = dcgmFBFree
+		labels[dcgmGPULabel] = "0"
+		return &model.Sample{Metric: labels, Value: 100}
+	}
+
+	tests := []struct {
+		name     string
+		nodename string
+		sample   *model.Sample
+		expected string
+	}{
+		{"prometheus node label", "", sample(model.Metric{k8sNodeNameLabel: "node-a", dcgmHostnameLabel: "host"}), "node-a"},
+		{"scraped node", "node-b", sample(model.Metric{dcgmHostnameLabel: "host"}), "node-b"},
+		{"hostname label", "", sample(model.Metric{dcgmHostnameLabel: "host"}), "host"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result, err := decodeDCGMVector(test.nodename, model.Vector{test.sample})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if _, ok := (*result.Metrics)[test.expected]; !ok || len(*result.Metrics) != 1 {
+				t.Errorf("expected metrics of node %v, got %v", test.expected, *result.Metrics)
+			}
+		})
+	}
+}
@@ -0,0 +1,169 @@
+package monitor
+
+import (
+	"fmt"
+	"github.com/genius/pkg/types"
+	"github.com/observerward/pkg/scraper"
+	"github.com/prometheus/common/model"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+)
+
+const (
+	k8sNodeNameLabel = "kubernetes_node"
+	idLabel          = "id"
+	uuidLabel        = "uuid"
+	modelLabel       = "model"
+)
+
+// metricsBuilder groups decoded samples into GPU snapshots by node and GPU id.
+type metricsBuilder struct {
+	gpus   map[string]map[uint]*scraper.MetricsSnapshotPerGPU
+	labels types.GPULabels
+	health GPUHealthReadings
+	// sampledAt is the time of the latest sample of each node.
+	sampledAt map[string]time.Time
+}
+
+func newMetricsBuilder() *metricsBuilder {
+	return &metricsBuilder{
+		gpus:      make(map[string]map[uint]*scraper.MetricsSnapshotPerGPU),
+		labels:    make(types.GPULabels),
+		health:    make(GPUHealthReadings),
+		sampledAt: make(map[string]time.Time),
+	}
+}
+
+// observe records the timestamp of a sample of the node. Samples without a
+// timestamp are ignored.
+func (b *metricsBuilder) observe(nodename string, ts model.Time) {
+	if ts == 0 {
+		return
+	}
+	if t := ts.Time(); t.After(b.sampledAt[nodename]) {
+		b.sampledAt[nodename] = t
+	}
+}
+
+// addLabels keeps the labels of the sample listed in types.GPULabelNames.
+func (b *metricsBuilder) addLabels(nodename string, id uint, metric model.Metric) {
+	for _, name := range types.GPULabelNames {
+		if value := metric[model.LabelName(name)]; value != "" {
+			b.labels.Set(nodename, id, name, string(value))
+		}
+	}
+}
+
+// gpu returns the snapshot of the GPU with the given id on the node, creating
+// it if it has not been seen yet.
+func (b *metricsBuilder) gpu(nodename string, id uint) *scraper.MetricsSnapshotPerGPU {
+	if _, ok := b.gpus[nodename]; !ok {
+		b.gpus[nodename] = make(map[uint]*scraper.MetricsSnapshotPerGPU)
+	}
+	gpuSnapshot, ok := b.gpus[nodename][id]
+	if !ok {
+		gpuSnapshot = &scraper.MetricsSnapshotPerGPU{}
+		gpuSnapshot.StaticAttr.ID = id
+		b.gpus[nodename][id] = gpuSnapshot
+	}
+	return gpuSnapshot
+}
+
+// build returns the GPU metrics, labels, health readings and sample times
+// mapped by nodename. The GPUs on each node are sorted by their ids.
+func (b *metricsBuilder) build() *Result {
+	metricsWithProm := make(types.GPUMetricsWithProm)
+	for nodename, gpusOnNode := range b.gpus {
+		gpuMetrics := &scraper.GPUMetrics{}
+		for _, gpuSnapshot := range gpusOnNode {
+			gpuMetrics.GPUs = append(gpuMetrics.GPUs, gpuSnapshot)
+		}
+		sort.Slice(gpuMetrics.GPUs, func(i, j int) bool {
+			return gpuMetrics.GPUs[i].StaticAttr.ID < gpuMetrics.GPUs[j].StaticAttr.ID
+		})
+		metricsWithProm[nodename] = gpuMetrics
+	}
+	return &Result{Metrics: &metricsWithProm, Labels: b.labels, Health: b.health, SampledAt: b.sampledAt}
+}
+
+// decodeVector maps the samples of an ObserverWard query result into GPU
+// snapshots. Samples of unknown metric names are skipped, while samples with
+// missing or malformed labels and values are reported as errors.
+func decodeVector(vector model.Vector) (*Result, error) {
+	b := newMetricsBuilder()
+	for _, sample := range vector {
+		if err := b.addObserverWardSample(sample); err != nil {
+			return nil, err
+		}
+	}
+	return b.build(), nil
+}
+
+func (b *metricsBuilder) addObserverWardSample(sample *model.Sample) error {
+	name := string(sample.Metric[model.MetricNameLabel])
+	t, ok := types.MetricTypeFromName(name)
+	if !ok {
+		return nil
+	}
+
+	nodename := string(sample.Metric[k8sNodeNameLabel])
+	if nodename == "" {
+		return fmt.Errorf("sample %v has no %q label", sample.Metric, k8sNodeNameLabel)
+	}
+	id, err := strconv.ParseUint(string(sample.Metric[idLabel]), 10, 32)
+	if err != nil {
+		return fmt.Errorf("parsing %q label of sample %v error: %v", idLabel, sample.Metric, err)
+	}
+	val, err := sampleValue(sample.Value)
+	if err != nil {
+		return fmt.Errorf("parsing value of sample %v error: %v", sample.Metric, err)
+	}
+
+	gpuSnapshot := b.gpu(nodename, uint(id))
+	gpuSnapshot.StaticAttr.UUID = string(sample.Metric[uuidLabel])
+	gpuSnapshot.StaticAttr.Model = string(sample.Metric[modelLabel])
+	setMetric(gpuSnapshot, t, val)
+	b.addLabels(nodename, uint(id), sample.Metric)
+	b.observe(nodename, sample.Timestamp)
+	return nil
+}
+
+// sampleValue rounds the sample value to the nearest unsigned integer, which
+// is how the GPU snapshots store their metrics.
+func sampleValue(v model.SampleValue) (uint64, error) {
+	f := float64(v)
+	if math.IsNaN(f) || math.IsInf(f, 0) || f < 0 {
+		return 0, fmt.Errorf("invalid value %v", v)
+	}
+	return uint64(math.Round(f)), nil
+}
+
+// setMetric sets the metric of type t in the GPU snapshot to val.
+func setMetric(gpuSnapshot *scraper.MetricsSnapshotPerGPU, t types.MetricType, val uint64) {
+	switch t {
+	case types.GPUDecoderUtilization:
+		gpuSnapshot.DecoderUtilization = uint(val)
+	case types.GPUEncoderUtilization:
+		gpuSnapshot.EncoderUtilization = uint(val)
+	case types.GPUFreeGlobalMemory:
+		gpuSnapshot.FreeGlobalMemory = val
+	case types.GPUMemoryUtilization:
+		gpuSnapshot.MemoryUtilization = uint(val)
+	case types.GPUPowerUsage:
+		gpuSnapshot.Power = uint(val)
+	case types.GPUUsedGlobalMemory:
+		gpuSnapshot.UsedGlobalMemory = val
+	case types.GPUMemorySize:
+		gpuSnapshot.StaticAttr.MemorySizeMB = val
+	case types.GPUMultiprocessorCount:
+		gpuSnapshot.StaticAttr.MultiprocessorCount = uint32(val)
+	case types.GPUSharedDecoderCount:
+		gpuSnapshot.StaticAttr.SharedDecoderCount = uint32(val)
+	case types.GPUSharedEncoderCount:
+		gpuSnapshot.StaticAttr.SharedEncoderCount = uint32(val)
+	case types.GPUTemperature:
+		gpuSnapshot.Temperature = uint(val)
+	}
+}
@@ -0,0 +1,95 @@
+package monitor
+
+import (
+	"github.com/genius/pkg/types"
+	"github.com/prometheus/common/model"
+	"testing"
+	"time"
+)
+
+func newSample(name, nodename, id, uuid, gpuModel string, value float64) *model.Sample {
+	return &model.Sample{
+		Metric: model.Metric{
+			model.MetricNameLabel: model.LabelValue(name),
+			k8sNodeNameLabel:      model.LabelValue(nodename),
+			idLabel:               model.LabelValue(id),
+			uuidLabel:             model.LabelValue(uuid),
+			modelLabel:            model.LabelValue(gpuModel),
+			"instance":            "192.168.205.114:9909",
+		},
+		Value: model.SampleValue(value),
+	}
+}
+
+func TestDecodeVector(t *testing.T) {
+	vector := model.Vector{
+		newSample("observerward_dynamic_gpu_free_global_memory_MiB", "node-a", "1", "GPU-2", "GeForce GTX 1080 Ti", 8000),
+		newSample("observerward_dynamic_gpu_free_global_memory_MiB", "node-a", "0", "GPU-1", "GeForce GTX 1080 Ti", 4000),
+		newSample("observerward_static_gpu_shared_encoder_count", "node-a", "0", "GPU-1", "GeForce GTX 1080 Ti", 2),
+		newSample("observerward_dynamic_gpu_power_usage_W", "node-b", "0", "GPU-3", `Tesla "V100"`, 59.6),
+		newSample("observerward_dynamic_gpu_unknown_metric", "node-b", "0", "GPU-3", `Tesla "V100"`, 1),
+	}
+
+	vector[0].Metric["compute_capability"] = "6.1"
+	vector[0].Metric["architecture"] = "pascal"
+	vector[0].Timestamp = model.TimeFromUnix(1700000000)
+	vector[1].Timestamp = model.TimeFromUnix(1700000030)
+
+	result, err := decodeVector(vector)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	metrics := result.Metrics
+	if len(*metrics) != 2 {
+		t.Fatalf("expected metrics of 2 nodes, got %v", len(*metrics))
+	}
+
+	gpus := (*metrics)["node-a"].GPUs
+	if len(gpus) != 2 {
+		t.Fatalf("expected 2 GPUs on node-a, got %v", len(gpus))
+	}
+	if gpus[0].StaticAttr.UUID != "GPU-1" || gpus[1].StaticAttr.UUID != "GPU-2" {
+		t.Errorf("expected GPUs sorted by id, got %v and %v", gpus[0].StaticAttr.UUID, gpus[1].StaticAttr.UUID)
+	}
+	if gpus[0].FreeGlobalMemory != 4000 || gpus[0].StaticAttr.SharedEncoderCount != 2 {
+		t.Errorf("unexpected metrics of GPU-1: %+v", gpus[0])
+	}
+
+	gpus = (*metrics)["node-b"].GPUs
+	if len(gpus) != 1 || gpus[0].Power != 60 || gpus[0].StaticAttr.Model != `Tesla "V100"` {
+		t.Errorf("unexpected metrics on node-b: %+v", gpus[0])
+	}
+
+	if cc := result.Labels.Get("node-a", 1, types.ComputeCapabilityLabel); cc != "6.1" {
+		t.Errorf("expected compute capability 6.1 of GPU-2, got %q", cc)
+	}
+	if arch := result.Labels.Get("node-a", 0, types.ArchitectureLabel); arch != "" {
+		t.Errorf("expected no architecture of GPU-1, got %q", arch)
+	}
+
+	if ts := result.SampledAt["node-a"]; !ts.Equal(time.Unix(1700000030, 0)) {
+		t.Errorf("expected the latest sample of node-a at 1700000030, got %v", ts)
+	}
+	if ts, ok := result.SampledAt["node-b"]; ok {
+		t.Errorf("expected no sample time of node-b, got %v", ts)
+	}
+}
+
+func TestDecodeVectorError(t *testing.T) {
+	tests := []struct {
+		name   string
+		sample *model.Sample
+	}{
+		{"missing node", newSample("observerward_dynamic_gpu_power_usage_W", "", "0", "GPU-1", "Tesla V100", 60)},
+		{"malformed id", newSample("observerward_dynamic_gpu_power_usage_W", "node-a", "gpu0", "GPU-1", "Tesla V100", 60)},
+		{"negative value", newSample("observerward_dynamic_gpu_power_usage_W", "node-a", "0", "GPU-1", "Tesla V100", -1)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := decodeVector(model.Vector{test.sample}); err == nil {
+				t.Errorf("expected an error, got nil")
+			}
+		})
+	}
+}
@@ -0,0 +1,173 @@
+package monitor
+
+import (
+	"fmt"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// defaultPort is the port Prometheus serves its HTTP API on by default.
+	defaultPort = 9090
+)
+
+var (
+	// defaultPortNames are the names usually given to the service port of the
+	// Prometheus HTTP API, such as by the prometheus-operator, in the order of
+	// preference.
+	defaultPortNames = []string{"web", "http"}
+
+	// DefaultPrometheusServiceConfig finds the Prometheus service deployed
+	// along with ObserverWard.
+	DefaultPrometheusServiceConfig = ServiceConfig{
+		Namespace:     "prometheus",
+		LabelSelector: "app=prometheus",
+		Scheme:        "http",
+	}
+)
+
+// EndpointResolver resolves the address of the Prometheus HTTP API. It is
+// called before every query, so that endpoint changes are picked up without
+// restarting the scheduler.
+type EndpointResolver interface {
+	Address() (string, error)
+}
+
+// StaticEndpoint is an endpoint resolver which always returns the same address.
+type StaticEndpoint string
+
+func (s StaticEndpoint) Address() (string, error) {
+	return string(s), nil
+}
+
+// DefaultPromAddress returns the address composed of Scheme, PromHost and PromPort.
+func DefaultPromAddress() string {
+	return strings.ToLower(Scheme) + "://" + net.JoinHostPort(PromHost, strconv.Itoa(PromPort))
+}
+
+// ServiceConfig specifies how the Prometheus service is found.
+type ServiceConfig struct {
+	// Namespace of the service. An empty namespace matches all namespaces.
+	Namespace string
+	// Name of the service. If it is empty, the service is selected by LabelSelector.
+	Name          string
+	LabelSelector string
+	// PortName is the name of the service port serving the HTTP API. If it
+	// is empty, the only port of the service is used, or else the port named
+	// in defaultPortNames, or the port defaultPort.
+	PortName string
+	Scheme   string
+}
+
+// ServiceResolver resolves the address of Prometheus from a Kubernetes
+// service, and falls back to a fixed address if no such service is found.
+type ServiceResolver struct {
+	lister   corelisters.ServiceLister
+	config   ServiceConfig
+	selector labels.Selector
+	fallback string
+}
+
+var _ EndpointResolver = &ServiceResolver{}
+
+// NewServiceResolver returns an endpoint resolver finding the Prometheus
+// service through lister. The fallback address is used if the service does
+// not exist, or has not been synced yet.
+func NewServiceResolver(lister corelisters.ServiceLister, config ServiceConfig, fallback string) (*ServiceResolver, error) {
+	selector, err := labels.Parse(config.LabelSelector)
+	if err != nil {
+		return nil, fmt.Errorf("parsing label selector %q of prometheus service error: %v", config.LabelSelector, err)
+	}
+	return &ServiceResolver{
+		lister:   lister,
+		config:   config,
+		selector: selector,
+		fallback: fallback,
+	}, nil
+}
+
+func (r *ServiceResolver) Address() (string, error) {
+	svc, err := r.findService()
+	if err != nil {
+		return "", err
+	}
+	if svc == nil {
+		return r.fallback, nil
+	}
+
+	port, err := r.servicePort(svc)
+	if err != nil {
+		return "", err
+	}
+	host := svc.Spec.ClusterIP
+	if host == "" || host == v1.ClusterIPNone {
+		host = svc.Name + "." + svc.Namespace + ".svc"
+	}
+	return strings.ToLower(r.config.Scheme) + "://" + net.JoinHostPort(host, strconv.Itoa(int(port))), nil
+}
+
+// findService returns the configured service, or nil if it cannot be found.
+// If several services match the label selector, the first one ordered by
+// namespace and name is used.
+func (r *ServiceResolver) findService() (*v1.Service, error) {
+	if r.config.Name != "" {
+		svc, err := r.lister.Services(r.config.Namespace).Get(r.config.Name)
+		if errors.IsNotFound(err) {
+			return nil, nil
+		}
+		return svc, err
+	}
+
+	services, err := r.lister.Services(r.config.Namespace).List(r.selector)
+	if err != nil {
+		return nil, fmt.Errorf("listing prometheus services error: %v", err)
+	}
+	if len(services) == 0 {
+		return nil, nil
+	}
+	sort.Slice(services, func(i, j int) bool {
+		if services[i].Namespace != services[j].Namespace {
+			return services[i].Namespace < services[j].Namespace
+		}
+		return services[i].Name < services[j].Name
+	})
+	return services[0], nil
+}
+
+// servicePort returns the port of the HTTP API. A service with several ports,
+// such as one along with a Thanos sidecar, must name the port of the HTTP API
+// in a usual way, or by the configured port name.
+func (r *ServiceResolver) servicePort(svc *v1.Service) (int32, error) {
+	if r.config.PortName != "" {
+		for _, port := range svc.Spec.Ports {
+			if port.Name == r.config.PortName {
+				return port.Port, nil
+			}
+		}
+		return 0, fmt.Errorf("service %v/%v has no port named %q", svc.Namespace, svc.Name, r.config.PortName)
+	}
+
+	if len(svc.Spec.Ports) == 1 {
+		return svc.Spec.Ports[0].Port, nil
+	}
+	for _, name := range defaultPortNames {
+		for _, port := range svc.Spec.Ports {
+			if port.Name == name {
+				return port.Port, nil
+			}
+		}
+	}
+	for _, port := range svc.Spec.Ports {
+		if port.Port == defaultPort {
+			return port.Port, nil
+		}
+	}
+	return 0, fmt.Errorf("cannot tell the port of the HTTP API among the %v ports of service %v/%v, the port name must be set",
+		len(svc.Spec.Ports), svc.Namespace, svc.Name)
+}
@@ -0,0 +1,127 @@
+package monitor
+
+import (
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"testing"
+)
+
+func newService(name, namespace, clusterIP string, labels map[string]string, ports ...v1.ServicePort) *v1.Service {
+	return &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Labels: labels},
+		Spec:       v1.ServiceSpec{ClusterIP: clusterIP, Ports: ports},
+	}
+}
+
+func TestServiceResolverAddress(t *testing.T) {
+	const fallback = "http://10.0.0.1:30090"
+	webPorts := []v1.ServicePort{{Name: "grpc", Port: 10901}, {Name: "web", Port: 9090}}
+
+	tests := []struct {
+		name     string
+		config   ServiceConfig
+		services []*v1.Service
+		expected string
+	}{
+		{
+			name:     "by label selector",
+			config:   DefaultPrometheusServiceConfig,
+			services: []*v1.Service{newService("prometheus", "prometheus", "10.96.0.10", map[string]string{"app": "prometheus"}, webPorts...)},
+			expected: "http://10.96.0.10:9090",
+		},
+		{
+			name:   "by the default port",
+			config: DefaultPrometheusServiceConfig,
+			services: []*v1.Service{newService("prometheus", "prometheus", "10.96.0.10", map[string]string{"app": "prometheus"},
+				v1.ServicePort{Name: "grpc", Port: 10901}, v1.ServicePort{Name: "api", Port: 9090})},
+			expected: "http://10.96.0.10:9090",
+		},
+		{
+			name:   "by name and port name",
+			config: ServiceConfig{Namespace: "monitoring", Name: "prometheus-k8s", PortName: "web", Scheme: "http"},
+			services: []*v1.Service{
+				newService("prometheus-k8s", "monitoring", "10.96.0.11", nil, webPorts...),
+				newService("prometheus", "prometheus", "10.96.0.10", map[string]string{"app": "prometheus"}, webPorts...),
+			},
+			expected: "http://10.96.0.11:9090",
+		},
+		{
+			name:     "headless service",
+			config:   ServiceConfig{Namespace: "monitoring", Name: "prometheus-operated", Scheme: "https"},
+			services: []*v1.Service{newService("prometheus-operated", "monitoring", v1.ClusterIPNone, nil, webPorts[1])},
+			expected: "https://prometheus-operated.monitoring.svc:9090",
+		},
+		{
+			name:     "fallback",
+			config:   DefaultPrometheusServiceConfig,
+			services: []*v1.Service{newService("grafana", "prometheus", "10.96.0.12", map[string]string{"app": "grafana"}, webPorts...)},
+			expected: fallback,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+			for _, svc := range test.services {
+				if err := indexer.Add(svc); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			resolver, err := NewServiceResolver(corelisters.NewServiceLister(indexer), test.config, fallback)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			address, err := resolver.Address()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if address != test.expected {
+				t.Errorf("expected address %v, got %v", test.expected, address)
+			}
+		})
+	}
+}
+
+func TestServiceResolverPickUpChanges(t *testing.T) {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	resolver, err := NewServiceResolver(corelisters.NewServiceLister(indexer), DefaultPrometheusServiceConfig, "http://10.0.0.1:30090")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	svc := newService("prometheus", "prometheus", "10.96.0.10", map[string]string{"app": "prometheus"}, v1.ServicePort{Port: 9090})
+	if err := indexer.Add(svc); err != nil {
+		t.Fatal(err)
+	}
+	if address, _ := resolver.Address(); address != "http://10.96.0.10:9090" {
+		t.Errorf("expected the added service to be resolved, got %v", address)
+	}
+
+	svc = svc.DeepCopy()
+	svc.Spec.ClusterIP = "10.96.0.20"
+	if err := indexer.Update(svc); err != nil {
+		t.Fatal(err)
+	}
+	if address, _ := resolver.Address(); address != "http://10.96.0.20:9090" {
+		t.Errorf("expected the updated service to be resolved, got %v", address)
+	}
+}
+
+func TestServiceResolverAmbiguousPorts(t *testing.T) {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	svc := newService("prometheus", "prometheus", "10.96.0.10", map[string]string{"app": "prometheus"},
+		v1.ServicePort{Name: "grpc", Port: 10901}, v1.ServicePort{Name: "api", Port: 8080})
+	if err := indexer.Add(svc); err != nil {
+		t.Fatal(err)
+	}
+	resolver, err := NewServiceResolver(corelisters.NewServiceLister(indexer), DefaultPrometheusServiceConfig, "http://10.0.0.1:30090")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if address, err := resolver.Address(); err == nil {
+		t.Errorf("expected an error without the port name, got %v", address)
+	}
+}
@@ -0,0 +1,205 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"github.com/genius/pkg/types"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"github.com/prometheus/common/model"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/klog/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// ObserverWardSourceName is the name of the metrics source which scrapes
+	// the ObserverWard exporters directly.
+	ObserverWardSourceName = "observerward"
+)
+
+var (
+	// DefaultObserverWardConfig matches the DaemonSet shipped with ObserverWard.
+	DefaultObserverWardConfig = ExporterConfig{
+		Namespace:     "prometheus",
+		LabelSelector: "name=observerward",
+		Port:          9909,
+		Path:          "/metrics",
+	}
+)
+
+// ExporterConfig specifies how the exporter pods are discovered and scraped.
+type ExporterConfig struct {
+	// Namespace of the exporter pods. An empty namespace matches all namespaces.
+	Namespace string
+	// LabelSelector selects the exporter pods.
+	LabelSelector string
+	// Port and Path of the metrics endpoint served by each exporter pod.
+	Port int
+	Path string
+}
+
+// ExporterSource is a metrics source which discovers the GPU exporter pods
+// through the Kubernetes API and scrapes the metrics endpoint of each of them,
+// so that no Prometheus server is needed.
+type ExporterSource struct {
+	name      string
+	config    ExporterConfig
+	selector  labels.Selector
+	podLister corelisters.PodLister
+	client    *http.Client
+	// decode maps the samples scraped from the exporter on a node into GPU
+	// snapshots of that node.
+	decode func(nodename string, vector model.Vector) (*Result, error)
+}
+
+var _ MetricsSource = &ExporterSource{}
+
+// NewObserverWardSource returns a metrics source scraping the ObserverWard
+// exporters found by podLister.
+func NewObserverWardSource(podLister corelisters.PodLister, config ExporterConfig) (*ExporterSource, error) {
+	return newExporterSource(ObserverWardSourceName, podLister, config, decodeObserverWardScrape)
+}
+
+func newExporterSource(name string, podLister corelisters.PodLister, config ExporterConfig,
+	decode func(string, model.Vector) (*Result, error)) (*ExporterSource, error) {
+	selector, err := labels.Parse(config.LabelSelector)
+	if err != nil {
+		return nil, fmt.Errorf("parsing label selector %q of %v exporters error: %v", config.LabelSelector, name, err)
+	}
+
+	return &ExporterSource{
+		name:      name,
+		config:    config,
+		selector:  selector,
+		podLister: podLister,
+		client:    &http.Client{Timeout: 10 * time.Second},
+		decode:    decode,
+	}, nil
+}
+
+func (e *ExporterSource) Name() string {
+	return e.name
+}
+
+// Fetch scrapes all the exporter pods concurrently. A node whose exporter
+// cannot be scraped is left out of the result, and an error is returned only
+// if none of the exporters could be scraped.
+func (e *ExporterSource) Fetch(ctx context.Context) (*Result, error) {
+	pods, err := e.podLister.Pods(e.config.Namespace).List(e.selector)
+	if err != nil {
+		return nil, fmt.Errorf("listing %v exporter pods error: %v", e.name, err)
+	}
+
+	now := time.Now()
+	metrics := make(types.GPUMetricsWithProm)
+	gpuLabels := make(types.GPULabels)
+	health := make(GPUHealthReadings)
+	sampledAt := make(map[string]time.Time)
+	var (
+		lock     sync.Mutex
+		wg       sync.WaitGroup
+		failures int
+		targets  int
+	)
+	for _, pod := range pods {
+		if pod.Spec.NodeName == "" || pod.Status.PodIP == "" || pod.Status.Phase != v1.PodRunning {
+			continue
+		}
+
+		targets++
+		wg.Add(1)
+		go func(pod *v1.Pod) {
+			defer wg.Done()
+			nodeResult, err := e.scrape(ctx, pod, now)
+
+			lock.Lock()
+			defer lock.Unlock()
+			if err != nil {
+				klog.Errorf("scraping %v exporter %v/%v on node %v error: %v",
+					e.name, pod.Namespace, pod.Name, pod.Spec.NodeName, err)
+				failures++
+				return
+			}
+			for nodename, gpuMetrics := range *nodeResult.Metrics {
+				metrics[nodename] = gpuMetrics
+			}
+			for nodename, nodeLabels := range nodeResult.Labels {
+				gpuLabels[nodename] = nodeLabels
+			}
+			for nodename, nodeHealth := range nodeResult.Health {
+				health[nodename] = nodeHealth
+			}
+			for nodename, ts := range nodeResult.SampledAt {
+				sampledAt[nodename] = ts
+			}
+		}(pod)
+	}
+	wg.Wait()
+
+	if targets == 0 {
+		klog.Warningf("no running %v exporter pod is found by selector %q in namespace %q",
+			e.name, e.config.LabelSelector, e.config.Namespace)
+	} else if failures == targets {
+		return nil, fmt.Errorf("none of the %v %v exporters could be scraped", targets, e.name)
+	}
+
+	return &Result{
+		Metrics:     &metrics,
+		Labels:      gpuLabels,
+		Health:      health,
+		SampledAt:   sampledAt,
+		CollectedAt: now,
+	}, nil
+}
+
+// scrape retrieves the metrics endpoint of an exporter pod and decodes the
+// text exposition format into GPU snapshots of the node the pod runs on.
+func (e *ExporterSource) scrape(ctx context.Context, pod *v1.Pod, ts time.Time) (*Result, error) {
+	url := "http://" + net.JoinHostPort(pod.Status.PodIP, strconv.Itoa(e.config.Port)) + e.config.Path
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", string(expfmt.FmtText))
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %v from %v", resp.Status, url)
+	}
+
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("parsing metrics from %v error: %v", url, err)
+	}
+	fams := make([]*dto.MetricFamily, 0, len(families))
+	for _, family := range families {
+		fams = append(fams, family)
+	}
+	vector, err := expfmt.ExtractSamples(&expfmt.DecodeOptions{Timestamp: model.TimeFromUnixNano(ts.UnixNano())}, fams...)
+	if err != nil {
+		return nil, fmt.Errorf("extracting samples from %v error: %v", url, err)
+	}
+	return e.decode(pod.Spec.NodeName, vector)
+}
+
+// decodeObserverWardScrape decodes the samples scraped from an ObserverWard
+// exporter. The exporter does not know which node it runs on, so the node
+// name label Prometheus would have attached is added before decoding.
+func decodeObserverWardScrape(nodename string, vector model.Vector) (*Result, error) {
+	for _, sample := range vector {
+		sample.Metric[k8sNodeNameLabel] = model.LabelValue(nodename)
+	}
+	return decodeVector(vector)
+}
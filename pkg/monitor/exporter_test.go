@@ -0,0 +1,101 @@
+package monitor
+
+import (
+	"context"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+// newExporterStub serves the fixture file on the metrics path of a local HTTP
+// server, and returns the server along with its port.
+func newExporterStub(t *testing.T, fixture string) (*httptest.Server, int) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFile(w, r, fixture)
+	})
+	server := httptest.NewServer(mux)
+
+	_, port, err := net.SplitHostPort(server.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	portInt, _ := strconv.Atoi(port)
+	return server, portInt
+}
+
+func newExporterPod(name, namespace, nodename string, labels map[string]string) *v1.Pod {
+	return &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Labels: labels},
+		Spec:       v1.PodSpec{NodeName: nodename},
+		Status:     v1.PodStatus{Phase: v1.PodRunning, PodIP: "127.0.0.1"},
+	}
+}
+
+func newPodLister(t *testing.T, pods ...*v1.Pod) corelisters.PodLister {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	for _, pod := range pods {
+		if err := indexer.Add(pod); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return corelisters.NewPodLister(indexer)
+}
+
+func TestObserverWardSourceFetch(t *testing.T) {
+	server, port := newExporterStub(t, "testdata/observerward-metrics.txt")
+	defer server.Close()
+
+	config := DefaultObserverWardConfig
+	config.Port = port
+	podLister := newPodLister(t,
+		newExporterPod("observerward-a", "prometheus", "node-a", map[string]string{"name": "observerward"}),
+		newExporterPod("other-exporter", "prometheus", "node-b", map[string]string{"name": "other"}),
+	)
+
+	source, err := NewObserverWardSource(podLister, config)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	result, err := source.Fetch(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(*result.Metrics) != 1 {
+		t.Fatalf("expected metrics of 1 node, got %v", len(*result.Metrics))
+	}
+	gpus := (*result.Metrics)["node-a"].GPUs
+	if len(gpus) != 2 {
+		t.Fatalf("expected 2 GPUs on node-a, got %v", len(gpus))
+	}
+	if gpus[1].StaticAttr.UUID != "GPU-8763e6c0-e8b9-ac77-91ba-407ee16f5493" || gpus[1].FreeGlobalMemory != 7137 ||
+		gpus[1].Power != 61 || gpus[1].StaticAttr.MemorySizeMB != 11178 || gpus[1].StaticAttr.Model != "GeForce GTX 1080 Ti" {
+		t.Errorf("unexpected metrics of GPU 1: %+v", gpus[1])
+	}
+}
+
+func TestObserverWardSourceFetchError(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+	defer server.Close()
+
+	config := DefaultObserverWardConfig
+	config.Port, _ = strconv.Atoi(port)
+	podLister := newPodLister(t,
+		newExporterPod("observerward-a", "prometheus", "node-a", map[string]string{"name": "observerward"}))
+
+	source, err := NewObserverWardSource(podLister, config)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := source.Fetch(context.Background()); err == nil {
+		t.Errorf("expected an error when no exporter could be scraped, got nil")
+	}
+}
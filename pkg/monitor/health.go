@@ -0,0 +1,208 @@
+package monitor
+
+import (
+	"fmt"
+	"github.com/genius/pkg/types"
+	"github.com/observerward/pkg/scraper"
+	"k8s.io/kubernetes/pkg/scheduler/framework"
+	"sort"
+	"strings"
+)
+
+// HealthReadings are the metrics telling the health of a GPU, which the GPU
+// snapshots have no field for.
+type HealthReadings struct {
+	// XIDError is the last XID error of the GPU, or 0 if there is none.
+	XIDError uint64
+	// UncorrectableECCErrors and CorrectableECCErrors are the volatile
+	// double-bit and single-bit ECC errors since the driver was loaded.
+	UncorrectableECCErrors uint64
+	CorrectableECCErrors   uint64
+	// ThrottleReasons is the bitmask of the reasons the clocks are throttled.
+	ThrottleReasons uint64
+}
+
+// GPUHealthReadings holds the health readings of the GPUs.
+// key: nodename, then GPU id
+type GPUHealthReadings map[string]map[uint]*HealthReadings
+
+// Get returns the readings of the GPU, or nil if there are none.
+func (r GPUHealthReadings) Get(nodename string, id uint) *HealthReadings {
+	return r[nodename][id]
+}
+
+// readings returns the readings of the GPU, creating them if there are none.
+func (r GPUHealthReadings) readings(nodename string, id uint) *HealthReadings {
+	if _, ok := r[nodename]; !ok {
+		r[nodename] = make(map[uint]*HealthReadings)
+	}
+	readings, ok := r[nodename][id]
+	if !ok {
+		readings = &HealthReadings{}
+		r[nodename][id] = readings
+	}
+	return readings
+}
+
+// The clock throttle reasons caused by the temperature of the GPU, see
+// nvmlClocksThrottleReasons of NVML.
+const (
+	throttleHWSlowdown = 0x8
+	throttleSWThermal  = 0x20
+	throttleHWThermal  = 0x40
+
+	thermalThrottleReasons = throttleHWSlowdown | throttleSWThermal | throttleHWThermal
+)
+
+// HealthStatus is the health status of a GPU.
+type HealthStatus string
+
+const (
+	// Healthy GPUs are used as usual.
+	Healthy HealthStatus = "Healthy"
+	// Degraded GPUs are still usable, but the nodes of them are scored lower.
+	Degraded HealthStatus = "Degraded"
+	// Unhealthy GPUs are excluded from scheduling.
+	Unhealthy HealthStatus = "Unhealthy"
+)
+
+// Health is the health status of a GPU, along with the reasons of it.
+type Health struct {
+	Status  HealthStatus
+	Reasons []string
+}
+
+// HealthThresholds tells the health status of a GPU from its metrics. A zero
+// temperature threshold disables the check of it.
+type HealthThresholds struct {
+	// IgnoredXIDs are the XID errors which do not make a GPU unhealthy, since
+	// they are caused by the applications rather than the hardware.
+	IgnoredXIDs map[uint64]bool
+	// A GPU with more uncorrectable ECC errors is unhealthy, and a GPU with
+	// more correctable ECC errors is degraded.
+	MaxUncorrectableECCErrors uint64
+	MaxCorrectableECCErrors   uint64
+	// A GPU reaching DegradedTemperature or being thermally throttled is
+	// degraded, and a GPU reaching UnhealthyTemperature is unhealthy, in °C.
+	DegradedTemperature  uint
+	UnhealthyTemperature uint
+}
+
+// Evaluate returns the health of the GPU. readings is nil if the metrics
+// source does not report them, in which case only the temperature is checked.
+func (t *HealthThresholds) Evaluate(gpu *scraper.MetricsSnapshotPerGPU, readings *HealthReadings) Health {
+	var unhealthy, degraded []string
+	if readings != nil {
+		if readings.XIDError != 0 && !t.IgnoredXIDs[readings.XIDError] {
+			unhealthy = append(unhealthy, fmt.Sprintf("XID error %v", readings.XIDError))
+		}
+		if readings.UncorrectableECCErrors > t.MaxUncorrectableECCErrors {
+			unhealthy = append(unhealthy, fmt.Sprintf("%v uncorrectable ECC errors", readings.UncorrectableECCErrors))
+		}
+		if readings.CorrectableECCErrors > t.MaxCorrectableECCErrors {
+			degraded = append(degraded, fmt.Sprintf("%v correctable ECC errors", readings.CorrectableECCErrors))
+		}
+		if readings.ThrottleReasons&thermalThrottleReasons != 0 {
+			degraded = append(degraded, fmt.Sprintf("thermally throttled (reasons 0x%x)", readings.ThrottleReasons))
+		}
+	}
+	switch {
+	case t.UnhealthyTemperature != 0 && gpu.Temperature >= t.UnhealthyTemperature:
+		unhealthy = append(unhealthy, fmt.Sprintf("temperature %v°C", gpu.Temperature))
+	case t.DegradedTemperature != 0 && gpu.Temperature >= t.DegradedTemperature:
+		degraded = append(degraded, fmt.Sprintf("temperature %v°C", gpu.Temperature))
+	}
+
+	if len(unhealthy) > 0 {
+		return Health{Status: Unhealthy, Reasons: append(unhealthy, degraded...)}
+	}
+	if len(degraded) > 0 {
+		return Health{Status: Degraded, Reasons: degraded}
+	}
+	return Health{Status: Healthy}
+}
+
+// HealthReport holds the health of the GPUs which are not healthy.
+// key: nodename, then GPU id
+type HealthReport map[string]map[uint]Health
+
+// Clone implements framework.StateData. The report is never modified once
+// checked, so it is shared rather than copied.
+func (r HealthReport) Clone() framework.StateData {
+	return r
+}
+
+// Count returns the number of GPUs of the status on the node.
+func (r HealthReport) Count(nodename string, status HealthStatus) int {
+	count := 0
+	for _, health := range r[nodename] {
+		if health.Status == status {
+			count++
+		}
+	}
+	return count
+}
+
+// Describe describes the GPUs of the status on the node, such as
+// "gpu 1 (XID error 79)", in the order of their ids.
+func (r HealthReport) Describe(nodename string, status HealthStatus) []string {
+	var ids []uint
+	for id, health := range r[nodename] {
+		if health.Status == status {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	descriptions := make([]string, 0, len(ids))
+	for _, id := range ids {
+		descriptions = append(descriptions, fmt.Sprintf("gpu %v (%v)", id, strings.Join(r[nodename][id].Reasons, ", ")))
+	}
+	return descriptions
+}
+
+// Check evaluates the health of all the GPUs, and returns the metrics without
+// the unhealthy GPUs along with the report of the GPUs which are not healthy.
+// The metrics are shared by the scheduling cycles, so only the nodes with
+// unhealthy GPUs are copied, and metrics itself is returned if there is none.
+func (t *HealthThresholds) Check(metrics *types.GPUMetricsWithProm, readings GPUHealthReadings) (*types.GPUMetricsWithProm, HealthReport) {
+	report := HealthReport{}
+	var healthy *types.GPUMetricsWithProm
+	for nodename, gpuMetrics := range *metrics {
+		if gpuMetrics == nil {
+			continue
+		}
+		var kept []*scraper.MetricsSnapshotPerGPU
+		excluded := false
+		for _, gpu := range gpuMetrics.GPUs {
+			health := t.Evaluate(gpu, readings.Get(nodename, gpu.StaticAttr.ID))
+			if health.Status != Healthy {
+				if _, ok := report[nodename]; !ok {
+					report[nodename] = make(map[uint]Health)
+				}
+				report[nodename][gpu.StaticAttr.ID] = health
+			}
+			if health.Status == Unhealthy {
+				excluded = true
+				continue
+			}
+			kept = append(kept, gpu)
+		}
+		if !excluded {
+			continue
+		}
+
+		if healthy == nil {
+			copied := make(types.GPUMetricsWithProm, len(*metrics))
+			for k, v := range *metrics {
+				copied[k] = v
+			}
+			healthy = &copied
+		}
+		(*healthy)[nodename] = &scraper.GPUMetrics{GPUs: kept}
+	}
+	if healthy == nil {
+		return metrics, report
+	}
+	return healthy, report
+}
@@ -0,0 +1,89 @@
+package monitor
+
+import (
+	"github.com/genius/pkg/types"
+	"github.com/observerward/pkg/scraper"
+	"reflect"
+	"testing"
+)
+
+func newHealthThresholds() *HealthThresholds {
+	return &HealthThresholds{
+		IgnoredXIDs:             map[uint64]bool{13: true, 31: true},
+		MaxCorrectableECCErrors: 100,
+		DegradedTemperature:     85,
+		UnhealthyTemperature:    95,
+	}
+}
+
+func TestHealthThresholdsEvaluate(t *testing.T) {
+	tests := []struct {
+		name        string
+		temperature uint
+		readings    *HealthReadings
+		want        Health
+	}{
+		{"no readings", 60, nil, Health{Status: Healthy}},
+		{"healthy", 60, &HealthReadings{CorrectableECCErrors: 100}, Health{Status: Healthy}},
+		{"ignored xid", 60, &HealthReadings{XIDError: 13}, Health{Status: Healthy}},
+		{"xid", 60, &HealthReadings{XIDError: 79}, Health{Status: Unhealthy, Reasons: []string{"XID error 79"}}},
+		{"uncorrectable ecc", 60, &HealthReadings{UncorrectableECCErrors: 1}, Health{Status: Unhealthy, Reasons: []string{"1 uncorrectable ECC errors"}}},
+		{"correctable ecc", 60, &HealthReadings{CorrectableECCErrors: 101}, Health{Status: Degraded, Reasons: []string{"101 correctable ECC errors"}}},
+		{"thermal throttling", 60, &HealthReadings{ThrottleReasons: 0x40 | 0x1}, Health{Status: Degraded, Reasons: []string{"thermally throttled (reasons 0x41)"}}},
+		{"power throttling", 60, &HealthReadings{ThrottleReasons: 0x4}, Health{Status: Healthy}},
+		{"hot", 88, nil, Health{Status: Degraded, Reasons: []string{"temperature 88°C"}}},
+		{"too hot", 95, nil, Health{Status: Unhealthy, Reasons: []string{"temperature 95°C"}}},
+		{
+			name:        "unhealthy and degraded",
+			temperature: 90,
+			readings:    &HealthReadings{XIDError: 48},
+			want:        Health{Status: Unhealthy, Reasons: []string{"XID error 48", "temperature 90°C"}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			gpu := &scraper.MetricsSnapshotPerGPU{Temperature: test.temperature}
+			if got := newHealthThresholds().Evaluate(gpu, test.readings); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("expected %+v, got %+v", test.want, got)
+			}
+		})
+	}
+}
+
+func TestHealthThresholdsCheck(t *testing.T) {
+	newGPU := func(id uint, temperature uint) *scraper.MetricsSnapshotPerGPU {
+		gpu := &scraper.MetricsSnapshotPerGPU{Temperature: temperature}
+		gpu.StaticAttr.ID = id
+		return gpu
+	}
+	metrics := &types.GPUMetricsWithProm{
+		"node-a": {GPUs: []*scraper.MetricsSnapshotPerGPU{newGPU(0, 60), newGPU(1, 60), newGPU(2, 90)}},
+		"node-b": {GPUs: []*scraper.MetricsSnapshotPerGPU{newGPU(0, 60)}},
+	}
+	readings := GPUHealthReadings{}
+	readings.readings("node-a", 1).XIDError = 79
+
+	healthy, report := newHealthThresholds().Check(metrics, readings)
+	if gpus := (*healthy)["node-a"].GPUs; len(gpus) != 2 || gpus[0].StaticAttr.ID != 0 || gpus[1].StaticAttr.ID != 2 {
+		t.Errorf("expected GPU 1 of node-a to be excluded, got %+v", gpus)
+	}
+	if len((*metrics)["node-a"].GPUs) != 3 {
+		t.Errorf("expected the metrics to be unchanged")
+	}
+	if (*healthy)["node-b"] != (*metrics)["node-b"] {
+		t.Errorf("expected node-b to be shared")
+	}
+	if report.Count("node-a", Unhealthy) != 1 || report.Count("node-a", Degraded) != 1 || len(report["node-b"]) != 0 {
+		t.Errorf("unexpected report: %+v", report)
+	}
+	if got := report.Describe("node-a", Unhealthy); !reflect.DeepEqual(got, []string{"gpu 1 (XID error 79)"}) {
+		t.Errorf("unexpected description: %v", got)
+	}
+
+	// Nothing is copied if all the GPUs are usable.
+	delete(readings, "node-a")
+	if healthy, _ := newHealthThresholds().Check(metrics, readings); healthy != metrics {
+		t.Errorf("expected the metrics to be returned as they are")
+	}
+}
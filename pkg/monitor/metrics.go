@@ -0,0 +1,46 @@
+package monitor
+
+import (
+	"k8s.io/component-base/metrics"
+	"k8s.io/component-base/metrics/legacyregistry"
+	"sync"
+)
+
+const (
+	metricsSubsystem = "genius"
+)
+
+var (
+	metricsMode = metrics.NewGaugeVec(
+		&metrics.GaugeOpts{
+			Subsystem: metricsSubsystem,
+			Name:      "metrics_mode",
+			Help: "Mode the GPU metrics of the source are read in: 0 if they are up to date, 1 if the last known good " +
+				"snapshot is used since the source is failing, and 2 if the GPUs are counted by the allocatable resources.",
+			StabilityLevel: metrics.ALPHA,
+		}, []string{"source"})
+	circuitOpen = metrics.NewGaugeVec(
+		&metrics.GaugeOpts{
+			Subsystem:      metricsSubsystem,
+			Name:           "metrics_source_circuit_open",
+			Help:           "Whether the circuit breaker of the GPU metrics source is open, 1 if it is.",
+			StabilityLevel: metrics.ALPHA,
+		}, []string{"source"})
+	fetchAttempts = metrics.NewCounterVec(
+		&metrics.CounterOpts{
+			Subsystem:      metricsSubsystem,
+			Name:           "metrics_fetch_attempts_total",
+			Help:           "Number of attempts to fetch the GPU metrics from the source, by the result.",
+			StabilityLevel: metrics.ALPHA,
+		}, []string{"source", "result"})
+
+	registerMetrics sync.Once
+)
+
+// RegisterMetrics registers the metrics of the GPU metrics sources in the
+// legacy registry, which the scheduler serves on its /metrics endpoint.
+func RegisterMetrics() {
+	registerMetrics.Do(func() {
+		legacyregistry.MustRegister(metricsMode, circuitOpen, fetchAttempts)
+	})
+}
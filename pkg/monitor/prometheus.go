@@ -0,0 +1,168 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"github.com/prometheus/client_golang/api"
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+	"k8s.io/klog/v2"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var (
+	// Scheme, PromHost and PromPort compose the address of Prometheus used
+	// when it is neither configured nor discovered.
+	Scheme   = "http"
+	PromHost = "222.201.144.187"
+	PromPort = 30090
+)
+
+const (
+	// PrometheusSourceName is the name of the metrics source backed by Prometheus.
+	PrometheusSourceName = "prometheus"
+
+	// batchQuery selects all the metrics exported by ObserverWard.
+	batchQuery = `{__name__=~"observerward_.*"}`
+	// sampledAtQuery selects the time of the latest ObserverWard sample of
+	// each node. The samples returned by batchQuery are stamped with the
+	// evaluation time instead, even if they are as old as the lookback window.
+	sampledAtQuery = `max by (` + k8sNodeNameLabel + `) (timestamp(` + batchQuery + `))`
+)
+
+var _ MetricsSource = &PrometheusSource{}
+
+// PrometheusSource is a metrics source which queries the ObserverWard metrics
+// scraped by a Prometheus server.
+type PrometheusSource struct {
+	resolver     EndpointResolver
+	roundTripper http.RoundTripper
+
+	lock        sync.Mutex
+	promAddress string
+	client      api.Client
+}
+
+// NewPrometheusSource returns a new metrics source backed by Prometheus.
+// The address of the Prometheus HTTP API is resolved by resolver before
+// every query. The requests are sent through roundTripper, or
+// api.DefaultRoundTripper if it is nil.
+func NewPrometheusSource(resolver EndpointResolver, roundTripper http.RoundTripper) *PrometheusSource {
+	return &PrometheusSource{
+		resolver:     resolver,
+		roundTripper: roundTripper,
+	}
+}
+
+func (p *PrometheusSource) Name() string {
+	return PrometheusSourceName
+}
+
+// Fetch returns the updated GPU metrics mapped by their nodename.
+// All the ObserverWard metrics are retrieved by a single instant query, and
+// the samples are grouped in memory by node, GPU id and metric type.
+func (p *PrometheusSource) Fetch(ctx context.Context) (*Result, error) {
+	now := time.Now()
+	result, err := p.query(ctx, batchQuery, now)
+	if err != nil {
+		klog.Errorf("querying GPU metrics error: %v", err)
+		return nil, err
+	}
+
+	vector, ok := result.(model.Vector)
+	if !ok {
+		return nil, fmt.Errorf("unexpected type %v of prometheus query result", result.Type())
+	}
+	decoded, err := decodeVector(vector)
+	if err != nil {
+		return nil, err
+	}
+	decoded.SampledAt = p.sampledAt(ctx, now)
+	decoded.CollectedAt = now
+	return decoded, nil
+}
+
+// sampledAt returns the time of the latest sample of each node. If the query
+// fails, nil is returned, so that the nodes are read as sampled at the
+// evaluation time.
+func (p *PrometheusSource) sampledAt(ctx context.Context, ts time.Time) map[string]time.Time {
+	result, err := p.query(ctx, sampledAtQuery, ts)
+	if err != nil {
+		klog.Warningf("querying the sample time of GPU metrics error: %v", err)
+		return nil
+	}
+	vector, ok := result.(model.Vector)
+	if !ok {
+		klog.Warningf("unexpected type %v of the sample time query result", result.Type())
+		return nil
+	}
+	return decodeSampledAt(vector)
+}
+
+// decodeSampledAt maps the result of sampledAtQuery, whose values are Unix
+// timestamps in seconds, by nodename.
+func decodeSampledAt(vector model.Vector) map[string]time.Time {
+	sampledAt := make(map[string]time.Time, len(vector))
+	for _, sample := range vector {
+		nodename := string(sample.Metric[k8sNodeNameLabel])
+		if nodename == "" {
+			continue
+		}
+		sec, frac := math.Modf(float64(sample.Value))
+		sampledAt[nodename] = time.Unix(int64(sec), int64(frac*float64(time.Second)))
+	}
+	return sampledAt
+}
+
+// apiClient returns the client of the current Prometheus address. The client
+// is recreated only if the address has changed since the last query.
+func (p *PrometheusSource) apiClient() (api.Client, error) {
+	address, err := p.resolver.Address()
+	if err != nil {
+		return nil, fmt.Errorf("resolving prometheus address error: %v", err)
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.client != nil && address == p.promAddress {
+		return p.client, nil
+	}
+
+	client, err := api.NewClient(api.Config{
+		Address:      address,
+		RoundTripper: p.roundTripper,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("creating prometheus client error: %v", err)
+	}
+	klog.Infof("prometheus address is changed from %q to %q", p.promAddress, address)
+	p.promAddress = address
+	p.client = client
+	return client, nil
+}
+
+// query calls prometheus HTTP api to retrieve metrics evaluated at ts.
+// This function refers to the Instant queries on page https://prometheus.io/docs/prometheus/latest/querying/api/.
+// The return value should be further processed against concrete business logic.
+func (p *PrometheusSource) query(ctx context.Context, qString string, ts time.Time) (model.Value, error) {
+	client, err := p.apiClient()
+	if err != nil {
+		return nil, err
+	}
+	v1api := v1.NewAPI(client)
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	result, warnings, err := v1api.Query(ctx, qString, ts)
+	if err != nil {
+		klog.Errorf("querying prometheus error: %v", err)
+		return nil, err
+	}
+	if len(warnings) > 0 {
+		klog.Warningf("warnings: %v", warnings)
+	}
+	return result, nil
+}
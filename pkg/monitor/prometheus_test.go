@@ -0,0 +1,60 @@
+package monitor
+
+import (
+	"context"
+	"github.com/prometheus/common/model"
+	"os"
+	"testing"
+	"time"
+)
+
+// testPrometheusURL names the environment variable holding the address of a
+// live Prometheus with the GPU metrics, such as "http://localhost:30090". The
+// tests querying it are skipped if it is not set.
+const testPrometheusURL = "GENIUS_TEST_PROMETHEUS_URL"
+
+func newTestPrometheusSource(t *testing.T) *PrometheusSource {
+	url := os.Getenv(testPrometheusURL)
+	if url == "" {
+		t.Skipf("%v is not set, skipping the test against a live Prometheus", testPrometheusURL)
+	}
+	return NewPrometheusSource(StaticEndpoint(url), nil)
+}
+
+func TestQuery(t *testing.T) {
+	p := newTestPrometheusSource(t)
+	val, err := p.query(context.Background(), batchQuery, time.Now())
+	if err != nil {
+		t.Fatalf("querying prometheus error: %v", err)
+	}
+	if val.Type() != model.ValVector {
+		t.Errorf("expected a vector, got %v", val.Type())
+	}
+}
+
+func TestFetch(t *testing.T) {
+	p := newTestPrometheusSource(t)
+	result, err := p.Fetch(context.Background())
+	if err != nil {
+		t.Fatalf("fetching from prometheus error: %v", err)
+	}
+	for k, v := range *result.Metrics {
+		t.Logf("NodeName: %v", k)
+		for _, g := range v.GPUs {
+			t.Logf(" ID: %v, UUID: %v, model: %v", g.StaticAttr.ID, g.StaticAttr.UUID, g.StaticAttr.Model)
+			t.Logf(" used global memory: %v, free global memory: %v, memory size in MB: %v",
+				g.UsedGlobalMemory, g.FreeGlobalMemory, g.StaticAttr.MemorySizeMB)
+		}
+	}
+}
+
+func TestDecodeSampledAt(t *testing.T) {
+	vector := model.Vector{
+		{Metric: model.Metric{k8sNodeNameLabel: "node-a"}, Value: 1700000000.5},
+		{Metric: model.Metric{}, Value: 1700000000},
+	}
+	sampledAt := decodeSampledAt(vector)
+	if len(sampledAt) != 1 || !sampledAt["node-a"].Equal(time.Unix(1700000000, int64(time.Second/2))) {
+		t.Errorf("unexpected sample time: %v", sampledAt)
+	}
+}
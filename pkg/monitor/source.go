@@ -0,0 +1,32 @@
+package monitor
+
+import (
+	"context"
+	"github.com/genius/pkg/types"
+	"time"
+)
+
+// MetricsSource is a backend providing the GPU metrics of the cluster.
+// Implementations must be safe to be fetched from the cache goroutine while
+// the scheduler is running.
+type MetricsSource interface {
+	// Name returns the name of the source, which is used to select it in
+	// the plugin configuration.
+	Name() string
+	// Fetch collects the latest GPU metrics of the cluster.
+	Fetch(ctx context.Context) (*Result, error)
+}
+
+// Result is the GPU metrics fetched from a metrics source.
+type Result struct {
+	Metrics *types.GPUMetricsWithProm
+	// Labels holds the labels of the GPUs in Metrics, if any.
+	Labels types.GPULabels
+	// Health holds the health readings of the GPUs in Metrics, if any.
+	Health GPUHealthReadings
+	// SampledAt holds the time of the latest sample of each node in Metrics.
+	// The nodes missing from it are read as sampled at CollectedAt.
+	SampledAt map[string]time.Time
+	// CollectedAt is the time at which the metrics were observed by the source.
+	CollectedAt time.Time
+}
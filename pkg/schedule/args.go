@@ -0,0 +1,291 @@
+package schedule
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/genius/pkg/apis/v1beta1"
+	"github.com/genius/pkg/models"
+	"github.com/genius/pkg/monitor"
+	"github.com/genius/pkg/schedule/gang"
+	"github.com/genius/pkg/schedule/reserve"
+	promconfig "github.com/prometheus/common/config"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+	"k8s.io/kubernetes/pkg/scheduler/framework"
+	"net/http"
+	"sync"
+)
+
+var (
+	cachesLock sync.Mutex
+	// caches holds the metrics caches keyed by the metrics source args, so
+	// that the scheduler profiles enabling Genius with the same metrics
+	// source share a single cache, while each of them has its own weights.
+	caches = map[string]*monitor.Cache{}
+
+	ledgerOnce sync.Once
+	// ledger holds the GPUs reserved by all the profiles enabling Genius,
+	// since they schedule the pods onto the same GPUs.
+	ledger *reserve.Ledger
+
+	registriesLock sync.Mutex
+	// registries holds the model registries keyed by the namespace and name
+	// of their ConfigMaps.
+	registries = map[string]*models.Registry{}
+)
+
+// sharedRegistry returns the model registry loaded from the ConfigMap of the
+// args, and starts watching the ConfigMap if no profile has done so. Only
+// the ConfigMap is watched, rather than all the ConfigMaps through the shared
+// informer factory of the scheduler.
+func sharedRegistry(args *v1beta1.ModelRegistryArgs, handle framework.Handle) *models.Registry {
+	key := args.Namespace + "/" + args.Name
+	registriesLock.Lock()
+	defer registriesLock.Unlock()
+	if r, ok := registries[key]; ok {
+		return r
+	}
+
+	r := models.NewRegistry()
+	load := func(obj interface{}) {
+		if cm, ok := obj.(*v1.ConfigMap); ok {
+			if err := r.Load(cm); err != nil {
+				klog.Errorf("keeping the previous GPU model aliases: %v", err)
+			}
+		}
+	}
+	factory := informers.NewSharedInformerFactoryWithOptions(handle.ClientSet(), 0,
+		informers.WithNamespace(args.Namespace),
+		informers.WithTweakListOptions(func(options *metav1.ListOptions) {
+			options.FieldSelector = fields.OneTermEqualSelector("metadata.name", args.Name).String()
+		}))
+	factory.Core().V1().ConfigMaps().Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    load,
+		UpdateFunc: func(_, obj interface{}) { load(obj) },
+		DeleteFunc: func(interface{}) {
+			klog.Infof("configmap %v is deleted, clearing the GPU model aliases", key)
+			r.Clear()
+		},
+	})
+	factory.Start(wait.NeverStop)
+	registries[key] = r
+	return r
+}
+
+// sharedLedger returns the ledger of GPU reservations, and creates it on the
+// first call. The reservation of a pod is released once the pod is deleted.
+func sharedLedger(handle framework.Handle) *reserve.Ledger {
+	ledgerOnce.Do(func() {
+		ledger = reserve.NewLedger()
+		handle.SharedInformerFactory().Core().V1().Pods().Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+			DeleteFunc: func(obj interface{}) {
+				if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+					obj = tombstone.Obj
+				}
+				if pod, ok := obj.(*v1.Pod); ok {
+					ledger.Release(pod.UID)
+				}
+			},
+		})
+	})
+	return ledger
+}
+
+// watchQueueTimes returns the queue times of the pod groups, forgetting a
+// group once all its pods are deleted.
+func watchQueueTimes(labelPrefix string, handle framework.Handle) *gang.QueueTimes {
+	queueTimes := gang.NewQueueTimes()
+	podLister := handle.SharedInformerFactory().Core().V1().Pods().Lister()
+	handle.SharedInformerFactory().Core().V1().Pods().Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		DeleteFunc: func(obj interface{}) {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			pod, ok := obj.(*v1.Pod)
+			if !ok {
+				return
+			}
+			group, err := gang.PodGroupOf(labelPrefix, pod)
+			if err != nil || group == nil {
+				return
+			}
+			if pods, err := podLister.Pods(group.Namespace).List(group.Selector(labelPrefix)); err == nil && len(pods) == 0 {
+				queueTimes.Forget(group.Key())
+			}
+		},
+	})
+	return queueTimes
+}
+
+// watchReservedPods returns the index of the pods of the pod groups which have
+// reserved their nodes, removing a pod once it is bound or deleted.
+func watchReservedPods(labelPrefix string, handle framework.Handle) *gang.ReservedPods {
+	reservedPods := gang.NewReservedPods()
+	remove := func(pod *v1.Pod) {
+		if group, err := gang.PodGroupOf(labelPrefix, pod); err == nil && group != nil {
+			reservedPods.Remove(group.Key(), pod.UID)
+		}
+	}
+	handle.SharedInformerFactory().Core().V1().Pods().Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			if pod, ok := newObj.(*v1.Pod); ok && pod.Spec.NodeName != "" {
+				remove(pod)
+			}
+		},
+		DeleteFunc: func(obj interface{}) {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			if pod, ok := obj.(*v1.Pod); ok {
+				remove(pod)
+			}
+		},
+	})
+	return reservedPods
+}
+
+// sharedCache returns the running metrics cache matching the metrics source
+// args, and creates one if there is none.
+func sharedCache(args *v1beta1.GeniusArgs, handle framework.Handle) (*monitor.Cache, error) {
+	key, err := json.Marshal(struct {
+		MetricsSource   string
+		RefreshInterval string
+		Prometheus      v1beta1.PrometheusArgs
+		Exporter        v1beta1.ExporterArgs
+		Resilience      v1beta1.ResilienceArgs
+	}{args.MetricsSource, args.RefreshInterval.Duration.String(), args.Prometheus, args.Exporter, args.Resilience})
+	if err != nil {
+		return nil, fmt.Errorf("encoding metrics source args error: %v", err)
+	}
+
+	cachesLock.Lock()
+	defer cachesLock.Unlock()
+	if c, ok := caches[string(key)]; ok {
+		return c, nil
+	}
+
+	source, err := newMetricsSource(args, handle)
+	if err != nil {
+		return nil, fmt.Errorf("creating gpu metrics source error: %v", err)
+	}
+	resilience := &args.Resilience
+	source = monitor.NewGuardedSource(source,
+		wait.Backoff{Duration: resilience.RetryBackoff.Duration, Factor: 2, Steps: *resilience.RetryAttempts},
+		monitor.NewCircuitBreaker(*resilience.FailureThreshold, resilience.OpenDuration.Duration))
+	monitor.RegisterMetrics()
+	c := monitor.NewCache(source, args.RefreshInterval.Duration, resilience.SnapshotTTL.Duration)
+	go c.Run(wait.NeverStop)
+	caches[string(key)] = c
+	return c, nil
+}
+
+// newMetricsSource returns the metrics source selected by the plugin args.
+func newMetricsSource(args *v1beta1.GeniusArgs, handle framework.Handle) (monitor.MetricsSource, error) {
+	switch args.MetricsSource {
+	case monitor.PrometheusSourceName:
+		resolver, err := newPrometheusResolver(&args.Prometheus, handle)
+		if err != nil {
+			return nil, err
+		}
+		rt, err := newPrometheusRoundTripper(&args.Prometheus)
+		if err != nil {
+			return nil, err
+		}
+		return monitor.NewPrometheusSource(resolver, rt), nil
+	case monitor.ObserverWardSourceName:
+		podLister := handle.SharedInformerFactory().Core().V1().Pods().Lister()
+		return monitor.NewObserverWardSource(podLister, exporterConfig(&args.Exporter, monitor.DefaultObserverWardConfig))
+	case monitor.DCGMSourceName:
+		podLister := handle.SharedInformerFactory().Core().V1().Pods().Lister()
+		return monitor.NewDCGMSource(podLister, exporterConfig(&args.Exporter, monitor.DefaultDCGMConfig))
+	default:
+		return nil, fmt.Errorf("unknown metrics source %q", args.MetricsSource)
+	}
+}
+
+// newPrometheusResolver returns the resolver of the Prometheus address. The
+// address in the args is used as is, otherwise the Prometheus service is
+// discovered through the scheduler's informers.
+func newPrometheusResolver(args *v1beta1.PrometheusArgs, handle framework.Handle) (monitor.EndpointResolver, error) {
+	if args.Address != "" {
+		return monitor.StaticEndpoint(args.Address), nil
+	}
+
+	serviceLister := handle.SharedInformerFactory().Core().V1().Services().Lister()
+	return monitor.NewServiceResolver(serviceLister, serviceConfig(&args.Service, monitor.DefaultPrometheusServiceConfig),
+		monitor.DefaultPromAddress())
+}
+
+// newPrometheusRoundTripper returns the round tripper applying the TLS and
+// authentication settings of Prometheus.
+func newPrometheusRoundTripper(args *v1beta1.PrometheusArgs) (http.RoundTripper, error) {
+	config := promconfig.HTTPClientConfig{
+		BearerTokenFile: args.BearerTokenFile,
+		FollowRedirects: true,
+	}
+	if args.TLS != nil {
+		config.TLSConfig = promconfig.TLSConfig{
+			CAFile:             args.TLS.CAFile,
+			CertFile:           args.TLS.CertFile,
+			KeyFile:            args.TLS.KeyFile,
+			ServerName:         args.TLS.ServerName,
+			InsecureSkipVerify: args.TLS.InsecureSkipVerify,
+		}
+	}
+	if args.BasicAuth != nil {
+		config.BasicAuth = &promconfig.BasicAuth{
+			Username:     args.BasicAuth.Username,
+			PasswordFile: args.BasicAuth.PasswordFile,
+		}
+	}
+
+	rt, err := promconfig.NewRoundTripperFromConfig(config, SchedulerName, false, false)
+	if err != nil {
+		return nil, fmt.Errorf("creating prometheus round tripper error: %v", err)
+	}
+	return rt, nil
+}
+
+// serviceConfig overrides the defaults with the fields set in the args.
+func serviceConfig(args *v1beta1.PrometheusServiceArgs, defaults monitor.ServiceConfig) monitor.ServiceConfig {
+	config := defaults
+	if args.Namespace != nil {
+		config.Namespace = *args.Namespace
+	}
+	if args.Name != "" {
+		config.Name = args.Name
+	}
+	if args.LabelSelector != "" {
+		config.LabelSelector = args.LabelSelector
+	}
+	if args.PortName != "" {
+		config.PortName = args.PortName
+	}
+	if args.Scheme != "" {
+		config.Scheme = args.Scheme
+	}
+	return config
+}
+
+// exporterConfig overrides the defaults with the fields set in the args.
+func exporterConfig(args *v1beta1.ExporterArgs, defaults monitor.ExporterConfig) monitor.ExporterConfig {
+	config := defaults
+	if args.Namespace != nil {
+		config.Namespace = *args.Namespace
+	}
+	if args.LabelSelector != "" {
+		config.LabelSelector = args.LabelSelector
+	}
+	if args.Port != 0 {
+		config.Port = args.Port
+	}
+	if args.Path != "" {
+		config.Path = args.Path
+	}
+	return config
+}
@@ -0,0 +1,212 @@
+package filter
+
+import (
+	"fmt"
+	"github.com/genius/pkg/models"
+	"github.com/genius/pkg/types"
+	"github.com/observerward/pkg/scraper"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/klog/v2"
+	"k8s.io/kubernetes/pkg/scheduler/framework"
+	"strconv"
+	"strings"
+)
+
+// The names of the pod labels specifying the compute capability and the
+// architecture, prefixed by the label prefix like the other GPU requirements.
+const (
+	GPUMinComputeCapabilityLabel = "gpu-min-compute-capability"
+	GPUArchitectureLabel         = "gpu-architecture"
+)
+
+// The architecture families of the NVIDIA GPUs.
+const (
+	KeplerArchitecture    = "kepler"
+	MaxwellArchitecture   = "maxwell"
+	PascalArchitecture    = "pascal"
+	VoltaArchitecture     = "volta"
+	TuringArchitecture    = "turing"
+	AmpereArchitecture    = "ampere"
+	AdaArchitecture       = "ada"
+	HopperArchitecture    = "hopper"
+	BlackwellArchitecture = "blackwell"
+)
+
+var (
+	validArchitectures = sets.NewString(KeplerArchitecture, MaxwellArchitecture, PascalArchitecture, VoltaArchitecture,
+		TuringArchitecture, AmpereArchitecture, AdaArchitecture, HopperArchitecture, BlackwellArchitecture)
+)
+
+// ComputeCapability is the CUDA compute capability of a GPU, such as 8.0.
+type ComputeCapability struct {
+	Major int
+	Minor int
+}
+
+// ParseComputeCapability parses a compute capability written as "8.0", "8",
+// or as the SM version of nvcc, such as "sm_80".
+func ParseComputeCapability(s string) (ComputeCapability, error) {
+	var major, minor string
+	lower := strings.ToLower(strings.TrimSpace(s))
+	if sm := strings.TrimPrefix(strings.TrimPrefix(lower, "sm"), "_"); sm != lower {
+		if len(sm) < 2 {
+			return ComputeCapability{}, fmt.Errorf("invalid compute capability %q", s)
+		}
+		major, minor = sm[:len(sm)-1], sm[len(sm)-1:]
+	} else if i := strings.Index(lower, "."); i >= 0 {
+		major, minor = lower[:i], lower[i+1:]
+	} else {
+		major, minor = lower, "0"
+	}
+
+	ma, err := strconv.ParseUint(major, 10, 8)
+	if err != nil {
+		return ComputeCapability{}, fmt.Errorf("invalid compute capability %q", s)
+	}
+	mi, err := strconv.ParseUint(minor, 10, 8)
+	if err != nil {
+		return ComputeCapability{}, fmt.Errorf("invalid compute capability %q", s)
+	}
+	return ComputeCapability{Major: int(ma), Minor: int(mi)}, nil
+}
+
+func (c ComputeCapability) String() string {
+	return fmt.Sprintf("%d.%d", c.Major, c.Minor)
+}
+
+// AtLeast judges whether the compute capability is no lower than the other.
+func (c ComputeCapability) AtLeast(other ComputeCapability) bool {
+	return c.Major > other.Major || (c.Major == other.Major && c.Minor >= other.Minor)
+}
+
+// Architecture returns the architecture family of the compute capability,
+// or "" if it is unknown.
+func (c ComputeCapability) Architecture() string {
+	switch {
+	case c.Major == 3:
+		return KeplerArchitecture
+	case c.Major == 5:
+		return MaxwellArchitecture
+	case c.Major == 6:
+		return PascalArchitecture
+	case c.Major == 7 && c.Minor < 5:
+		return VoltaArchitecture
+	case c.Major == 7:
+		return TuringArchitecture
+	case c.Major == 8 && c.Minor < 9:
+		return AmpereArchitecture
+	case c.Major == 8:
+		return AdaArchitecture
+	case c.Major == 9:
+		return HopperArchitecture
+	case c.Major == 10 || c.Major == 12:
+		return BlackwellArchitecture
+	}
+	return ""
+}
+
+// GPUCapability returns the compute capability and the architecture of the
+// GPU. They are read from the labels of the GPU if the exporter sets them,
+// or else from the metadata of the model in the registry. The architecture
+// is derived from the compute capability if neither sets it. ok is false if
+// the compute capability is unknown.
+func GPUCapability(gpu *scraper.MetricsSnapshotPerGPU, gpuLabels map[string]string, registry *models.Registry) (cc ComputeCapability, arch string, ok bool) {
+	ccValue, arch := gpuLabels[types.ComputeCapabilityLabel], gpuLabels[types.ArchitectureLabel]
+	if ccValue == "" || arch == "" {
+		if _, model, found := registry.Find(gpu.StaticAttr.Model); found {
+			if ccValue == "" {
+				ccValue = model.ComputeCapability
+			}
+			if arch == "" {
+				arch = model.Architecture
+			}
+		}
+	}
+
+	if ccValue != "" {
+		parsed, err := ParseComputeCapability(ccValue)
+		if err != nil {
+			klog.Warningf("ignoring compute capability of gpu %v (%v): %v", gpu.StaticAttr.ID, gpu.StaticAttr.Model, err)
+		} else {
+			cc, ok = parsed, true
+		}
+	}
+	if arch == "" && ok {
+		arch = cc.Architecture()
+	}
+	return cc, strings.ToLower(arch), ok
+}
+
+// GPUFitsCapability judges whether the GPU has the compute capability and the
+// architecture required. If not, the reason tells what the GPU lacks.
+func GPUFitsCapability(gpu *scraper.MetricsSnapshotPerGPU, req *GPURequirements, gpuLabels map[string]string, registry *models.Registry) (bool, string) {
+	if req.MinComputeCapability == "" && len(req.Architectures) == 0 {
+		return true, ""
+	}
+	cc, arch, known := GPUCapability(gpu, gpuLabels, registry)
+	if req.MinComputeCapability != "" {
+		// The requirement has been validated when it was read.
+		required, _ := ParseComputeCapability(req.MinComputeCapability)
+		if !known {
+			return false, fmt.Sprintf("gpu %v (%v) has an unknown compute capability", gpu.StaticAttr.ID, gpu.StaticAttr.Model)
+		}
+		if !cc.AtLeast(required) {
+			return false, fmt.Sprintf("gpu %v (%v) has compute capability %v < %v", gpu.StaticAttr.ID, gpu.StaticAttr.Model, cc, required)
+		}
+	}
+	if len(req.Architectures) > 0 {
+		if arch == "" {
+			return false, fmt.Sprintf("gpu %v (%v) has an unknown architecture", gpu.StaticAttr.ID, gpu.StaticAttr.Model)
+		}
+		for _, a := range req.Architectures {
+			if strings.EqualFold(a, arch) {
+				return true, ""
+			}
+		}
+		return false, fmt.Sprintf("gpu %v (%v) is of architecture %v", gpu.StaticAttr.ID, gpu.StaticAttr.Model, arch)
+	}
+	return true, ""
+}
+
+// PodFitsCapability judges whether there is enough number of cards with the
+// compute capability and the architecture required by the pod. If not, the
+// reason lists the cards which failed.
+func PodFitsCapability(pod *v1.Pod, req *GPURequirements, requiredNumber int, nodeInfo *framework.NodeInfo,
+	metrics *types.GPUMetricsWithProm, gpuLabels types.GPULabels, registry *models.Registry) (bool, string) {
+	if req.MinComputeCapability == "" && len(req.Architectures) == 0 {
+		klog.Infof(`pod %v passed the gpu capability filter successfully`, pod.Name)
+		return true, ""
+	}
+
+	nodename := nodeInfo.Node().Name
+	gpus := metrics.GPUs(nodename)
+	fittedCards := 0
+	var failures []string
+	for _, gpu := range gpus {
+		if fit, reason := GPUFitsCapability(gpu, req, gpuLabels[nodename][gpu.StaticAttr.ID], registry); fit {
+			fittedCards++
+		} else {
+			failures = append(failures, reason)
+		}
+	}
+	if requiredNumber == 0 {
+		requiredNumber = 1
+	}
+	if fittedCards >= requiredNumber {
+		klog.Infof(`pod %v passed the gpu capability filter successfully`, pod.Name)
+		return true, ""
+	}
+
+	var required []string
+	if req.MinComputeCapability != "" {
+		required = append(required, "compute capability >= "+req.MinComputeCapability)
+	}
+	if len(req.Architectures) > 0 {
+		required = append(required, fmt.Sprintf("architecture in %v", req.Architectures))
+	}
+	reason := fmt.Sprintf("only %v/%v gpu have %v: %v",
+		fittedCards, requiredNumber, strings.Join(required, " and "), strings.Join(failures, ", "))
+	klog.Infof(`pod %v does not pass the gpu capability filter on node %v, since %v`, pod.Name, nodename, reason)
+	return false, reason
+}
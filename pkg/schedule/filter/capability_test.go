@@ -0,0 +1,127 @@
+package filter
+
+import (
+	"github.com/genius/pkg/models"
+	"github.com/genius/pkg/types"
+	"github.com/observerward/pkg/scraper"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/kubernetes/pkg/scheduler/framework"
+	"strings"
+	"testing"
+)
+
+func TestParseComputeCapability(t *testing.T) {
+	tests := []struct {
+		value string
+		want  ComputeCapability
+		arch  string
+	}{
+		{"8.0", ComputeCapability{8, 0}, AmpereArchitecture},
+		{"7.5", ComputeCapability{7, 5}, TuringArchitecture},
+		{"9", ComputeCapability{9, 0}, HopperArchitecture},
+		{"sm_80", ComputeCapability{8, 0}, AmpereArchitecture},
+		{"SM89", ComputeCapability{8, 9}, AdaArchitecture},
+		{"sm_70", ComputeCapability{7, 0}, VoltaArchitecture},
+		{"6.1", ComputeCapability{6, 1}, PascalArchitecture},
+	}
+	for _, test := range tests {
+		t.Run(test.value, func(t *testing.T) {
+			got, err := ParseComputeCapability(test.value)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != test.want || got.Architecture() != test.arch {
+				t.Errorf("expected %v of %v, got %v of %v", test.want, test.arch, got, got.Architecture())
+			}
+		})
+	}
+
+	for _, value := range []string{"", "sm_", "8.x", "ampere", "8.0.1"} {
+		if _, err := ParseComputeCapability(value); err == nil {
+			t.Errorf("expected an error on %q", value)
+		}
+	}
+}
+
+func TestPodFitsCapability(t *testing.T) {
+	registry := models.NewRegistry()
+	err := registry.Load(&v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "kube-system", Name: "genius-gpu-models"},
+		Data: map[string]string{models.ConfigMapKey: `
+models:
+  t4:
+    names: ["Tesla T4"]
+    computeCapability: "7.5"
+  a100:
+    patterns: ["A100"]
+    computeCapability: "8.0"
+`},
+	})
+	if err != nil {
+		t.Fatalf("loading registry error: %v", err)
+	}
+
+	newModelGPU := func(id uint, model string) *scraper.MetricsSnapshotPerGPU {
+		return &scraper.MetricsSnapshotPerGPU{StaticAttr: scraper.GPUStaticAttr{ID: id, Model: model}}
+	}
+	metrics := &types.GPUMetricsWithProm{
+		"node": {GPUs: []*scraper.MetricsSnapshotPerGPU{
+			newModelGPU(0, "Tesla T4"),
+			newModelGPU(1, "NVIDIA A100-SXM4-40GB"),
+			newModelGPU(2, "NVIDIA H100 80GB HBM3"),
+			newModelGPU(3, "GeForce GTX 1080 Ti"),
+		}},
+	}
+	// The exporter labels the H100, which is not in the registry.
+	gpuLabels := types.GPULabels{}
+	gpuLabels.Set("node", 2, types.ComputeCapabilityLabel, "9.0")
+	nodeInfo := framework.NewNodeInfo()
+	nodeInfo.SetNode(&v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node"}})
+
+	tests := []struct {
+		name       string
+		req        *GPURequirements
+		number     int
+		want       bool
+		wantReason []string
+	}{
+		{"no requirement", &GPURequirements{}, 4, true, nil},
+		{"min compute capability", &GPURequirements{MinComputeCapability: "sm_80"}, 2, true, nil},
+		{
+			name:   "too few with min compute capability",
+			req:    &GPURequirements{MinComputeCapability: "8.0"},
+			number: 3,
+			wantReason: []string{
+				"only 2/3 gpu have compute capability >= 8.0",
+				"gpu 0 (Tesla T4) has compute capability 7.5 < 8.0",
+				"gpu 3 (GeForce GTX 1080 Ti) has an unknown compute capability",
+			},
+		},
+		{"architecture", &GPURequirements{Architectures: []string{"Hopper"}}, 1, true, nil},
+		{
+			name: "architecture without number",
+			req:  &GPURequirements{Architectures: []string{"volta"}},
+			wantReason: []string{
+				"only 0/1 gpu have architecture in [volta]",
+				"gpu 0 (Tesla T4) is of architecture turing",
+				"gpu 3 (GeForce GTX 1080 Ti) has an unknown architecture",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pod := newRequirementsPod(nil, nil)
+			fit, reason := PodFitsCapability(pod, test.req, test.number, nodeInfo, metrics, gpuLabels, registry)
+			if fit != test.want {
+				t.Errorf("expected %v, got %v", test.want, fit)
+			}
+			for _, want := range test.wantReason {
+				if !strings.Contains(reason, want) {
+					t.Errorf("expected reason containing %q, got %q", want, reason)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,227 @@
+package filter
+
+import (
+	"fmt"
+	"github.com/genius/pkg/types"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+	"k8s.io/kubernetes/pkg/scheduler/framework"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// GPUCUDAVersionLabel is the name of the pod label specifying the CUDA
+	// version the pod is built against, prefixed by the label prefix.
+	GPUCUDAVersionLabel = "gpu-cuda-version"
+)
+
+// The node labels of the NVIDIA GPU feature discovery. The labels of the
+// older releases are read if the newer ones are absent.
+const (
+	gfdDriverMajorLabel     = "nvidia.com/cuda.driver-version.major"
+	gfdDriverMinorLabel     = "nvidia.com/cuda.driver-version.minor"
+	gfdDriverRevisionLabel  = "nvidia.com/cuda.driver-version.revision"
+	gfdRuntimeMajorLabel    = "nvidia.com/cuda.runtime-version.major"
+	gfdRuntimeMinorLabel    = "nvidia.com/cuda.runtime-version.minor"
+	gfdOldDriverMajorLabel  = "nvidia.com/cuda.driver.major"
+	gfdOldDriverMinorLabel  = "nvidia.com/cuda.driver.minor"
+	gfdOldDriverRevLabel    = "nvidia.com/cuda.driver.rev"
+	gfdOldRuntimeMajorLabel = "nvidia.com/cuda.runtime.major"
+	gfdOldRuntimeMinorLabel = "nvidia.com/cuda.runtime.minor"
+)
+
+// cudaDrivers are the minimum Linux driver versions of the CUDA versions, in
+// the descending order, see the CUDA toolkit release notes.
+var cudaDrivers = []struct {
+	cuda   string
+	driver string
+}{
+	{"12.8", "570.26"},
+	{"12.6", "560.28.03"},
+	{"12.5", "555.42.02"},
+	{"12.4", "550.54.14"},
+	{"12.3", "545.23.06"},
+	{"12.2", "535.54.03"},
+	{"12.1", "530.30.02"},
+	{"12.0", "525.60.13"},
+	{"11.8", "520.61.05"},
+	{"11.7", "515.43.04"},
+	{"11.6", "510.39.01"},
+	{"11.5", "495.29.05"},
+	{"11.4", "470.42.01"},
+	{"11.3", "465.19.01"},
+	{"11.2", "460.27.03"},
+	{"11.1", "455.23"},
+	{"11.0", "450.36.06"},
+	{"10.2", "440.33"},
+	{"10.1", "418.39"},
+	{"10.0", "410.48"},
+}
+
+// Version is a dotted version, such as 12.2 or 535.104.05.
+type Version []int
+
+// ParseVersion parses a dotted version.
+func ParseVersion(s string) (Version, error) {
+	var v Version
+	for _, part := range strings.Split(strings.TrimSpace(s), ".") {
+		n, err := strconv.ParseUint(part, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid version %q", s)
+		}
+		v = append(v, int(n))
+	}
+	return v, nil
+}
+
+func (v Version) String() string {
+	parts := make([]string, len(v))
+	for i, n := range v {
+		parts[i] = strconv.Itoa(n)
+	}
+	return strings.Join(parts, ".")
+}
+
+// AtLeast judges whether the version is no lower than the other. The missing
+// parts are read as zero.
+func (v Version) AtLeast(other Version) bool {
+	for i := 0; i < len(v) || i < len(other); i++ {
+		var a, b int
+		if i < len(v) {
+			a = v[i]
+		}
+		if i < len(other) {
+			b = other[i]
+		}
+		if a != b {
+			return a > b
+		}
+	}
+	return true
+}
+
+// DriverInfo is the NVIDIA driver installed on a node.
+type DriverInfo struct {
+	// DriverVersion is "" if it is unknown.
+	DriverVersion string
+	// CUDAVersion is the highest CUDA version the driver supports, or nil if
+	// it is unknown.
+	CUDAVersion Version
+}
+
+// NodeDriverInfo returns the driver of the node. The versions are read from
+// the labels of the GPUs on the node if the exporter sets them, or else from
+// the node labels of the GPU feature discovery. If only the driver version is
+// known, the CUDA version is the highest one the driver supports.
+func NodeDriverInfo(node *v1.Node, gpuLabels map[uint]map[string]string) DriverInfo {
+	var info DriverInfo
+	var cuda string
+	ids := make([]uint, 0, len(gpuLabels))
+	for id := range gpuLabels {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	for _, id := range ids {
+		if info.DriverVersion == "" {
+			info.DriverVersion = gpuLabels[id][types.DriverVersionLabel]
+		}
+		if cuda == "" {
+			cuda = gpuLabels[id][types.CUDAVersionLabel]
+		}
+	}
+
+	labels := node.GetLabels()
+	if info.DriverVersion == "" {
+		info.DriverVersion = joinVersion(labels, gfdDriverMajorLabel, gfdDriverMinorLabel, gfdDriverRevisionLabel)
+	}
+	if info.DriverVersion == "" {
+		info.DriverVersion = joinVersion(labels, gfdOldDriverMajorLabel, gfdOldDriverMinorLabel, gfdOldDriverRevLabel)
+	}
+	if cuda == "" {
+		cuda = joinVersion(labels, gfdRuntimeMajorLabel, gfdRuntimeMinorLabel)
+	}
+	if cuda == "" {
+		cuda = joinVersion(labels, gfdOldRuntimeMajorLabel, gfdOldRuntimeMinorLabel)
+	}
+
+	if cuda != "" {
+		v, err := ParseVersion(cuda)
+		if err != nil {
+			klog.Warningf("ignoring CUDA version of node %v: %v", node.Name, err)
+		} else {
+			info.CUDAVersion = v
+		}
+	}
+	if info.CUDAVersion == nil && info.DriverVersion != "" {
+		info.CUDAVersion = driverCUDAVersion(info.DriverVersion)
+	}
+	return info
+}
+
+// joinVersion joins the parts of a version set in several labels, or returns
+// "" if the first part is absent.
+func joinVersion(labels map[string]string, names ...string) string {
+	var parts []string
+	for _, name := range names {
+		part, ok := labels[name]
+		if !ok {
+			break
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, ".")
+}
+
+// driverCUDAVersion returns the highest CUDA version the driver supports, or
+// nil if the driver is malformed or older than all the known CUDA versions.
+func driverCUDAVersion(driver string) Version {
+	d, err := ParseVersion(driver)
+	if err != nil {
+		klog.Warningf("ignoring driver version: %v", err)
+		return nil
+	}
+	for _, c := range cudaDrivers {
+		minDriver, _ := ParseVersion(c.driver)
+		if d.AtLeast(minDriver) {
+			v, _ := ParseVersion(c.cuda)
+			return v
+		}
+	}
+	return nil
+}
+
+// PodFitsCUDAVersion judges whether the driver of the node supports the CUDA
+// version required by the pod. If not, the reason names the version gap.
+func PodFitsCUDAVersion(pod *v1.Pod, req *GPURequirements, nodeInfo *framework.NodeInfo, gpuLabels types.GPULabels) (bool, string) {
+	if req.MinCUDAVersion == "" {
+		klog.Infof(`pod %v passed the cuda version filter successfully`, pod.Name)
+		return true, ""
+	}
+
+	// The requirement has been validated when it was read.
+	required, _ := ParseVersion(req.MinCUDAVersion)
+	node := nodeInfo.Node()
+	info := NodeDriverInfo(node, gpuLabels[node.Name])
+	if info.CUDAVersion != nil && info.CUDAVersion.AtLeast(required) {
+		klog.Infof(`pod %v passed the cuda version filter successfully`, pod.Name)
+		return true, ""
+	}
+
+	var reason string
+	switch {
+	case info.CUDAVersion == nil && info.DriverVersion == "":
+		reason = fmt.Sprintf("the driver of node %v is unknown, while the pod requires CUDA %v", node.Name, required)
+	case info.CUDAVersion == nil:
+		reason = fmt.Sprintf("the CUDA version supported by driver %v of node %v is unknown, while the pod requires CUDA %v",
+			info.DriverVersion, node.Name, required)
+	case info.DriverVersion == "":
+		reason = fmt.Sprintf("node %v supports up to CUDA %v, while the pod requires CUDA %v", node.Name, info.CUDAVersion, required)
+	default:
+		reason = fmt.Sprintf("node %v supports up to CUDA %v with driver %v, while the pod requires CUDA %v",
+			node.Name, info.CUDAVersion, info.DriverVersion, required)
+	}
+	klog.Infof(`pod %v does not pass the cuda version filter, since %v`, pod.Name, reason)
+	return false, reason
+}
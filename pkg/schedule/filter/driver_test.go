@@ -0,0 +1,112 @@
+package filter
+
+import (
+	"github.com/genius/pkg/types"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/kubernetes/pkg/scheduler/framework"
+	"strings"
+	"testing"
+)
+
+func newLabeledNode(name string, labels map[string]string) *v1.Node {
+	return &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels}}
+}
+
+func TestNodeDriverInfo(t *testing.T) {
+	tests := []struct {
+		name       string
+		node       *v1.Node
+		gpuLabels  map[uint]map[string]string
+		wantDriver string
+		wantCUDA   string
+	}{
+		{"unknown", newLabeledNode("node", nil), nil, "", ""},
+		{
+			name:       "exporter labels",
+			node:       newLabeledNode("node", map[string]string{"nvidia.com/cuda.runtime-version.major": "11", "nvidia.com/cuda.runtime-version.minor": "4"}),
+			gpuLabels:  map[uint]map[string]string{1: {types.DriverVersionLabel: "535.104.05", types.CUDAVersionLabel: "12.2"}},
+			wantDriver: "535.104.05",
+			wantCUDA:   "12.2",
+		},
+		{
+			name: "feature discovery labels",
+			node: newLabeledNode("node", map[string]string{
+				"nvidia.com/cuda.driver-version.major":    "550",
+				"nvidia.com/cuda.driver-version.minor":    "54",
+				"nvidia.com/cuda.driver-version.revision": "15",
+				"nvidia.com/cuda.runtime-version.major":   "12",
+				"nvidia.com/cuda.runtime-version.minor":   "4",
+			}),
+			wantDriver: "550.54.15",
+			wantCUDA:   "12.4",
+		},
+		{
+			name: "old feature discovery labels",
+			node: newLabeledNode("node", map[string]string{
+				"nvidia.com/cuda.driver.major":  "470",
+				"nvidia.com/cuda.driver.minor":  "82",
+				"nvidia.com/cuda.driver.rev":    "01",
+				"nvidia.com/cuda.runtime.major": "11",
+				"nvidia.com/cuda.runtime.minor": "4",
+			}),
+			wantDriver: "470.82.01",
+			wantCUDA:   "11.4",
+		},
+		{
+			name:       "cuda version of driver",
+			node:       newLabeledNode("node", nil),
+			gpuLabels:  map[uint]map[string]string{0: {}, 1: {types.DriverVersionLabel: "535.104.05"}},
+			wantDriver: "535.104.05",
+			wantCUDA:   "12.2",
+		},
+		{
+			name:       "driver older than known cuda versions",
+			node:       newLabeledNode("node", nil),
+			gpuLabels:  map[uint]map[string]string{0: {types.DriverVersionLabel: "390.12"}},
+			wantDriver: "390.12",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			info := NodeDriverInfo(test.node, test.gpuLabels)
+			if info.DriverVersion != test.wantDriver || info.CUDAVersion.String() != test.wantCUDA {
+				t.Errorf("expected driver %q and CUDA %q, got %q and %q", test.wantDriver, test.wantCUDA, info.DriverVersion, info.CUDAVersion)
+			}
+		})
+	}
+}
+
+func TestPodFitsCUDAVersion(t *testing.T) {
+	gpuLabels := types.GPULabels{}
+	gpuLabels.Set("node-a", 0, types.DriverVersionLabel, "470.82.01")
+	gpuLabels.Set("node-b", 0, types.CUDAVersionLabel, "12.4")
+
+	tests := []struct {
+		name       string
+		node       string
+		cuda       string
+		want       bool
+		wantReason string
+	}{
+		{"no requirement", "node-a", "", true, ""},
+		{"supported", "node-b", "12.2", true, ""},
+		{"same version", "node-b", "12.4", true, ""},
+		{"version gap", "node-a", "12.2", false, "node node-a supports up to CUDA 11.4 with driver 470.82.01, while the pod requires CUDA 12.2"},
+		{"version gap without driver", "node-b", "12.6", false, "node node-b supports up to CUDA 12.4, while the pod requires CUDA 12.6"},
+		{"unknown driver", "node-c", "11.0", false, "the driver of node node-c is unknown"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			nodeInfo := framework.NewNodeInfo()
+			nodeInfo.SetNode(newLabeledNode(test.node, nil))
+			req := &GPURequirements{MinCUDAVersion: test.cuda}
+			fit, reason := PodFitsCUDAVersion(newRequirementsPod(nil, nil), req, nodeInfo, gpuLabels)
+			if fit != test.want || !strings.Contains(reason, test.wantReason) {
+				t.Errorf("expected (%v, %q), got (%v, %q)", test.want, test.wantReason, fit, reason)
+			}
+		})
+	}
+}
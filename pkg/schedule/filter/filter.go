@@ -1,50 +1,77 @@
 package filter
 
 import (
+	"fmt"
+	"github.com/genius/pkg/models"
 	"github.com/genius/pkg/types"
+	"github.com/observerward/pkg/scraper"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/klog/v2"
 	"k8s.io/kubernetes/pkg/scheduler/framework"
-	"regexp"
 	"strconv"
-	"strings"
 )
 
+// The names of the pod labels specifying the GPU requirements. The labels
+// are prefixed by the label prefix set in the plugin args, "genius/" by default.
+const (
+	GPUNumberLabel      = "gpu-number"
+	GPUMemoryEachLabel  = "gpu-memory-each"
+	GPUMemoryTotalLabel = "gpu-memory-total"
+	GPUModelLabel       = "gpu-model"
+)
+
+// PodFitsGPUData judges whether there is GPU data of the node. The other
+// filters read a node without GPU data as a node without GPUs, while this
+// gives the reason of it.
+func PodFitsGPUData(pod *v1.Pod, nodeInfo *framework.NodeInfo, metrics *types.GPUMetricsWithProm) (bool, string) {
+	nodename := nodeInfo.Node().Name
+	if metrics.HasGPUData(nodename) {
+		return true, ""
+	}
+	reason := fmt.Sprintf("no GPU data of node %v, its GPU exporter may not be running", nodename)
+	klog.Infof(`pod %v does not pass the gpu data filter, since there is %v`, pod.Name, reason)
+	return false, reason
+}
+
 // PodFitsGPUNumber judges whether the number of gpus on this node satisfies
-// the required number specified in the label.
-// If there is not such an "genius/gpu-number" label while there are gpu/gpus
+// the number required by the pod.
+// If the node advertises the GPU resource, the GPUs requested by the other
+// pods on the node are not counted.
+// If the pod does not specify the number while there are gpu/gpus
 // on this node, this function returns true, otherwise false.
-func PodFitsGPUNumber(pod *v1.Pod, nodeInfo *framework.NodeInfo, metrics *types.GPUMetricsWithProm) (bool, int) {
-	gpus := (*metrics)[nodeInfo.Node().Name].GPUs
+func PodFitsGPUNumber(pod *v1.Pod, req *GPURequirements, resourceName v1.ResourceName, nodeInfo *framework.NodeInfo, metrics *types.GPUMetricsWithProm) (bool, int) {
+	gpus := metrics.GPUs(nodeInfo.Node().Name)
 	gpuNumberOnThisNode := len(gpus)
-	if number, ok := pod.GetLabels()["genius/gpu-number"]; ok {
-		nInt := str2Int(number)
-		if nInt <= gpuNumberOnThisNode {
+	if nInt, ok := req.Number(); ok {
+		available := gpuNumberOnThisNode
+		if free, ok := NodeFreeGPUs(resourceName, nodeInfo); ok && int(free) < available {
+			available = int(free)
+		}
+		if nInt <= available {
 			klog.Infof(`pod %v passed the gpu number filter successfully`, pod.Name)
 			return true, nInt
 		}
 
-		klog.Infof(`pod %v does not passed the gpu number filter, since it requires %v gpu, but there are %v on this node`,
-			pod.Name, number, gpuNumberOnThisNode)
+		klog.Infof(`pod %v does not passed the gpu number filter, since it requires %v gpu, but there are %v available on this node`,
+			pod.Name, nInt, available)
 		return false, nInt
 	}
-	klog.Infof(`pod %v does not specify the label "genius/gpu-number", skipping gpu number filter`, pod.Name)
+	klog.Infof(`pod %v does not specify the gpu number, skipping gpu number filter`, pod.Name)
 	klog.Infof(`pod %v passed the gpu number filter successfully`, pod.Name)
 	return gpuNumberOnThisNode > 0, 0
 }
 
 // PodFitsMemoryEach judges whether each GPU on this node satisfies the memory
 // requirement of the pod. However, this is a coarse-grained implementation, which
-// means that the "genius/gpu-memory-each" label specifies the memory requirement that each
-// GPU must satisfy. If any of the GPU does not have so much memory, then this
+// means that the memory-each requirement specifies the memory that each
+// GPU must satisfy. If fewer GPUs than required have so much memory, then this
 // function returns false.
-func PodFitsMemoryEach(requiredNumber int, pod *v1.Pod, nodeInfo *framework.NodeInfo, metrics *types.GPUMetricsWithProm) bool {
-	gpus := (*metrics)[nodeInfo.Node().Name].GPUs
+func PodFitsMemoryEach(pod *v1.Pod, req *GPURequirements, requiredNumber int, nodeInfo *framework.NodeInfo, metrics *types.GPUMetricsWithProm) bool {
+	gpus := metrics.GPUs(nodeInfo.Node().Name)
 	fittedCards := 0
-	if memory, ok := pod.GetLabels()["genius/gpu-memory-each"]; ok {
-		memoryInt := str2UInt64(memory)
+	if req.MemoryEach != 0 {
 		for _, gpu := range gpus {
-			if gpu.FreeGlobalMemory > memoryInt {
+			if GPUFitsMemory(gpu, req.MemoryEach) {
 				fittedCards++
 			}
 		}
@@ -54,7 +81,7 @@ func PodFitsMemoryEach(requiredNumber int, pod *v1.Pod, nodeInfo *framework.Node
 		}
 
 		klog.Infof(`pod %v does not pass the gpu memory-each filter, since it requires %v memory on each gpu, but only %v/%v gpu could satisfy`,
-			pod.Name, memory, fittedCards, requiredNumber)
+			pod.Name, req.MemoryEach, fittedCards, requiredNumber)
 		return false
 	}
 	klog.Infof(`pod %v passed the gpu memory-each filter successfully`, pod.Name)
@@ -62,58 +89,103 @@ func PodFitsMemoryEach(requiredNumber int, pod *v1.Pod, nodeInfo *framework.Node
 }
 
 // PodFitsMemoryTotal judges whether the total GPU memory on this node satisfies
-// the one required by the pod, which is specified through the "genius/gpu-memory-total" label.
+// the memory-total required by the pod.
 // It does the comparison by aggregating the free global memory of each GPU on this node.
-func PodFitsMemoryTotal(pod *v1.Pod, nodeInfo *framework.NodeInfo, metrics *types.GPUMetricsWithProm) bool {
-	gpus := (*metrics)[nodeInfo.Node().Name].GPUs
+func PodFitsMemoryTotal(pod *v1.Pod, req *GPURequirements, nodeInfo *framework.NodeInfo, metrics *types.GPUMetricsWithProm) bool {
+	gpus := metrics.GPUs(nodeInfo.Node().Name)
 	totalMemory := uint64(0)
-	if memory, ok := pod.GetLabels()["genius/gpu-memory-total"]; ok {
-		memoryInt := str2UInt64(memory)
+	if req.MemoryTotal != 0 {
 		for _, gpu := range gpus {
 			totalMemory += gpu.FreeGlobalMemory
 		}
-		if memoryInt <= totalMemory {
+		if req.MemoryTotal <= totalMemory {
 			klog.Infof("pod %v passed the gpu memory-total filter successfully", pod.Name)
 			return true
 		}
 
 		klog.Infof(`pod %v does not pass the gpu memory-total filter, since it requires total %v memory, but the actual gpu memory in total is %v on this node`,
-			pod.Name, memory, totalMemory)
+			pod.Name, req.MemoryTotal, totalMemory)
 		return false
 	}
 	klog.Infof("pod %v passed the gpu memory-total filter successfully", pod.Name)
 	return true
 }
 
-// PodFitsModel judges whether there is enough number of cards in the model satisfies the number of cards
-// required by the user in the specific same model.
-// TODO: This filter-point can be more fine-grained. Maybe to specify the number of cards in the model makes
-// more sense, but I'm not yet quite sure.
-func PodFitsModel(requiredNumber int, pod *v1.Pod, nodeInfo *framework.NodeInfo, metrics *types.GPUMetricsWithProm) bool {
-	gpus := (*metrics)[nodeInfo.Node().Name].GPUs
+// PodFitsModel judges whether there is enough number of cards of the models selected by the pod.
+// If the pod requires all its GPUs to be of the same model, the cards of a single model must be enough.
+// The model aliases are resolved by the registry, which may be nil.
+func PodFitsModel(pod *v1.Pod, req *GPURequirements, requiredNumber int, nodeInfo *framework.NodeInfo, metrics *types.GPUMetricsWithProm, registry *models.Registry) bool {
+	gpus := metrics.GPUs(nodeInfo.Node().Name)
+	if len(req.Models.Allow) == 0 && len(req.Models.Deny) == 0 && !req.Affinity.SameModel {
+		klog.Infof(`pod %v passed the gpu model filter successfully`, pod.Name)
+		return true
+	}
+
+	fittedCards := 0
+	cardsOfModel := map[string]int{}
+	for _, gpu := range gpus {
+		if GPUFitsModel(gpu, req, registry) {
+			fittedCards++
+			cardsOfModel[gpu.StaticAttr.Model]++
+		}
+	}
+	if req.Affinity.SameModel {
+		fittedCards = 0
+		for _, cards := range cardsOfModel {
+			if cards > fittedCards {
+				fittedCards = cards
+			}
+		}
+	}
+	if fittedCards >= requiredNumber {
+		klog.Infof(`pod %v passed the gpu model filter successfully`, pod.Name)
+		return true
+	}
+
+	klog.Infof(`pod %v does not pass the gpu model filter, since it requires %v gpu of models %+v, but only %v/%v gpu could satisfy`,
+		pod.Name, requiredNumber, req.Models, fittedCards, requiredNumber)
+	return false
+}
+
+// PodFitsAttributes judges whether there is enough number of cards with the
+// multiprocessors and memory bandwidth required by the pod.
+func PodFitsAttributes(pod *v1.Pod, req *GPURequirements, requiredNumber int, nodeInfo *framework.NodeInfo, metrics *types.GPUMetricsWithProm) bool {
+	gpus := metrics.GPUs(nodeInfo.Node().Name)
 	fittedCards := 0
-	if model, ok := pod.GetLabels()["genius/gpu-model"]; ok {
+	if req.MinMultiprocessors != 0 || req.MinBandwidth != 0 {
 		for _, gpu := range gpus {
-			if matchModel(model, gpu.StaticAttr.Model) {
+			if GPUFitsAttributes(gpu, req) {
 				fittedCards++
 			}
 		}
 		if fittedCards >= requiredNumber {
-			klog.Infof(`pod %v passed the gpu model filter successfully`, pod.Name)
+			klog.Infof(`pod %v passed the gpu attributes filter successfully`, pod.Name)
 			return true
 		}
 
-		klog.Infof(`pod %v does not pass the gpu model filter, since it requires %v gpu of model "%v", but only %v/%v gpu could satisfy`,
-			pod.Name, requiredNumber, model, fittedCards, requiredNumber)
+		klog.Infof(`pod %v does not pass the gpu attributes filter, since it requires %v multiprocessors and %v bandwidth on each gpu, but only %v/%v gpu could satisfy`,
+			pod.Name, req.MinMultiprocessors, req.MinBandwidth, fittedCards, requiredNumber)
 		return false
 	}
-	klog.Infof(`pod %v passed the gpu model filter successfully`, pod.Name)
+	klog.Infof(`pod %v passed the gpu attributes filter successfully`, pod.Name)
 	return true
 }
 
-func matchModel(origin, request string) bool {
-	r, _ := regexp.MatchString(strings.ToLower(origin), strings.ToLower(request))
-	return r
+// GPUFitsMemory judges whether the GPU has more free memory than the required
+// one, in MB.
+func GPUFitsMemory(gpu *scraper.MetricsSnapshotPerGPU, memory uint64) bool {
+	return gpu.FreeGlobalMemory > memory
+}
+
+// GPUFitsModel judges whether the GPU is of a model selected by the requirements.
+func GPUFitsModel(gpu *scraper.MetricsSnapshotPerGPU, req *GPURequirements, registry *models.Registry) bool {
+	return req.FitsModel(gpu.StaticAttr.Model, registry)
+}
+
+// GPUFitsAttributes judges whether the GPU has the multiprocessors and memory
+// bandwidth required.
+func GPUFitsAttributes(gpu *scraper.MetricsSnapshotPerGPU, req *GPURequirements) bool {
+	return gpu.StaticAttr.MultiprocessorCount >= req.MinMultiprocessors && gpu.StaticAttr.Bandwidth >= req.MinBandwidth
 }
 
 func str2Int(s string) int {
@@ -1,7 +1,87 @@
 package filter
 
-import "testing"
+import (
+	"github.com/genius/pkg/models"
+	"github.com/genius/pkg/types"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/kubernetes/pkg/scheduler/framework"
+	"testing"
+)
 
 func TestMatchModel(t *testing.T) {
-	println(matchModel(`.*1080\sTi`, "GeForce GTX 1080 Ti"))
+	registry := models.NewRegistry()
+	err := registry.Load(&v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "kube-system", Name: "genius-gpu-models"},
+		Data: map[string]string{models.ConfigMapKey: `
+models:
+  gtx1080ti:
+    names: ["GeForce GTX 1080 Ti"]
+  v100:
+    patterns: ["^Tesla V100"]
+`},
+	})
+	if err != nil {
+		t.Fatalf("loading registry error: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		registry *models.Registry
+		req      *GPURequirements
+		model    string
+		want     bool
+	}{
+		{"pattern", nil, &GPURequirements{Models: ModelRequirements{Allow: []string{`.*1080\sTi`}}}, "GeForce GTX 1080 Ti", true},
+		{"pattern ignoring case", nil, &GPURequirements{Models: ModelRequirements{Allow: []string{"geforce"}}}, "GeForce GTX 1080 Ti", true},
+		{"invalid pattern as substring", nil, &GPURequirements{Models: ModelRequirements{Allow: []string{"(1080"}}}, "GeForce GTX (1080) Ti", true},
+		{"invalid pattern not matched", nil, &GPURequirements{Models: ModelRequirements{Allow: []string{"(1080"}}}, "GeForce GTX 1080 Ti", false},
+		{"alias name", registry, &GPURequirements{Models: ModelRequirements{Allow: []string{"gtx1080ti"}}}, "geforce gtx 1080 ti", true},
+		{"alias name exactly", registry, &GPURequirements{Models: ModelRequirements{Allow: []string{"gtx1080ti"}}}, "GeForce GTX 1080 Ti Founders", false},
+		{"alias pattern", registry, &GPURequirements{Models: ModelRequirements{Allow: []string{"v100"}}}, "Tesla V100-SXM2-16GB", true},
+		{"denied alias", registry, &GPURequirements{Models: ModelRequirements{Deny: []string{"v100"}}}, "Tesla V100-PCIE-32GB", false},
+		{"alias without registry", nil, &GPURequirements{Models: ModelRequirements{Allow: []string{"gtx1080ti"}}}, "GeForce GTX 1080 Ti", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.req.FitsModel(test.model, test.registry); got != test.want {
+				t.Errorf("expected %v, got %v", test.want, got)
+			}
+		})
+	}
+}
+
+func TestFiltersWithoutGPUData(t *testing.T) {
+	metrics := &types.GPUMetricsWithProm{"nil-node": nil, "empty-node": {}}
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod"}}
+	count := 1
+	req := &GPURequirements{
+		Count:                &count,
+		MemoryEach:           4000,
+		MemoryTotal:          4000,
+		Models:               ModelRequirements{Allow: []string{"V100"}},
+		MinMultiprocessors:   40,
+		MinComputeCapability: "7.0",
+	}
+
+	for _, nodename := range []string{"missing-node", "nil-node", "empty-node"} {
+		t.Run(nodename, func(t *testing.T) {
+			nodeInfo := framework.NewNodeInfo()
+			nodeInfo.SetNode(&v1.Node{ObjectMeta: metav1.ObjectMeta{Name: nodename}})
+
+			if fit, reason := PodFitsGPUData(pod, nodeInfo, metrics); fit || reason == "" {
+				t.Errorf("expected the node without GPU data not to fit with a reason, got %v %q", fit, reason)
+			}
+			if fit, _ := PodFitsGPUNumber(pod, req, "nvidia.com/gpu", nodeInfo, metrics); fit {
+				t.Errorf("expected the gpu number filter to fail")
+			}
+			if PodFitsMemoryEach(pod, req, count, nodeInfo, metrics) || PodFitsMemoryTotal(pod, req, nodeInfo, metrics) ||
+				PodFitsModel(pod, req, count, nodeInfo, metrics, nil) || PodFitsAttributes(pod, req, count, nodeInfo, metrics) {
+				t.Errorf("expected the gpu filters to fail")
+			}
+			if fit, _ := PodFitsCapability(pod, req, count, nodeInfo, metrics, types.GPULabels{}, nil); fit {
+				t.Errorf("expected the gpu capability filter to fail")
+			}
+		})
+	}
 }
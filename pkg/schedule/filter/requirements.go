@@ -0,0 +1,301 @@
+package filter
+
+import (
+	"fmt"
+	"github.com/genius/pkg/apis/v1beta1"
+	"github.com/genius/pkg/models"
+	"github.com/observerward/pkg/scraper"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/klog/v2"
+	"k8s.io/kubernetes/pkg/scheduler/framework"
+	"regexp"
+	"sigs.k8s.io/yaml"
+	"strings"
+)
+
+const (
+	// GPURequirementsAnnotation is the name of the pod annotation holding the
+	// GPURequirements in JSON or YAML, prefixed by the label prefix set in the
+	// plugin args. It takes precedence over the labels of the GPU requirements.
+	GPURequirementsAnnotation = "gpu-requirements"
+)
+
+// The GPU placements of a pod.
+const (
+	// SpreadPlacement prefers the GPUs with the most free memory.
+	SpreadPlacement = "spread"
+	// PackPlacement prefers the GPUs with the least free memory that fit the
+	// pod, leaving the idle GPUs to the pods requiring more.
+	PackPlacement = "pack"
+)
+
+var (
+	validPlacements = sets.NewString(SpreadPlacement, PackPlacement)
+)
+
+// GPURequirements is the GPU requirements of a pod. The zero value of a field
+// means that it is not required.
+type GPURequirements struct {
+	// Count is the number of GPUs. If it is unset, the pod fits any node with GPUs.
+	Count *int `json:"count,omitempty"`
+	// MemoryEach is the free memory required on each GPU, in MB.
+	MemoryEach uint64 `json:"memoryEach,omitempty"`
+	// MemoryTotal is the free memory required on all the GPUs of the node, in MB.
+	MemoryTotal uint64 `json:"memoryTotal,omitempty"`
+	// Models selects the GPU models.
+	Models ModelRequirements `json:"models,omitempty"`
+	// MinMultiprocessors is the minimum number of streaming multiprocessors of each GPU.
+	MinMultiprocessors uint32 `json:"minMultiprocessors,omitempty"`
+	// MinBandwidth is the minimum memory bandwidth of each GPU.
+	MinBandwidth uint `json:"minBandwidth,omitempty"`
+	// MinComputeCapability is the minimum CUDA compute capability of each
+	// GPU, such as "8.0" or "sm_80".
+	MinComputeCapability string `json:"minComputeCapability,omitempty"`
+	// Architectures are the architecture families allowed for each GPU, such
+	// as "ampere" and "hopper".
+	Architectures []string `json:"architectures,omitempty"`
+	// MinCUDAVersion is the CUDA version the pod is built against, such as
+	// "12.2", which the driver of the node must support.
+	MinCUDAVersion string `json:"minCUDAVersion,omitempty"`
+	// Affinity places the pod among the GPUs of a node.
+	Affinity GPUAffinity `json:"affinity,omitempty"`
+}
+
+// ModelRequirements selects the GPU models by case-insensitive regular
+// expressions. A GPU fits if its model matches any of Allow, or Allow is
+// empty, and it matches none of Deny.
+type ModelRequirements struct {
+	Allow []string `json:"allow,omitempty"`
+	Deny  []string `json:"deny,omitempty"`
+}
+
+// GPUAffinity places the pod among the GPUs of a node.
+type GPUAffinity struct {
+	// Placement is either "spread" or "pack". Defaults to "spread".
+	Placement string `json:"placement,omitempty"`
+	// SameModel requires all the GPUs of the pod to be of the same model.
+	SameModel bool `json:"sameModel,omitempty"`
+}
+
+var _ framework.StateData = &GPURequirements{}
+
+// Clone implements framework.StateData, so that the requirements parsed in
+// the pre-filter phase can be kept in the cycle state.
+func (r *GPURequirements) Clone() framework.StateData {
+	c := *r
+	if r.Count != nil {
+		count := *r.Count
+		c.Count = &count
+	}
+	c.Models.Allow = append([]string(nil), r.Models.Allow...)
+	c.Models.Deny = append([]string(nil), r.Models.Deny...)
+	c.Architectures = append([]string(nil), r.Architectures...)
+	return &c
+}
+
+// Number returns the required number of GPUs, and whether it is required at all.
+func (r *GPURequirements) Number() (int, bool) {
+	if r.Count == nil {
+		return 0, false
+	}
+	return *r.Count, true
+}
+
+// PodGPURequirements reads the GPU requirements of the pod from the
+// "gpu-requirements" annotation, or from the labels of the single
+// requirements if the annotation is not set. The GPUs requested through the
+// extended resource take precedence over the count in either of them, since
+// they are what the device plugin actually allocates to the containers.
+// Malformed labels are read as zero, while a malformed annotation is an error.
+func PodGPURequirements(labelPrefix string, resourceName v1.ResourceName, pod *v1.Pod) (*GPURequirements, error) {
+	var req *GPURequirements
+	if value, ok := pod.GetAnnotations()[labelPrefix+GPURequirementsAnnotation]; ok {
+		var err error
+		req, err = ParseGPURequirements(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid annotation %v: %v", labelPrefix+GPURequirementsAnnotation, err)
+		}
+	} else {
+		req = labelGPURequirements(labelPrefix, pod)
+	}
+
+	if request := PodGPURequest(resourceName, pod); request > 0 {
+		if n, ok := req.Number(); ok && n != int(request) {
+			klog.Warningf("pod %v requests %v %v, which overrides the required GPU count %v", pod.Name, request, resourceName, n)
+		}
+		count := int(request)
+		req.Count = &count
+	}
+	return req, nil
+}
+
+// ParseGPURequirements parses and validates the requirements in JSON or YAML.
+// Unknown fields are rejected, so that typos do not go unnoticed.
+func ParseGPURequirements(value string) (*GPURequirements, error) {
+	req := &GPURequirements{}
+	if err := yaml.UnmarshalStrict([]byte(value), req); err != nil {
+		return nil, err
+	}
+	if err := validateGPURequirements(req).ToAggregate(); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+func validateGPURequirements(req *GPURequirements) field.ErrorList {
+	var allErrs field.ErrorList
+	if req.Count != nil && *req.Count < 0 {
+		allErrs = append(allErrs, field.Invalid(field.NewPath("count"), *req.Count, "must not be negative"))
+	}
+	for _, patterns := range []struct {
+		name     string
+		patterns []string
+	}{
+		{"allow", req.Models.Allow},
+		{"deny", req.Models.Deny},
+	} {
+		for i, pattern := range patterns.patterns {
+			if _, err := regexp.Compile("(?i)" + pattern); err != nil {
+				allErrs = append(allErrs, field.Invalid(field.NewPath("models", patterns.name).Index(i), pattern, err.Error()))
+			}
+		}
+	}
+	if req.MinComputeCapability != "" {
+		if _, err := ParseComputeCapability(req.MinComputeCapability); err != nil {
+			allErrs = append(allErrs, field.Invalid(field.NewPath("minComputeCapability"), req.MinComputeCapability, err.Error()))
+		}
+	}
+	if req.MinCUDAVersion != "" {
+		if _, err := ParseVersion(req.MinCUDAVersion); err != nil {
+			allErrs = append(allErrs, field.Invalid(field.NewPath("minCUDAVersion"), req.MinCUDAVersion, err.Error()))
+		}
+	}
+	for i, arch := range req.Architectures {
+		if !validArchitectures.Has(strings.ToLower(arch)) {
+			allErrs = append(allErrs, field.NotSupported(field.NewPath("architectures").Index(i), arch, validArchitectures.List()))
+		}
+	}
+	if req.Affinity.Placement != "" && !validPlacements.Has(req.Affinity.Placement) {
+		allErrs = append(allErrs, field.NotSupported(field.NewPath("affinity", "placement"), req.Affinity.Placement, validPlacements.List()))
+	}
+	return allErrs
+}
+
+// labelGPURequirements reads the requirements from the labels.
+func labelGPURequirements(labelPrefix string, pod *v1.Pod) *GPURequirements {
+	labels := pod.GetLabels()
+	req := &GPURequirements{
+		MemoryEach:  str2UInt64(labels[labelPrefix+GPUMemoryEachLabel]),
+		MemoryTotal: str2UInt64(labels[labelPrefix+GPUMemoryTotalLabel]),
+	}
+	if number, ok := labels[labelPrefix+GPUNumberLabel]; ok {
+		count := str2Int(number)
+		req.Count = &count
+	}
+	if model, ok := labels[labelPrefix+GPUModelLabel]; ok {
+		req.Models.Allow = []string{model}
+	}
+	if cc, ok := labels[labelPrefix+GPUMinComputeCapabilityLabel]; ok {
+		if _, err := ParseComputeCapability(cc); err != nil {
+			klog.Warningf("ignoring label %v of pod %v: %v", labelPrefix+GPUMinComputeCapabilityLabel, pod.Name, err)
+		} else {
+			req.MinComputeCapability = cc
+		}
+	}
+	if arch, ok := labels[labelPrefix+GPUArchitectureLabel]; ok {
+		req.Architectures = []string{arch}
+	}
+	if cuda, ok := labels[labelPrefix+GPUCUDAVersionLabel]; ok {
+		if _, err := ParseVersion(cuda); err != nil {
+			klog.Warningf("ignoring label %v of pod %v: %v", labelPrefix+GPUCUDAVersionLabel, pod.Name, err)
+		} else {
+			req.MinCUDAVersion = cuda
+		}
+	}
+	return req
+}
+
+// RequestsGPUs judges whether the pod needs any GPU. A pod without a count
+// needs one if it has any other GPU requirement, while a count of 0 means
+// that it needs none regardless of the other requirements.
+func (r *GPURequirements) RequestsGPUs() bool {
+	if n, ok := r.Number(); ok {
+		return n > 0
+	}
+	return len(r.MetricsRequirements()) > 0 || r.MinCUDAVersion != "" || r.Affinity.SameModel
+}
+
+// MetricsRequirements returns the names of the requirements which can only
+// be checked against the GPU metrics, such as the free memory and the model.
+func (r *GPURequirements) MetricsRequirements() []string {
+	var names []string
+	for _, req := range []struct {
+		name string
+		set  bool
+	}{
+		{"memoryEach", r.MemoryEach != 0},
+		{"memoryTotal", r.MemoryTotal != 0},
+		{"models", len(r.Models.Allow) > 0 || len(r.Models.Deny) > 0},
+		{"minMultiprocessors", r.MinMultiprocessors != 0},
+		{"minBandwidth", r.MinBandwidth != 0},
+		{"minComputeCapability", r.MinComputeCapability != ""},
+		{"architectures", len(r.Architectures) > 0},
+	} {
+		if req.set {
+			names = append(names, req.name)
+		}
+	}
+	return names
+}
+
+// FitsModel judges whether the model is selected by the requirements. Each
+// selector is either an alias of the registry or a pattern, see
+// models.Registry.Match.
+func (r *GPURequirements) FitsModel(model string, registry *models.Registry) bool {
+	if len(r.Models.Allow) > 0 {
+		allowed := false
+		for _, pattern := range r.Models.Allow {
+			if registry.Match(pattern, model) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return false
+		}
+	}
+	for _, pattern := range r.Models.Deny {
+		if registry.Match(pattern, model) {
+			return false
+		}
+	}
+	return true
+}
+
+// GPUFits judges whether a single GPU satisfies all the per-GPU requirements
+// checked by the enabled filters, namely the memory-each, the models, the
+// attributes, and the compute capability and architecture, given the labels
+// of the GPU.
+func GPUFits(gpu *scraper.MetricsSnapshotPerGPU, req *GPURequirements, gpuLabels map[string]string, registry *models.Registry,
+	enabled v1beta1.FilterArgs) bool {
+	if Enabled(enabled.MemoryEach) && req.MemoryEach != 0 && !GPUFitsMemory(gpu, req.MemoryEach) {
+		return false
+	}
+	if Enabled(enabled.Capability) {
+		if fit, _ := GPUFitsCapability(gpu, req, gpuLabels, registry); !fit {
+			return false
+		}
+	}
+	if Enabled(enabled.Model) && !GPUFitsModel(gpu, req, registry) {
+		return false
+	}
+	return !Enabled(enabled.Attributes) || GPUFitsAttributes(gpu, req)
+}
+
+// Enabled judges whether a filter in the plugin args is enabled, where an
+// unset filter is enabled as by default.
+func Enabled(filter *bool) bool {
+	return filter == nil || *filter
+}
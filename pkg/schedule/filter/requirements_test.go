@@ -0,0 +1,188 @@
+package filter
+
+import (
+	"github.com/genius/pkg/types"
+	"github.com/observerward/pkg/scraper"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/kubernetes/pkg/scheduler/framework"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func newRequirementsPod(labels, annotations map[string]string) *v1.Pod {
+	return &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod", Labels: labels, Annotations: annotations}}
+}
+
+func TestPodGPURequirements(t *testing.T) {
+	two := 2
+	tests := []struct {
+		name string
+		pod  *v1.Pod
+		want *GPURequirements
+	}{
+		{
+			name: "labels",
+			pod: newRequirementsPod(map[string]string{
+				"genius/gpu-number":       "2",
+				"genius/gpu-memory-each":  "4000",
+				"genius/gpu-memory-total": "9000",
+				"genius/gpu-model":        "t4",
+			}, nil),
+			want: &GPURequirements{Count: &two, MemoryEach: 4000, MemoryTotal: 9000, Models: ModelRequirements{Allow: []string{"t4"}}},
+		},
+		{
+			name: "json annotation",
+			pod: newRequirementsPod(nil, map[string]string{
+				"genius/gpu-requirements": `{"count": 2, "models": {"allow": ["Tesla V100-SXM2-16GB"], "deny": ["GeForce.*"]}, "minBandwidth": 300}`,
+			}),
+			want: &GPURequirements{
+				Count:        &two,
+				Models:       ModelRequirements{Allow: []string{"Tesla V100-SXM2-16GB"}, Deny: []string{"GeForce.*"}},
+				MinBandwidth: 300,
+			},
+		},
+		{
+			name: "yaml annotation over labels",
+			pod: newRequirementsPod(map[string]string{"genius/gpu-number": "1"}, map[string]string{
+				"genius/gpu-requirements": "count: 2\nmemoryEach: 8000\nminMultiprocessors: 40\naffinity:\n  placement: pack\n  sameModel: true\n",
+			}),
+			want: &GPURequirements{
+				Count:              &two,
+				MemoryEach:         8000,
+				MinMultiprocessors: 40,
+				Affinity:           GPUAffinity{Placement: PackPlacement, SameModel: true},
+			},
+		},
+		{
+			name: "capability labels",
+			pod: newRequirementsPod(map[string]string{
+				"genius/gpu-min-compute-capability": "8.0",
+				"genius/gpu-architecture":           "ampere",
+				"genius/gpu-cuda-version":           "12.2",
+			}, nil),
+			want: &GPURequirements{MinComputeCapability: "8.0", Architectures: []string{"ampere"}, MinCUDAVersion: "12.2"},
+		},
+		{
+			name: "capability annotation",
+			pod: newRequirementsPod(nil, map[string]string{
+				"genius/gpu-requirements": `{"minComputeCapability": "sm_80", "architectures": ["ampere", "hopper"]}`,
+			}),
+			want: &GPURequirements{MinComputeCapability: "sm_80", Architectures: []string{"ampere", "hopper"}},
+		},
+		{
+			name: "resource request over annotation",
+			pod: func() *v1.Pod {
+				pod := newRequirementsPod(nil, map[string]string{"genius/gpu-requirements": `{"count": 1}`})
+				pod.Spec.Containers = []v1.Container{newGPUContainer(2, 2)}
+				return pod
+			}(),
+			want: &GPURequirements{Count: &two},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := PodGPURequirements("genius/", gpuResource, test.pod)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("expected %+v, got %+v", test.want, got)
+			}
+		})
+	}
+}
+
+func TestPodGPURequirementsError(t *testing.T) {
+	tests := []struct {
+		name       string
+		annotation string
+		wantErr    string
+	}{
+		{"malformed", `{"count": 2`, "invalid annotation genius/gpu-requirements"},
+		{"unknown field", `{"cout": 2}`, `unknown field "cout"`},
+		{"wrong type", `{"memoryEach": "8GB"}`, "memoryEach"},
+		{"negative count", `{"count": -1}`, "count: Invalid value: -1"},
+		{"bad pattern", `{"models": {"deny": ["ok", "(t4"]}}`, "models.deny[1]"},
+		{"bad placement", `{"affinity": {"placement": "scatter"}}`, "affinity.placement"},
+		{"bad compute capability", `{"minComputeCapability": "eight"}`, "minComputeCapability"},
+		{"bad cuda version", `{"minCUDAVersion": "12.x"}`, "minCUDAVersion"},
+		{"unknown architecture", `{"architectures": ["ampere", "fermi2"]}`, "architectures[1]"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pod := newRequirementsPod(nil, map[string]string{"genius/gpu-requirements": test.annotation})
+			_, err := PodGPURequirements("genius/", gpuResource, pod)
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("expected error containing %q, got %v", test.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestRequestsGPUs(t *testing.T) {
+	tests := []struct {
+		name string
+		pod  *v1.Pod
+		want bool
+	}{
+		{"no requirement", newRequirementsPod(nil, nil), false},
+		{"zero count", newRequirementsPod(map[string]string{"genius/gpu-number": "0", "genius/gpu-memory-each": "4000"}, nil), false},
+		{"count", newRequirementsPod(map[string]string{"genius/gpu-number": "1"}, nil), true},
+		{"memory without count", newRequirementsPod(map[string]string{"genius/gpu-memory-each": "4000"}, nil), true},
+		{"cuda version without count", newRequirementsPod(nil, map[string]string{"genius/gpu-requirements": `{"minCUDAVersion": "12.2"}`}), true},
+		{"resource", newGPUPod(nil, []v1.Container{newGPUContainer(1, 1)}), true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req, err := PodGPURequirements("genius/", gpuResource, test.pod)
+			if err != nil {
+				t.Fatalf("reading gpu requirements error: %v", err)
+			}
+			if got := req.RequestsGPUs(); got != test.want {
+				t.Errorf("expected %v, got %v", test.want, got)
+			}
+		})
+	}
+}
+
+func TestPodFitsModel(t *testing.T) {
+	newModelGPU := func(model string) *scraper.MetricsSnapshotPerGPU {
+		return &scraper.MetricsSnapshotPerGPU{StaticAttr: scraper.GPUStaticAttr{Model: model}}
+	}
+	metrics := &types.GPUMetricsWithProm{
+		"node": {GPUs: []*scraper.MetricsSnapshotPerGPU{
+			newModelGPU("Tesla T4"),
+			newModelGPU("Tesla V100-SXM2-16GB"),
+			newModelGPU("GeForce GTX 1080 Ti"),
+		}},
+	}
+	nodeInfo := framework.NewNodeInfo()
+	nodeInfo.SetNode(&v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node"}})
+
+	tests := []struct {
+		name   string
+		req    *GPURequirements
+		number int
+		want   bool
+	}{
+		{"no requirement", &GPURequirements{}, 3, true},
+		{"allow", &GPURequirements{Models: ModelRequirements{Allow: []string{"tesla"}}}, 2, true},
+		{"allow too few", &GPURequirements{Models: ModelRequirements{Allow: []string{"tesla"}}}, 3, false},
+		{"deny", &GPURequirements{Models: ModelRequirements{Deny: []string{"geforce"}}}, 3, false},
+		{"allow and deny", &GPURequirements{Models: ModelRequirements{Allow: []string{"tesla"}, Deny: []string{"v100"}}}, 1, true},
+		{"same model", &GPURequirements{Affinity: GPUAffinity{SameModel: true}}, 2, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pod := newRequirementsPod(nil, nil)
+			if got := PodFitsModel(pod, test.req, test.number, nodeInfo, metrics, nil); got != test.want {
+				t.Errorf("expected %v, got %v", test.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package filter
+
+import (
+	"fmt"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+	"k8s.io/kubernetes/pkg/scheduler/framework"
+)
+
+// PodGPURequest returns the number of GPUs the pod requests through the
+// extended resource. Like the other resources, the init containers run one
+// after another before the containers, which run side by side, so the pod
+// requests the maximum of the largest init container request and the sum
+// of the container requests.
+func PodGPURequest(resourceName v1.ResourceName, pod *v1.Pod) int64 {
+	sum := int64(0)
+	for i := range pod.Spec.Containers {
+		sum += containerGPURequest(resourceName, &pod.Spec.Containers[i])
+	}
+	for i := range pod.Spec.InitContainers {
+		if n := containerGPURequest(resourceName, &pod.Spec.InitContainers[i]); n > sum {
+			sum = n
+		}
+	}
+	return sum
+}
+
+// containerGPURequest returns the GPUs requested by the container. The
+// requests of extended resources must equal the limits, and they default to
+// the limits, so the limits are read if the requests are unset.
+func containerGPURequest(resourceName v1.ResourceName, c *v1.Container) int64 {
+	if q, ok := c.Resources.Requests[resourceName]; ok {
+		return q.Value()
+	}
+	if q, ok := c.Resources.Limits[resourceName]; ok {
+		return q.Value()
+	}
+	return 0
+}
+
+// NodeFreeGPUs returns the number of GPUs on the node which have not been
+// requested by the pods through the extended resource. The second return
+// value is false if the node does not advertise the resource, such as when
+// no device plugin is deployed.
+func NodeFreeGPUs(resourceName v1.ResourceName, nodeInfo *framework.NodeInfo) (int64, bool) {
+	allocatable, ok := nodeInfo.Allocatable.ScalarResources[resourceName]
+	if !ok {
+		return 0, false
+	}
+	free := allocatable - nodeInfo.Requested.ScalarResources[resourceName]
+	if free < 0 {
+		free = 0
+	}
+	return free, true
+}
+
+// PodFitsAllocatableGPUs judges whether the node has enough free GPUs by the
+// extended resource alone, for when the GPU metrics are unavailable. A pod
+// requiring no number of GPUs needs one, and a node not advertising the
+// resource does not fit.
+func PodFitsAllocatableGPUs(pod *v1.Pod, req *GPURequirements, resourceName v1.ResourceName, nodeInfo *framework.NodeInfo) (bool, string) {
+	required, ok := req.Number()
+	if !ok || required == 0 {
+		required = 1
+	}
+	free, ok := NodeFreeGPUs(resourceName, nodeInfo)
+	if !ok {
+		return false, fmt.Sprintf("node %v does not advertise %v", nodeInfo.Node().Name, resourceName)
+	}
+	if int64(required) > free {
+		return false, fmt.Sprintf("node %v has %v free %v, while the pod requires %v", nodeInfo.Node().Name, free, resourceName, required)
+	}
+	klog.Infof(`pod %v passed the allocatable gpu filter successfully`, pod.Name)
+	return true, ""
+}
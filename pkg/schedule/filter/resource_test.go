@@ -0,0 +1,132 @@
+package filter
+
+import (
+	"github.com/genius/pkg/types"
+	"github.com/observerward/pkg/scraper"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/kubernetes/pkg/scheduler/framework"
+	"testing"
+)
+
+const gpuResource = v1.ResourceName("nvidia.com/gpu")
+
+func newGPUContainer(requests, limits int64) v1.Container {
+	c := v1.Container{Resources: v1.ResourceRequirements{
+		Requests: v1.ResourceList{},
+		Limits:   v1.ResourceList{},
+	}}
+	if requests > 0 {
+		c.Resources.Requests[gpuResource] = *resource.NewQuantity(requests, resource.DecimalSI)
+	}
+	if limits > 0 {
+		c.Resources.Limits[gpuResource] = *resource.NewQuantity(limits, resource.DecimalSI)
+	}
+	return c
+}
+
+func newGPUPod(labels map[string]string, containers []v1.Container, initContainers ...v1.Container) *v1.Pod {
+	return &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "pod", Labels: labels},
+		Spec:       v1.PodSpec{Containers: containers, InitContainers: initContainers},
+	}
+}
+
+func TestPodGPURequest(t *testing.T) {
+	tests := []struct {
+		name string
+		pod  *v1.Pod
+		want int64
+	}{
+		{"no request", newGPUPod(nil, []v1.Container{{}}), 0},
+		{"requests", newGPUPod(nil, []v1.Container{newGPUContainer(1, 1), newGPUContainer(2, 2)}), 3},
+		{"limits only", newGPUPod(nil, []v1.Container{newGPUContainer(0, 2)}), 2},
+		{"smaller init container", newGPUPod(nil, []v1.Container{newGPUContainer(1, 1), newGPUContainer(1, 1)}, newGPUContainer(1, 1)), 2},
+		{"larger init container", newGPUPod(nil, []v1.Container{newGPUContainer(1, 1)}, newGPUContainer(4, 4), newGPUContainer(2, 2)), 4},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := PodGPURequest(gpuResource, test.pod); got != test.want {
+				t.Errorf("expected %v, got %v", test.want, got)
+			}
+		})
+	}
+}
+
+func TestPodFitsGPUNumberWithResource(t *testing.T) {
+	metrics := &types.GPUMetricsWithProm{
+		"node": {GPUs: []*scraper.MetricsSnapshotPerGPU{{}, {}, {}, {}}},
+	}
+	// 1 of the 4 GPUs advertised by the device plugin is requested by a running pod.
+	running := newGPUPod(nil, []v1.Container{newGPUContainer(1, 1)})
+	nodeInfo := framework.NewNodeInfo(running)
+	nodeInfo.SetNode(&v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node"},
+		Status: v1.NodeStatus{Allocatable: v1.ResourceList{
+			gpuResource: *resource.NewQuantity(4, resource.DecimalSI),
+		}},
+	})
+
+	tests := []struct {
+		name       string
+		pod        *v1.Pod
+		wantFit    bool
+		wantNumber int
+	}{
+		{"no requirement", newGPUPod(nil, []v1.Container{{}}), true, 0},
+		{"label", newGPUPod(map[string]string{"genius/gpu-number": "3"}, []v1.Container{{}}), true, 3},
+		{"label beyond free GPUs", newGPUPod(map[string]string{"genius/gpu-number": "4"}, []v1.Container{{}}), false, 4},
+		{"resource", newGPUPod(nil, []v1.Container{newGPUContainer(2, 2)}), true, 2},
+		{"resource beyond free GPUs", newGPUPod(nil, []v1.Container{newGPUContainer(4, 4)}), false, 4},
+		{"resource overrides label", newGPUPod(map[string]string{"genius/gpu-number": "4"}, []v1.Container{newGPUContainer(1, 1)}), true, 1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req, err := PodGPURequirements("genius/", gpuResource, test.pod)
+			if err != nil {
+				t.Fatalf("reading gpu requirements error: %v", err)
+			}
+			fit, number := PodFitsGPUNumber(test.pod, req, gpuResource, nodeInfo, metrics)
+			if fit != test.wantFit || number != test.wantNumber {
+				t.Errorf("expected (%v, %v), got (%v, %v)", test.wantFit, test.wantNumber, fit, number)
+			}
+		})
+	}
+}
+
+func TestPodFitsAllocatableGPUs(t *testing.T) {
+	running := newGPUPod(nil, []v1.Container{newGPUContainer(3, 3)})
+	nodeInfo := framework.NewNodeInfo(running)
+	nodeInfo.SetNode(&v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node"},
+		Status: v1.NodeStatus{Allocatable: v1.ResourceList{
+			gpuResource: *resource.NewQuantity(4, resource.DecimalSI),
+		}},
+	})
+	cpuNode := framework.NewNodeInfo()
+	cpuNode.SetNode(&v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "cpu-node"}})
+
+	tests := []struct {
+		name     string
+		pod      *v1.Pod
+		nodeInfo *framework.NodeInfo
+		want     bool
+	}{
+		{"no requirement", newGPUPod(nil, []v1.Container{{}}), nodeInfo, true},
+		{"free gpu", newGPUPod(nil, []v1.Container{newGPUContainer(1, 1)}), nodeInfo, true},
+		{"beyond free gpus", newGPUPod(nil, []v1.Container{newGPUContainer(2, 2)}), nodeInfo, false},
+		{"no resource", newGPUPod(nil, []v1.Container{{}}), cpuNode, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req, err := PodGPURequirements("genius/", gpuResource, test.pod)
+			if err != nil {
+				t.Fatalf("reading gpu requirements error: %v", err)
+			}
+			if fit, reason := PodFitsAllocatableGPUs(test.pod, req, gpuResource, test.nodeInfo); fit != test.want {
+				t.Errorf("expected %v, got %v (%v)", test.want, fit, reason)
+			}
+		})
+	}
+}
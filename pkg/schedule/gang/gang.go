@@ -0,0 +1,152 @@
+package gang
+
+import (
+	"fmt"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// PodGroupLabel is the name of the pod label naming the pod group,
+	// prefixed by the label prefix set in the plugin args. A group is made of
+	// the pods labeled with the same name in a namespace.
+	PodGroupLabel = "pod-group"
+	// MinAvailableLabel is the name of the pod label holding the number of the
+	// pods of the group which must be scheduled together, prefixed by the
+	// label prefix set in the plugin args.
+	MinAvailableLabel = "min-available"
+)
+
+// PodGroup is a group of pods which are scheduled all together, or none of
+// them is.
+type PodGroup struct {
+	Namespace    string
+	Name         string
+	MinAvailable int
+}
+
+// PodGroupOf returns the group of the pod, or nil if the pod is in no group.
+// An error is returned if the min-available of the group is missing or not
+// a positive number.
+func PodGroupOf(labelPrefix string, pod *v1.Pod) (*PodGroup, error) {
+	name, ok := pod.Labels[labelPrefix+PodGroupLabel]
+	if !ok || name == "" {
+		return nil, nil
+	}
+	value, ok := pod.Labels[labelPrefix+MinAvailableLabel]
+	if !ok {
+		return nil, fmt.Errorf("label %v is required by pod group %v", labelPrefix+MinAvailableLabel, name)
+	}
+	minAvailable, err := strconv.Atoi(value)
+	if err != nil || minAvailable < 1 {
+		return nil, fmt.Errorf("invalid label %v %q of pod group %v: must be a positive number", labelPrefix+MinAvailableLabel, value, name)
+	}
+	return &PodGroup{Namespace: pod.Namespace, Name: name, MinAvailable: minAvailable}, nil
+}
+
+// Key returns the namespaced name of the group.
+func (g *PodGroup) Key() string {
+	return g.Namespace + "/" + g.Name
+}
+
+// Selector selects the pods of the group in its namespace.
+func (g *PodGroup) Selector(labelPrefix string) labels.Selector {
+	return labels.SelectorFromSet(labels.Set{labelPrefix + PodGroupLabel: g.Name})
+}
+
+// Contains judges whether the pod is in the group.
+func (g *PodGroup) Contains(labelPrefix string, pod *v1.Pod) bool {
+	return pod.Namespace == g.Namespace && pod.Labels[labelPrefix+PodGroupLabel] == g.Name
+}
+
+// QueueTimes records the time each pod group is first queued, so that all the
+// pods of a group are ordered by the same time in the scheduling queue. The
+// time of a group is recorded once, by the first of its pods ordered, and
+// kept until the group is forgotten, so that the order of the pods already
+// queued never changes.
+type QueueTimes struct {
+	lock   sync.Mutex
+	groups map[string]time.Time
+}
+
+// NewQueueTimes returns the queue times without any group.
+func NewQueueTimes() *QueueTimes {
+	return &QueueTimes{groups: map[string]time.Time{}}
+}
+
+// Record records the time one of the pods of the group is queued, unless the
+// time of the group is recorded, and returns the time of the group.
+func (q *QueueTimes) Record(key string, queuedAt time.Time) time.Time {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	t, ok := q.groups[key]
+	if !ok {
+		t = queuedAt
+		q.groups[key] = t
+	}
+	return t
+}
+
+// Forget forgets the group, such as when all its pods are deleted.
+func (q *QueueTimes) Forget(key string) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	delete(q.groups, key)
+}
+
+// ReservedPods indexes the pods of each pod group which have reserved their
+// nodes and are not bound yet, namely the pods assumed or waiting in the
+// permit phase.
+type ReservedPods struct {
+	lock   sync.RWMutex
+	groups map[string]map[k8stypes.UID]struct{}
+}
+
+// NewReservedPods returns the index without any pod.
+func NewReservedPods() *ReservedPods {
+	return &ReservedPods{groups: map[string]map[k8stypes.UID]struct{}{}}
+}
+
+// Add adds the pod to the group.
+func (r *ReservedPods) Add(key string, uid k8stypes.UID) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	pods, ok := r.groups[key]
+	if !ok {
+		pods = map[k8stypes.UID]struct{}{}
+		r.groups[key] = pods
+	}
+	pods[uid] = struct{}{}
+}
+
+// Remove removes the pod from the group, such as when it fails to be bound,
+// is bound, or is deleted.
+func (r *ReservedPods) Remove(key string, uid k8stypes.UID) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if pods, ok := r.groups[key]; ok {
+		delete(pods, uid)
+		if len(pods) == 0 {
+			delete(r.groups, key)
+		}
+	}
+}
+
+// Contains judges whether the pod of the group is in the index.
+func (r *ReservedPods) Contains(key string, uid k8stypes.UID) bool {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	_, ok := r.groups[key][uid]
+	return ok
+}
+
+// Count returns the number of the pods of the group.
+func (r *ReservedPods) Count(key string) int {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	return len(r.groups[key])
+}
@@ -0,0 +1,86 @@
+package gang
+
+import (
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestPodGroupOf(t *testing.T) {
+	tests := []struct {
+		name    string
+		labels  map[string]string
+		want    *PodGroup
+		wantErr bool
+	}{
+		{"no group", map[string]string{}, nil, false},
+		{"group", map[string]string{"genius/pod-group": "job", "genius/min-available": "4"},
+			&PodGroup{Namespace: "default", Name: "job", MinAvailable: 4}, false},
+		{"missing min-available", map[string]string{"genius/pod-group": "job"}, nil, true},
+		{"zero min-available", map[string]string{"genius/pod-group": "job", "genius/min-available": "0"}, nil, true},
+		{"malformed min-available", map[string]string{"genius/pod-group": "job", "genius/min-available": "four"}, nil, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod", Namespace: "default", Labels: test.labels}}
+			got, err := PodGroupOf("genius/", pod)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("expected error %v, got %v", test.wantErr, err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("expected %+v, got %+v", test.want, got)
+			}
+		})
+	}
+
+	group := &PodGroup{Namespace: "default", Name: "job", MinAvailable: 2}
+	other := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "other", Labels: map[string]string{"genius/pod-group": "job"}}}
+	if group.Contains("genius/", other) {
+		t.Errorf("expected a pod of another namespace not to be in the group")
+	}
+}
+
+func TestQueueTimes(t *testing.T) {
+	now := time.Now()
+	q := NewQueueTimes()
+	if got := q.Record("default/job", now); !got.Equal(now) {
+		t.Errorf("expected the time the first pod is queued, got %v", got)
+	}
+	if got := q.Record("default/job", now.Add(-time.Second)); !got.Equal(now) {
+		t.Errorf("expected the time of the group not to change, got %v", got)
+	}
+
+	q.Forget("default/job")
+	later := now.Add(time.Minute)
+	if got := q.Record("default/job", later); !got.Equal(later) {
+		t.Errorf("expected the forgotten group to be recorded again, got %v", got)
+	}
+}
+
+func TestReservedPods(t *testing.T) {
+	r := NewReservedPods()
+	r.Add("default/job", "worker-0")
+	r.Add("default/job", "worker-0")
+	r.Add("default/job", "worker-1")
+	r.Add("default/other", "other-0")
+	if got := r.Count("default/job"); got != 2 {
+		t.Errorf("expected 2 pods of the group, got %v", got)
+	}
+	if !r.Contains("default/job", "worker-1") || r.Contains("default/job", "other-0") {
+		t.Errorf("expected the index to contain only the pods added to the group")
+	}
+
+	r.Remove("default/job", "worker-0")
+	r.Remove("default/job", "worker-1")
+	if got := r.Count("default/job"); got != 0 {
+		t.Errorf("expected no pod of the group, got %v", got)
+	}
+	if _, ok := r.groups["default/job"]; ok {
+		t.Errorf("expected the empty group to be removed")
+	}
+	if got := r.Count("default/other"); got != 1 {
+		t.Errorf("expected the other group to be kept, got %v", got)
+	}
+}
@@ -2,16 +2,26 @@ package schedule
 
 import (
 	"context"
+	"fmt"
+	"github.com/genius/pkg/apis/v1beta1"
+	"github.com/genius/pkg/models"
 	"github.com/genius/pkg/monitor"
 	"github.com/genius/pkg/schedule/filter"
+	"github.com/genius/pkg/schedule/gang"
+	"github.com/genius/pkg/schedule/reserve"
 	"github.com/genius/pkg/schedule/score"
 	"github.com/genius/pkg/schedule/sort"
 	"github.com/genius/pkg/types"
+	"github.com/observerward/pkg/scraper"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	policylisters "k8s.io/client-go/listers/policy/v1beta1"
 	"k8s.io/klog/v2"
 	"k8s.io/kubernetes/pkg/scheduler/framework"
+	"strings"
 	"sync"
+	"time"
 )
 
 const (
@@ -19,65 +29,339 @@ const (
 )
 
 const (
-	metricsKey = "metrics"
+	metricsKey      = "metrics"
+	rawMetricsKey   = "raw-metrics"
+	weightsKey      = "weights"
+	requirementsKey = "requirements"
+	reservationKey  = "reservation"
+	gpuLabelsKey    = "gpu-labels"
+	healthKey       = "health"
+	staleKey        = "stale"
+	modeKey         = "mode"
+	skipKey         = "skip"
 )
 
+// stalePolicyActions describes what is done to the stale nodes under each
+// policy, in the logs and events.
+var stalePolicyActions = map[string]string{
+	v1beta1.StalePolicyUnschedulable: "filtered out",
+	v1beta1.StalePolicyStaticOnly:    "scored by the static GPU attributes only",
+	v1beta1.StalePolicyIgnore:        "scheduled as usual",
+}
+
 var (
-	_ framework.QueueSortPlugin = &Genius{}
-	_ framework.PreFilterPlugin = &Genius{}
-	_ framework.FilterPlugin    = &Genius{}
-	_ framework.ScorePlugin     = &Genius{}
+	_ framework.QueueSortPlugin     = &Genius{}
+	_ framework.PreFilterPlugin     = &Genius{}
+	_ framework.PreFilterExtensions = &Genius{}
+	_ framework.FilterPlugin        = &Genius{}
+	_ framework.PostFilterPlugin    = &Genius{}
+	_ framework.ScorePlugin         = &Genius{}
+	_ framework.ScoreExtensions     = &Genius{}
+	_ framework.ReservePlugin       = &Genius{}
+	_ framework.PermitPlugin        = &Genius{}
+	_ framework.PreBindPlugin       = &Genius{}
 )
 
+// skipState marks the pods requesting no GPUs in the cycle state, which
+// Genius neither filters nor reserves GPUs for.
+type skipState struct{}
+
+func (s skipState) Clone() framework.StateData {
+	return s
+}
+
+// rawMetrics holds the GPU metrics of the snapshot before the unhealthy and
+// the reserved GPUs are left out, which tells a node without GPU data from a
+// node whose GPUs are all unusable for now. The snapshot is shared by the
+// scheduling cycles and never modified, so it is not copied.
+type rawMetrics struct {
+	*types.GPUMetricsWithProm
+}
+
+func (m rawMetrics) Clone() framework.StateData {
+	return m
+}
+
 type Genius struct {
-	handle  framework.Handle
-	monitor *monitor.Monitor
+	handle       framework.Handle
+	args         *v1beta1.GeniusArgs
+	weights      score.Weights
+	weightBounds score.Bounds
+	cache        *monitor.Cache
+	ledger       *reserve.Ledger
+	models       *models.Registry
+	health       *monitor.HealthThresholds
+	podLister    corelisters.PodLister
+	pdbLister    policylisters.PodDisruptionBudgetLister
+	queueTimes   *gang.QueueTimes
+	reservedPods *gang.ReservedPods
 	sync.RWMutex
 }
 
 func New(obj runtime.Object, handle framework.Handle) (framework.Plugin, error) {
-	m, err := monitor.NewMonitor(monitor.Scheme, monitor.PromHost, monitor.PromPort)
+	args, err := v1beta1.DecodeGeniusArgs(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	cache, err := sharedCache(args, handle)
 	if err != nil {
-		klog.Exitf("creating gpu monitor error: %v", err)
+		return nil, err
 	}
 
 	return &Genius{
 		handle:  handle,
-		monitor: m,
+		args:    args,
+		weights: score.NewWeights(&args.ScoreWeights),
+		weightBounds: score.Bounds{
+			Enabled:   *args.PodScoreWeights.Enabled,
+			MaxWeight: *args.PodScoreWeights.MaxWeight,
+		},
+		cache:        cache,
+		ledger:       sharedLedger(handle),
+		models:       sharedRegistry(&args.ModelRegistry, handle),
+		health:       newHealthThresholds(&args.Health),
+		podLister:    handle.SharedInformerFactory().Core().V1().Pods().Lister(),
+		pdbLister:    handle.SharedInformerFactory().Policy().V1beta1().PodDisruptionBudgets().Lister(),
+		queueTimes:   watchQueueTimes(*args.LabelPrefix, handle),
+		reservedPods: watchReservedPods(*args.LabelPrefix, handle),
 	}, nil
 }
 
+// newHealthThresholds returns the thresholds set in the defaulted plugin args,
+// or nil if the health check is disabled.
+func newHealthThresholds(args *v1beta1.HealthArgs) *monitor.HealthThresholds {
+	if !*args.Enabled {
+		return nil
+	}
+	ignored := make(map[uint64]bool, len(args.IgnoredXIDs))
+	for _, xid := range args.IgnoredXIDs {
+		ignored[xid] = true
+	}
+	return &monitor.HealthThresholds{
+		IgnoredXIDs:               ignored,
+		MaxUncorrectableECCErrors: *args.MaxUncorrectableECCErrors,
+		MaxCorrectableECCErrors:   *args.MaxCorrectableECCErrors,
+		DegradedTemperature:       *args.DegradedTemperature,
+		UnhealthyTemperature:      *args.UnhealthyTemperature,
+	}
+}
+
 func (g *Genius) Name() string {
 	return SchedulerName
 }
 
 func (g *Genius) Less(podInfo1, podInfo2 *framework.QueuedPodInfo) bool {
-	return sort.Less(podInfo1, podInfo2)
+	return sort.Less(*g.args.LabelPrefix, g.queueTimes, podInfo1, podInfo2)
 }
 
 func (g *Genius) PreFilter(ctx context.Context, state *framework.CycleState, pod *v1.Pod) *framework.Status {
-	klog.V(3).Infof("prefilter pod %v, updating metrics for next scheduling phases", pod.Name)
+	if status := g.checkPodGroup(pod); !status.IsSuccess() {
+		return status
+	}
+
+	req, err := filter.PodGPURequirements(*g.args.LabelPrefix, v1.ResourceName(g.args.GPUResourceName), pod)
+	if err != nil {
+		klog.Errorf("prefilter pod %v error: %v", pod.Name, err)
+		g.handle.EventRecorder().Eventf(pod, nil, v1.EventTypeWarning, "InvalidGPURequirements", "Scheduling", "%v", err)
+		return framework.NewStatus(framework.UnschedulableAndUnresolvable, err.Error())
+	}
+	if !req.RequestsGPUs() {
+		klog.V(3).Infof("pod %v requests no GPU, skipping the GPU filters", pod.Name)
+		state.Lock()
+		state.Write(skipKey, skipState{})
+		state.Unlock()
+		return framework.NewStatus(framework.Success)
+	}
 
-	metrics, err := g.monitor.UpdateMetrics()
+	if !g.weightBounds.Enabled && score.OverridesWeights(*g.args.LabelPrefix, pod) {
+		klog.Warningf("prefilter pod %v: overriding score weights is disabled, using the configured weights", pod.Name)
+		g.handle.EventRecorder().Eventf(pod, nil, v1.EventTypeWarning, "ScoreWeightsIgnored", "Scheduling",
+			"Overriding score weights is disabled, so the annotations are ignored and the configured weights are used")
+	}
+	weights, err := score.PodWeights(*g.args.LabelPrefix, pod, g.weights, g.weightBounds)
 	if err != nil {
-		klog.Errorf("updating metrics for scheduling error: %v", err)
-		return framework.NewStatus(framework.Error)
+		klog.Errorf("prefilter pod %v error: invalid score weights: %v", pod.Name, err)
+		g.handle.EventRecorder().Eventf(pod, nil, v1.EventTypeWarning, "InvalidScoreWeights", "Scheduling",
+			"Invalid score weights: %v", err)
+		return framework.NewStatus(framework.UnschedulableAndUnresolvable, "invalid score weights: "+err.Error())
 	}
-	logMetricsInfo(metrics)
+
+	snapshot, mode := g.cache.Read(time.Now())
+	if mode == monitor.ModeFallback {
+		klog.Warningf("prefilter pod %v: no GPU metrics are collected within %v, counting GPUs by the allocatable %v",
+			pod.Name, g.args.Resilience.SnapshotTTL.Duration, g.args.GPUResourceName)
+		g.handle.EventRecorder().Eventf(pod, nil, v1.EventTypeWarning, "GPUMetricsUnavailable", "Scheduling",
+			"GPU metrics are unavailable, so GPUs are counted by the allocatable %v and nodes are not scored", g.args.GPUResourceName)
+
+		state.Lock()
+		defer state.Unlock()
+		state.Write(modeKey, mode)
+		state.Write(weightsKey, &weights)
+		state.Write(requirementsKey, req)
+		return framework.NewStatus(framework.Success)
+	}
+	if mode == monitor.ModeDegraded {
+		klog.Warningf("prefilter pod %v: GPU metrics source %v is failing, using the last GPU metrics of version %v collected at %v",
+			pod.Name, snapshot.Source, snapshot.Version, snapshot.CollectedAt)
+	} else {
+		klog.V(3).Infof("prefilter pod %v, using GPU metrics of version %v collected from %v at %v",
+			pod.Name, snapshot.Version, snapshot.Source, snapshot.CollectedAt)
+	}
+
+	maxAge, policy := g.args.Staleness.MaxAge.Duration, g.args.Staleness.Policy
+	stale := snapshot.StaleNodes(maxAge)
+	if len(stale) > 0 {
+		klog.Warningf("GPU metrics of nodes %v are older than %v, so they are %v for pod %v",
+			stale.Names(), maxAge, stalePolicyActions[policy], pod.Name)
+		g.handle.EventRecorder().Eventf(pod, nil, v1.EventTypeWarning, "StaleGPUMetrics", "Scheduling",
+			"GPU metrics of nodes %v are older than %v, so they are %v", stale.Names(), maxAge, stalePolicyActions[policy])
+	}
+
+	// The unhealthy GPUs are left out of the metrics, so that no filter
+	// counts them.
+	metrics, report := snapshot.Metrics, monitor.HealthReport{}
+	if g.health != nil {
+		metrics, report = g.health.Check(metrics, snapshot.Health)
+	}
+
+	// The reserved GPUs are subtracted from the metrics, until the metrics
+	// include the usage of the pods reserving them.
+	g.ledger.Expire(snapshot.CollectedAt, g.args.ReservationGracePeriod.Duration)
+	metrics = g.ledger.Adjust(metrics)
 
 	state.Lock()
 	defer state.Unlock()
 	state.Write(metricsKey, metrics)
+	state.Write(rawMetricsKey, rawMetrics{snapshot.Metrics})
+	state.Write(gpuLabelsKey, snapshot.Labels)
+	state.Write(healthKey, report)
+	state.Write(staleKey, stale)
+	state.Write(modeKey, mode)
+	state.Write(weightsKey, &weights)
+	state.Write(requirementsKey, req)
 	return framework.NewStatus(framework.Success)
 }
 
+// PreFilterExtensions returns the plugin itself, so that the GPU metrics in
+// the cycle state follow the pods added and removed by the scheduler when it
+// evaluates the nominated pods or simulates preemption.
 func (g *Genius) PreFilterExtensions() framework.PreFilterExtensions {
-	return nil
+	return g
+}
+
+// AddPod subtracts the GPUs taken by podToAdd from the GPU metrics of the node
+// in the cycle state.
+func (g *Genius) AddPod(ctx context.Context, state *framework.CycleState, podToSchedule *v1.Pod, podToAdd *v1.Pod, nodeInfo *framework.NodeInfo) *framework.Status {
+	return g.updatePodGPUs(state, podToAdd, nodeInfo, true)
+}
+
+// RemovePod gives the GPUs taken by podToRemove back to the GPU metrics of the
+// node in the cycle state, so that preempting it frees its GPUs.
+func (g *Genius) RemovePod(ctx context.Context, state *framework.CycleState, podToSchedule *v1.Pod, podToRemove *v1.Pod, nodeInfo *framework.NodeInfo) *framework.Status {
+	return g.updatePodGPUs(state, podToRemove, nodeInfo, false)
+}
+
+// updatePodGPUs adds or removes the GPUs taken by the pod on the node. The
+// number of the GPUs is also counted by the allocatable resource, which the
+// scheduler updates in nodeInfo itself, so nothing is done without the GPU
+// metrics.
+func (g *Genius) updatePodGPUs(state *framework.CycleState, pod *v1.Pod, nodeInfo *framework.NodeInfo, add bool) *framework.Status {
+	if g.skipped(state) || g.readMode(state) == monitor.ModeFallback {
+		return framework.NewStatus(framework.Success)
+	}
+	node := nodeInfo.Node()
+	if node == nil {
+		return framework.NewStatus(framework.Error, "node not found")
+	}
+
+	state.Lock()
+	defer state.Unlock()
+	metrics, err := state.Read(metricsKey)
+	if err != nil {
+		// PreFilter has not run, such as in the preemption of other plugins.
+		return framework.NewStatus(framework.Success)
+	}
+	gpuMetrics := metrics.(*types.GPUMetricsWithProm)
+	gpus := g.podGPUs(state, pod, node.Name, gpuMetrics, add)
+	if len(gpus) == 0 {
+		return framework.NewStatus(framework.Success)
+	}
+
+	if add {
+		klog.V(4).Infof("adding %v GPU(s) of pod %v on node %v", len(gpus), pod.Name, node.Name)
+		state.Write(metricsKey, reserve.AddGPUs(gpuMetrics, node.Name, gpus))
+	} else {
+		klog.V(4).Infof("removing %v GPU(s) of pod %v on node %v", len(gpus), pod.Name, node.Name)
+		state.Write(metricsKey, reserve.RemoveGPUs(gpuMetrics, node.Name, gpus, g.availableGPUs(state, node.Name)))
+	}
+	return framework.NewStatus(framework.Success)
+}
+
+// podGPUs returns the GPUs the pod takes on the node: those in its
+// reservation if it has not shown up in the metrics, or else those assigned
+// in its annotation. A pod added without either, such as a nominated pod,
+// is assumed to take the GPUs it would reserve. The state must be locked.
+func (g *Genius) podGPUs(state *framework.CycleState, pod *v1.Pod, nodeName string, metrics *types.GPUMetricsWithProm, add bool) []reserve.GPU {
+	if r := g.ledger.Get(pod.UID); r != nil && r.Node == nodeName {
+		return append([]reserve.GPU(nil), r.GPUs...)
+	}
+	req, err := filter.PodGPURequirements(*g.args.LabelPrefix, v1.ResourceName(g.args.GPUResourceName), pod)
+	if err != nil || !req.RequestsGPUs() {
+		return nil
+	}
+	if gpus := reserve.AssignedGPUs(pod, *g.args.LabelPrefix+reserve.AssignedGPUsAnnotation, req); len(gpus) > 0 {
+		return gpus
+	}
+	if !add {
+		return nil
+	}
+	var gpuLabels map[uint]map[string]string
+	if l, err := state.Read(gpuLabelsKey); err == nil {
+		gpuLabels = l.(types.GPULabels)[nodeName]
+	}
+	return reserve.SelectGPUs(req, metrics.GPUs(nodeName), gpuLabels, g.models, g.args.Filters)
+}
+
+// availableGPUs returns the GPUs of the node in the metrics the cycle is built
+// from, before the reserved GPUs are left out, without the unhealthy ones.
+// The state must be locked.
+func (g *Genius) availableGPUs(state *framework.CycleState, nodeName string) []*scraper.MetricsSnapshotPerGPU {
+	raw, err := state.Read(rawMetricsKey)
+	if err != nil {
+		return nil
+	}
+	var report monitor.HealthReport
+	if r, err := state.Read(healthKey); err == nil {
+		report = r.(monitor.HealthReport)
+	}
+	var gpus []*scraper.MetricsSnapshotPerGPU
+	for _, gpu := range raw.(rawMetrics).GPUs(nodeName) {
+		if health, ok := report[nodeName][gpu.StaticAttr.ID]; ok && health.Status == monitor.Unhealthy {
+			continue
+		}
+		gpus = append(gpus, gpu)
+	}
+	return gpus
 }
 
 func (g *Genius) Filter(ctx context.Context, state *framework.CycleState, pod *v1.Pod, nodeInfo *framework.NodeInfo) *framework.Status {
+	if g.skipped(state) {
+		return framework.NewStatus(framework.Success)
+	}
 	klog.V(3).Infof("filter pod %v and node %v", pod.Name, nodeInfo.Node().Name)
 
+	g.RLock()
+	r, err := state.Read(requirementsKey)
+	g.RUnlock()
+	if err != nil {
+		klog.Errorf("retrieving gpu requirements from cyclestate in filter phase error: %v", err)
+		return framework.NewStatus(framework.Error, "cannot retrieve gpu requirements")
+	}
+	if g.readMode(state) == monitor.ModeFallback {
+		return g.filterWithoutMetrics(pod, r.(*filter.GPURequirements), nodeInfo)
+	}
+
 	g.RLock()
 	metrics, err := state.Read(metricsKey)
 	g.RUnlock()
@@ -87,21 +371,174 @@ func (g *Genius) Filter(ctx context.Context, state *framework.CycleState, pod *v
 		return framework.NewStatus(framework.Error, "cannot retrieve cluster metrics")
 	}
 
-	m := metrics.(*types.GPUMetricsWithProm)
-	if ok, requiredNumber := filter.PodFitsGPUNumber(pod, nodeInfo, m); ok {
-		fitsMemoryEach := filter.PodFitsMemoryEach(requiredNumber, pod, nodeInfo, m)
-		fitsMemoryTotal := filter.PodFitsMemoryTotal(pod, nodeInfo, m)
-		fitsModel := filter.PodFitsModel(requiredNumber, pod, nodeInfo, m)
-		if fitsMemoryEach && fitsMemoryTotal && fitsModel {
+	g.RLock()
+	l, err := state.Read(gpuLabelsKey)
+	g.RUnlock()
+	if err != nil {
+		klog.Errorf("retrieving gpu labels from cyclestate in filter phase error: %v", err)
+		return framework.NewStatus(framework.Error, "cannot retrieve gpu labels")
+	}
+
+	g.RLock()
+	h, err := state.Read(healthKey)
+	g.RUnlock()
+	if err != nil {
+		klog.Errorf("retrieving gpu health from cyclestate in filter phase error: %v", err)
+		return framework.NewStatus(framework.Error, "cannot retrieve gpu health")
+	}
+
+	g.RLock()
+	stale, err := state.Read(staleKey)
+	g.RUnlock()
+	if err != nil {
+		klog.Errorf("retrieving stale nodes from cyclestate in filter phase error: %v", err)
+		return framework.NewStatus(framework.Error, "cannot retrieve stale nodes")
+	}
+	if age, ok := stale.(monitor.StaleNodes)[nodeInfo.Node().Name]; ok && g.args.Staleness.Policy == v1beta1.StalePolicyUnschedulable {
+		return framework.NewStatus(framework.Unschedulable, fmt.Sprintf("GPU metrics of node %v are stale, last sampled %v ago (max age %v)",
+			nodeInfo.Node().Name, age.Round(time.Second), g.args.Staleness.MaxAge.Duration))
+	}
+
+	g.RLock()
+	raw, err := state.Read(rawMetricsKey)
+	g.RUnlock()
+	if err != nil {
+		klog.Errorf("retrieving raw cluster metrics from cyclestate in filter phase error: %v", err)
+		return framework.NewStatus(framework.Error, "cannot retrieve raw cluster metrics")
+	}
+
+	m, req, gpuLabels := metrics.(*types.GPUMetricsWithProm), r.(*filter.GPURequirements), l.(types.GPULabels)
+	if fit, reason := filter.PodFitsGPUData(pod, nodeInfo, raw.(rawMetrics).GPUMetricsWithProm); !fit {
+		return framework.NewStatus(framework.UnschedulableAndUnresolvable, reason)
+	}
+	// The unhealthy GPUs have been excluded in PreFilter, and they are named
+	// in the status if the node does not fit.
+	nodename := nodeInfo.Node().Name
+	reasons := []string{"unschedulable node: " + nodename}
+	unhealthy := h.(monitor.HealthReport).Describe(nodename, monitor.Unhealthy)
+	if len(unhealthy) > 0 {
+		reasons = append(reasons, "unhealthy "+strings.Join(unhealthy, ", "))
+	}
+	// The node has GPUs, but they may all be unhealthy or reserved for now.
+	// The reserved GPUs may be freed by preempting or waiting, while the
+	// unhealthy ones cannot be healed by evicting pods.
+	if !m.HasGPUData(nodename) {
+		reserved := len(raw.(rawMetrics).GPUs(nodename)) - len(unhealthy)
+		if reserved == 0 {
+			return framework.NewStatus(framework.UnschedulableAndUnresolvable, reasons...)
+		}
+		reasons = append(reasons, fmt.Sprintf("%v GPUs reserved by the pods being bound", reserved))
+		return framework.NewStatus(framework.Unschedulable, reasons...)
+	}
+	enabled := g.args.Filters
+	if *enabled.CUDAVersion {
+		if fit, reason := filter.PodFitsCUDAVersion(pod, req, nodeInfo, gpuLabels); !fit {
+			return framework.NewStatus(framework.UnschedulableAndUnresolvable, reason)
+		}
+	}
+	if ok, requiredNumber := filter.PodFitsGPUNumber(pod, req, v1.ResourceName(g.args.GPUResourceName), nodeInfo, m); ok || !*enabled.GPUNumber {
+		fitsMemoryEach := !*enabled.MemoryEach || filter.PodFitsMemoryEach(pod, req, requiredNumber, nodeInfo, m)
+		fitsMemoryTotal := !*enabled.MemoryTotal || filter.PodFitsMemoryTotal(pod, req, nodeInfo, m)
+		fitsModel := !*enabled.Model || filter.PodFitsModel(pod, req, requiredNumber, nodeInfo, m, g.models)
+		fitsAttributes := !*enabled.Attributes || filter.PodFitsAttributes(pod, req, requiredNumber, nodeInfo, m)
+		if *enabled.Capability {
+			if fit, reason := filter.PodFitsCapability(pod, req, requiredNumber, nodeInfo, m, gpuLabels, g.models); !fit {
+				return framework.NewStatus(framework.Unschedulable, append([]string{reason}, reasons[1:]...)...)
+			}
+		}
+		// The GPUs are selected as in Reserve, so that the node can be reserved
+		// once it passes, with GPUs satisfying all the requirements at once.
+		gpus := reserve.SelectGPUs(req, m.GPUs(nodename), gpuLabels[nodename], g.models, enabled)
+		fitsTogether, reason := reserve.Satisfies(req, gpus, enabled)
+		if !fitsTogether {
+			reasons = append(reasons, reason)
+		}
+		if fitsMemoryEach && fitsMemoryTotal && fitsModel && fitsAttributes && fitsTogether {
 			return framework.NewStatus(framework.Success)
 		}
 	}
 
-	return framework.NewStatus(framework.Unschedulable, "unschedulable node: "+nodeInfo.Node().Name)
+	return framework.NewStatus(framework.Unschedulable, reasons...)
+}
+
+// skipped judges whether PreFilter has found that the pod requests no GPU.
+func (g *Genius) skipped(state *framework.CycleState) bool {
+	g.RLock()
+	_, err := state.Read(skipKey)
+	g.RUnlock()
+	return err == nil
+}
+
+// nodeHasGPUs judges whether the node has GPUs, by the extended resource it
+// advertises, or by the latest GPU metrics if it advertises none.
+func (g *Genius) nodeHasGPUs(nodeInfo *framework.NodeInfo) bool {
+	if allocatable, ok := nodeInfo.Allocatable.ScalarResources[v1.ResourceName(g.args.GPUResourceName)]; ok {
+		return allocatable > 0
+	}
+	snapshot, mode := g.cache.Read(time.Now())
+	return mode != monitor.ModeFallback && snapshot.Metrics.HasGPUData(nodeInfo.Node().Name)
+}
+
+// scoreNonGPUPod scores the node for a pod requesting no GPU. Every node
+// scores the same unless the policy steers the pod away from the GPU nodes.
+func (g *Genius) scoreNonGPUPod(nodeInfo *framework.NodeInfo) int64 {
+	if g.args.NonGPUPodPolicy == v1beta1.NonGPUPodPolicyAvoidGPUNodes && !g.nodeHasGPUs(nodeInfo) {
+		return framework.MaxNodeScore
+	}
+	return 0
+}
+
+// readMode returns the mode the GPU metrics are read in during the cycle.
+func (g *Genius) readMode(state *framework.CycleState) monitor.Mode {
+	g.RLock()
+	mode, err := state.Read(modeKey)
+	g.RUnlock()
+	if err != nil {
+		// PreFilter has not run, such as in the preemption of other plugins.
+		return monitor.ModeNormal
+	}
+	return mode.(monitor.Mode)
+}
+
+// filterWithoutMetrics filters the node while the GPU metrics are unavailable.
+// Only the free GPUs of the extended resource and the CUDA version, which is
+// read from the node labels, are checked, and the pods with requirements
+// needing the GPU metrics are unschedulable.
+func (g *Genius) filterWithoutMetrics(pod *v1.Pod, req *filter.GPURequirements, nodeInfo *framework.NodeInfo) *framework.Status {
+	if names := req.MetricsRequirements(); len(names) > 0 {
+		return framework.NewStatus(framework.Unschedulable,
+			fmt.Sprintf("GPU metrics are unavailable, so the requirements %v cannot be checked", names))
+	}
+	if *g.args.Filters.CUDAVersion {
+		if fit, reason := filter.PodFitsCUDAVersion(pod, req, nodeInfo, types.GPULabels{}); !fit {
+			return framework.NewStatus(framework.UnschedulableAndUnresolvable, reason)
+		}
+	}
+	if *g.args.Filters.GPUNumber {
+		if fit, reason := filter.PodFitsAllocatableGPUs(pod, req, v1.ResourceName(g.args.GPUResourceName), nodeInfo); !fit {
+			return framework.NewStatus(framework.Unschedulable, reason)
+		}
+	}
+	return framework.NewStatus(framework.Success)
 }
 
 func (g *Genius) Score(ctx context.Context, state *framework.CycleState, pod *v1.Pod, nodeName string) (int64, *framework.Status) {
 	klog.V(3).Infof("scoring pod %v and node %v", pod.Name, nodeName)
+	if g.skipped(state) {
+		if g.args.NonGPUPodPolicy == v1beta1.NonGPUPodPolicyNone {
+			return 0, nil
+		}
+		nodeInfo, err := g.handle.SnapshotSharedLister().NodeInfos().Get(nodeName)
+		if err != nil {
+			klog.Errorf("getting node info error: %v", err)
+			return 0, framework.NewStatus(framework.Error)
+		}
+		return g.scoreNonGPUPod(nodeInfo), nil
+	}
+	if g.readMode(state) == monitor.ModeFallback {
+		// All the nodes score the same without the GPU metrics.
+		return 0, nil
+	}
 
 	nodeInfo, err := g.handle.SnapshotSharedLister().NodeInfos().Get(nodeName)
 	if err != nil {
@@ -117,17 +554,149 @@ func (g *Genius) Score(ctx context.Context, state *framework.CycleState, pod *v1
 		return 0, framework.NewStatus(framework.Error)
 	}
 
-	m := metrics.(*types.GPUMetricsWithProm)
-	sc, err := score.ComputeScore(pod, nodeInfo, m)
+	g.RLock()
+	weights, err := state.Read(weightsKey)
+	g.RUnlock()
+	if err != nil {
+		klog.Errorf("retrieving score weights from cyclestate in scoring phase error: %v", err)
+		return 0, framework.NewStatus(framework.Error)
+	}
+
+	g.RLock()
+	report, err := state.Read(healthKey)
+	g.RUnlock()
+	if err != nil {
+		klog.Errorf("retrieving gpu health from cyclestate in scoring phase error: %v", err)
+		return 0, framework.NewStatus(framework.Error)
+	}
+
+	g.RLock()
+	stale, err := state.Read(staleKey)
+	g.RUnlock()
+	if err != nil {
+		klog.Errorf("retrieving stale nodes from cyclestate in scoring phase error: %v", err)
+		return 0, framework.NewStatus(framework.Error)
+	}
+
+	m, w := metrics.(*types.GPUMetricsWithProm), *weights.(*score.Weights)
+	if _, ok := stale.(monitor.StaleNodes)[nodeName]; ok && g.args.Staleness.Policy == v1beta1.StalePolicyStaticOnly {
+		klog.V(3).Infof("GPU metrics of node %v are stale, scoring it by the static GPU attributes only", nodeName)
+		w.Dynamic = 0
+	}
+	sc, err := score.ComputeScore(pod, nodeInfo, m, w)
 	if err != nil {
 		klog.Errorf("computing score of pod %v and node %v error: %v", pod.Name, nodeName, err)
 		return 0, framework.NewStatus(framework.Error)
 	}
 
+	if degraded := report.(monitor.HealthReport).Count(nodeName, monitor.Degraded); degraded > 0 && g.health != nil {
+		total := 0
+		if gpuMetrics := (*m)[nodeName]; gpuMetrics != nil {
+			total = len(gpuMetrics.GPUs)
+		}
+		sc = score.PenalizeDegraded(sc, degraded, total, *g.args.Health.DegradedPenalty)
+		klog.V(3).Infof("node %v has %v/%v degraded gpu, lowering its score to %v", nodeName, degraded, total, sc)
+	}
+
 	klog.Infof("the original score of pod %v with node %v is %v", pod.Name, nodeName, sc)
 	return int64(sc), nil
 }
 
+// Reserve records the GPUs the pod takes on the node in the ledger, so that
+// the following scheduling cycles do not count them as free. The pod is
+// unschedulable on the node if too few of its GPUs satisfy the pod together.
+func (g *Genius) Reserve(ctx context.Context, state *framework.CycleState, pod *v1.Pod, nodeName string) *framework.Status {
+	g.reservePodGroup(pod)
+	if g.skipped(state) {
+		return framework.NewStatus(framework.Success)
+	}
+	if g.readMode(state) == monitor.ModeFallback {
+		// The GPUs are allocated by the device plugin alone without the GPU metrics.
+		return framework.NewStatus(framework.Success)
+	}
+
+	g.RLock()
+	metrics, err := state.Read(metricsKey)
+	g.RUnlock()
+	if err != nil {
+		klog.Errorf("retrieving cluster metrics from cyclestate in reserve phase error: %v", err)
+		return framework.NewStatus(framework.Error, "cannot retrieve cluster metrics")
+	}
+
+	g.RLock()
+	req, err := state.Read(requirementsKey)
+	g.RUnlock()
+	if err != nil {
+		klog.Errorf("retrieving gpu requirements from cyclestate in reserve phase error: %v", err)
+		return framework.NewStatus(framework.Error, "cannot retrieve gpu requirements")
+	}
+
+	g.RLock()
+	gpuLabels, err := state.Read(gpuLabelsKey)
+	g.RUnlock()
+	if err != nil {
+		klog.Errorf("retrieving gpu labels from cyclestate in reserve phase error: %v", err)
+		return framework.NewStatus(framework.Error, "cannot retrieve gpu labels")
+	}
+
+	gpuMetrics := (*metrics.(*types.GPUMetricsWithProm))[nodeName]
+	if gpuMetrics == nil {
+		return framework.NewStatus(framework.Success)
+	}
+	gpuReq := req.(*filter.GPURequirements)
+	gpus := reserve.SelectGPUs(gpuReq, gpuMetrics.GPUs, gpuLabels.(types.GPULabels)[nodeName], g.models, g.args.Filters)
+	if ok, reason := reserve.Satisfies(gpuReq, gpus, g.args.Filters); !ok {
+		return framework.NewStatus(framework.Unschedulable, fmt.Sprintf("%v on node %v", reason, nodeName))
+	}
+	if len(gpus) == 0 {
+		return framework.NewStatus(framework.Success)
+	}
+
+	reservation := &reserve.Reservation{
+		Pod:        pod.Namespace + "/" + pod.Name,
+		Node:       nodeName,
+		GPUs:       gpus,
+		ReservedAt: time.Now(),
+	}
+	g.ledger.Reserve(pod.UID, reservation)
+
+	state.Lock()
+	state.Write(reservationKey, reservation)
+	state.Unlock()
+	return framework.NewStatus(framework.Success)
+}
+
+// Unreserve releases the GPUs reserved by the pod, if the pod fails to be
+// bound, and rejects the rest of its pod group waiting to be bound.
+func (g *Genius) Unreserve(ctx context.Context, state *framework.CycleState, pod *v1.Pod, nodeName string) {
+	g.ledger.Release(pod.UID)
+	g.unreservePodGroup(pod)
+	g.rejectPodGroup(pod)
+}
+
+// PreBind records the GPUs reserved for the pod in its annotation, before the
+// pod is bound to the node.
+func (g *Genius) PreBind(ctx context.Context, state *framework.CycleState, pod *v1.Pod, nodeName string) *framework.Status {
+	g.RLock()
+	r, err := state.Read(reservationKey)
+	g.RUnlock()
+	if err != nil {
+		// The pod reserved no GPU.
+		return framework.NewStatus(framework.Success)
+	}
+
+	reservation := r.(*reserve.Reservation)
+	annotation := *g.args.LabelPrefix + reserve.AssignedGPUsAnnotation
+	if err := reserve.AssignGPUs(ctx, g.handle.ClientSet(), pod, annotation, reservation); err != nil {
+		klog.Errorf("assigning GPUs to pod %v error: %v", pod.Name, err)
+		return framework.NewStatus(framework.Error, err.Error())
+	}
+	klog.Infof("assigned GPUs %v on node %v to pod %v", reservation.Devices(), nodeName, pod.Name)
+	return framework.NewStatus(framework.Success)
+}
+
+// NormalizeScore rescales the scores to [0, framework.MaxNodeScore], since the
+// raw scores grow with the weights and the number of GPUs on the nodes.
 func (g *Genius) NormalizeScore(ctx context.Context, state *framework.CycleState, pod *v1.Pod, scores framework.NodeScoreList) *framework.Status {
 	max := int64(0)
 	min := int64(0)
@@ -149,34 +718,14 @@ func (g *Genius) NormalizeScore(ctx context.Context, state *framework.CycleState
 
 	for i, sc := range scores {
 		scores[i].Score = (sc.Score - min) * framework.MaxNodeScore / (max - min)
-		klog.V(3).Infof("the normalized score for pod %v with node %v is %v", pod.Name, sc.Name, sc.Score)
+		klog.V(3).Infof("the normalized score for pod %v with node %v is %v", pod.Name, sc.Name, scores[i].Score)
 	}
 
 	return framework.NewStatus(framework.Success)
 }
 
+// ScoreExtensions returns the plugin itself, so that the scores are normalized
+// before the framework checks that they are within [0, framework.MaxNodeScore].
 func (g *Genius) ScoreExtensions() framework.ScoreExtensions {
-	return nil
-}
-
-func logMetricsInfo(metrics *types.GPUMetricsWithProm) {
-	klog.V(3).Infof("updated GPU metrics info:\n")
-	for k, v := range *metrics {
-		klog.V(3).Infof("nodename: %v", k)
-		for _, g := range v.GPUs {
-			klog.V(3).Infof(" id: %v", g.StaticAttr.ID)
-			klog.V(3).Infof(" uuid: %v", g.StaticAttr.UUID)
-			klog.V(3).Infof(" model: %v", g.StaticAttr.Model)
-			klog.V(3).Infof(" decoder utilization: %v", g.DecoderUtilization)
-			klog.V(3).Infof(" encoder utilization: %v", g.EncoderUtilization)
-			klog.V(3).Infof(" memory utilization: %v", g.MemoryUtilization)
-			klog.V(3).Infof(" power usage: %v", g.Power)
-			klog.V(3).Infof(" used global memory: %v", g.UsedGlobalMemory)
-			klog.V(3).Infof(" free global memory: %v", g.FreeGlobalMemory)
-			klog.V(3).Infof(" memory size in MB: %v", g.StaticAttr.MemorySizeMB)
-			klog.V(3).Infof(" multiprocessor count: %v", g.StaticAttr.MultiprocessorCount)
-			klog.V(3).Infof(" shared decoder count: %v", g.StaticAttr.SharedDecoderCount)
-			klog.V(3).Infof(" shared encoder count: %v", g.StaticAttr.SharedEncoderCount)
-		}
-	}
+	return g
 }
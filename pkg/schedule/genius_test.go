@@ -0,0 +1,379 @@
+package schedule
+
+import (
+	"context"
+	"fmt"
+	"github.com/genius/pkg/apis/v1beta1"
+	"github.com/genius/pkg/monitor"
+	"github.com/genius/pkg/schedule/filter"
+	"github.com/genius/pkg/schedule/gang"
+	"github.com/genius/pkg/schedule/reserve"
+	"github.com/genius/pkg/schedule/score"
+	"github.com/genius/pkg/types"
+	"github.com/observerward/pkg/scraper"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/kubernetes/pkg/scheduler/framework"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestGenius(t *testing.T) *Genius {
+	args, err := v1beta1.DecodeGeniusArgs(nil)
+	if err != nil {
+		t.Fatalf("decoding default args error: %v", err)
+	}
+	return &Genius{
+		args:         args,
+		health:       newHealthThresholds(&args.Health),
+		cache:        monitor.NewCache(nil, time.Second, time.Minute),
+		ledger:       reserve.NewLedger(),
+		reservedPods: gang.NewReservedPods(),
+	}
+}
+
+// newFakeNodeInfo returns the node info of a node advertising gpus GPUs.
+func newFakeNodeInfo(name string, gpus int64) *framework.NodeInfo {
+	nodeInfo := framework.NewNodeInfo()
+	nodeInfo.SetNode(&v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Status: v1.NodeStatus{Allocatable: v1.ResourceList{
+			v1beta1.DefaultGPUResourceName: *resource.NewQuantity(gpus, resource.DecimalSI),
+		}},
+	})
+	return nodeInfo
+}
+
+// newCycleState returns the cycle state PreFilter would have written for
+// the pod requiring count GPUs.
+func newCycleState(metrics *types.GPUMetricsWithProm, count int) *framework.CycleState {
+	state := framework.NewCycleState()
+	state.Write(requirementsKey, &filter.GPURequirements{Count: &count})
+	state.Write(metricsKey, metrics)
+	state.Write(rawMetricsKey, rawMetrics{metrics})
+	state.Write(gpuLabelsKey, types.GPULabels{})
+	state.Write(healthKey, monitor.HealthReport{})
+	state.Write(staleKey, monitor.StaleNodes{})
+	state.Write(modeKey, monitor.ModeNormal)
+	return state
+}
+
+func TestFilterWithoutGPUData(t *testing.T) {
+	metrics := &types.GPUMetricsWithProm{
+		"gpu-node":   {GPUs: []*scraper.MetricsSnapshotPerGPU{{FreeGlobalMemory: 8000}, {FreeGlobalMemory: 8000}}},
+		"nil-node":   nil,
+		"empty-node": {},
+	}
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod"}}
+
+	tests := []struct {
+		node     string
+		wantCode framework.Code
+	}{
+		{"gpu-node", framework.Success},
+		{"missing-node", framework.UnschedulableAndUnresolvable},
+		{"nil-node", framework.UnschedulableAndUnresolvable},
+		{"empty-node", framework.UnschedulableAndUnresolvable},
+	}
+	for _, test := range tests {
+		t.Run(test.node, func(t *testing.T) {
+			g := newTestGenius(t)
+			status := g.Filter(context.Background(), newCycleState(metrics, 1), pod, newFakeNodeInfo(test.node, 2))
+			if status.Code() != test.wantCode {
+				t.Fatalf("expected %v, got %v: %v", test.wantCode, status.Code(), status.Message())
+			}
+			if test.wantCode != framework.Success && !strings.Contains(status.Message(), "no GPU data of node "+test.node) {
+				t.Errorf("expected the reason to name the missing GPU data, got %q", status.Message())
+			}
+		})
+	}
+}
+
+func TestFilterUnusableGPUs(t *testing.T) {
+	raw := &types.GPUMetricsWithProm{
+		"unhealthy-node": {GPUs: []*scraper.MetricsSnapshotPerGPU{{StaticAttr: scraper.GPUStaticAttr{ID: 0}}}},
+		"reserved-node":  {GPUs: []*scraper.MetricsSnapshotPerGPU{{StaticAttr: scraper.GPUStaticAttr{ID: 0}}}},
+	}
+	// The unhealthy GPUs and the GPUs reserved as a whole are left out of the
+	// metrics in PreFilter.
+	metrics := &types.GPUMetricsWithProm{"unhealthy-node": {}, "reserved-node": {}}
+	report := monitor.HealthReport{"unhealthy-node": {0: {Status: monitor.Unhealthy, Reasons: []string{"XID error 79"}}}}
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod"}}
+
+	tests := []struct {
+		node       string
+		wantCode   framework.Code
+		wantReason string
+	}{
+		// Evicting pods cannot heal the GPUs.
+		{"unhealthy-node", framework.UnschedulableAndUnresolvable, "unhealthy gpu 0 (XID error 79)"},
+		// Unlike a node without GPU data, the node may be preempted.
+		{"reserved-node", framework.Unschedulable, "1 GPUs reserved by the pods being bound"},
+	}
+	for _, test := range tests {
+		t.Run(test.node, func(t *testing.T) {
+			g := newTestGenius(t)
+			state := newCycleState(metrics, 1)
+			state.Write(rawMetricsKey, rawMetrics{raw})
+			state.Write(healthKey, report)
+			status := g.Filter(context.Background(), state, pod, newFakeNodeInfo(test.node, 1))
+			if status.Code() != test.wantCode {
+				t.Fatalf("expected %v, got %v: %v", test.wantCode, status.Code(), status.Message())
+			}
+			if !strings.Contains(status.Message(), test.wantReason) {
+				t.Errorf("expected the reason %q, got %q", test.wantReason, status.Message())
+			}
+		})
+	}
+}
+
+func TestGPUsFitTogether(t *testing.T) {
+	newGPU := func(id uint, model string, free uint64) *scraper.MetricsSnapshotPerGPU {
+		return &scraper.MetricsSnapshotPerGPU{
+			StaticAttr:       scraper.GPUStaticAttr{ID: id, UUID: fmt.Sprintf("GPU-%v", id), Model: model, MemorySizeMB: 16000},
+			FreeGlobalMemory: free,
+		}
+	}
+	// Two GPUs have the memory and the other two are of the model, but none
+	// of them has both.
+	metrics := &types.GPUMetricsWithProm{"node": {GPUs: []*scraper.MetricsSnapshotPerGPU{
+		newGPU(0, "Tesla T4", 8000), newGPU(1, "Tesla T4", 8000),
+		newGPU(2, "Tesla V100", 1000), newGPU(3, "Tesla V100", 1000),
+	}}}
+	count := 2
+	req := &filter.GPURequirements{Count: &count, MemoryEach: 4000, Models: filter.ModelRequirements{Allow: []string{"Tesla V100"}}}
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod", Namespace: "default", UID: "pod"}}
+
+	g := newTestGenius(t)
+	state := newCycleState(metrics, count)
+	state.Write(requirementsKey, req)
+	if status := g.Filter(context.Background(), state, pod, newFakeNodeInfo("node", 4)); status.Code() != framework.Unschedulable {
+		t.Errorf("expected the node to be unschedulable, got %v: %v", status.Code(), status.Message())
+	}
+	if status := g.Reserve(context.Background(), state, pod, "node"); status.Code() != framework.Unschedulable {
+		t.Errorf("expected the reservation to fail, got %v: %v", status.Code(), status.Message())
+	}
+	if g.ledger.Get(pod.UID) != nil {
+		t.Errorf("expected no GPU to be reserved")
+	}
+
+	// The GPUs reserve the share of the memory-total above the memory-each.
+	req = &filter.GPURequirements{Count: &count, MemoryEach: 4000, MemoryTotal: 10000}
+	state.Write(requirementsKey, req)
+	if status := g.Filter(context.Background(), state, pod, newFakeNodeInfo("node", 4)); !status.IsSuccess() {
+		t.Fatalf("expected the node to fit, got %v: %v", status.Code(), status.Message())
+	}
+	if status := g.Reserve(context.Background(), state, pod, "node"); !status.IsSuccess() {
+		t.Fatalf("expected the node passing the filter to be reserved, got %v: %v", status.Code(), status.Message())
+	}
+	g.Unreserve(context.Background(), state, pod, "node")
+
+	total := &filter.GPURequirements{MemoryTotal: 20000}
+	state.Write(requirementsKey, total)
+	if status := g.Reserve(context.Background(), state, pod, "node"); status.Code() != framework.Unschedulable {
+		t.Errorf("expected the reservation of more memory than free to fail, got %v: %v", status.Code(), status.Message())
+	}
+}
+
+func TestFilterFallbackMode(t *testing.T) {
+	g := newTestGenius(t)
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod"}}
+	newState := func(req *filter.GPURequirements) *framework.CycleState {
+		state := framework.NewCycleState()
+		state.Write(requirementsKey, req)
+		state.Write(modeKey, monitor.ModeFallback)
+		return state
+	}
+	one, three := 1, 3
+
+	if status := g.Filter(context.Background(), newState(&filter.GPURequirements{Count: &one}), pod, newFakeNodeInfo("node", 2)); !status.IsSuccess() {
+		t.Errorf("expected the pod to fit the allocatable GPUs, got %v", status.Message())
+	}
+	if status := g.Filter(context.Background(), newState(&filter.GPURequirements{Count: &three}), pod, newFakeNodeInfo("node", 2)); status.IsSuccess() {
+		t.Errorf("expected the pod requiring more than the allocatable GPUs not to fit")
+	}
+	if status := g.Filter(context.Background(), newState(&filter.GPURequirements{Count: &one, MemoryEach: 4000}), pod, newFakeNodeInfo("node", 2)); status.IsSuccess() {
+		t.Errorf("expected the pod requiring free memory not to fit without GPU metrics")
+	}
+}
+
+func TestNonGPUPod(t *testing.T) {
+	g := newTestGenius(t)
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod"}}
+	state := framework.NewCycleState()
+	if status := g.PreFilter(context.Background(), state, pod); !status.IsSuccess() {
+		t.Fatalf("expected prefilter to succeed, got %v", status.Message())
+	}
+	if !g.skipped(state) {
+		t.Fatalf("expected the pod requesting no GPU to be skipped")
+	}
+
+	// The node has no GPU data, which would fail a GPU pod.
+	if status := g.Filter(context.Background(), state, pod, newFakeNodeInfo("gpu-node", 2)); !status.IsSuccess() {
+		t.Errorf("expected the filter to pass the pod, got %v", status.Message())
+	}
+	if status := g.Reserve(context.Background(), state, pod, "gpu-node"); !status.IsSuccess() {
+		t.Errorf("expected the reserve to pass the pod, got %v", status.Message())
+	}
+
+	cpuNode := framework.NewNodeInfo()
+	cpuNode.SetNode(&v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "cpu-node"}})
+	if g.scoreNonGPUPod(cpuNode) != g.scoreNonGPUPod(newFakeNodeInfo("gpu-node", 2)) {
+		t.Errorf("expected the nodes to score the same without the policy")
+	}
+	g.args.NonGPUPodPolicy = v1beta1.NonGPUPodPolicyAvoidGPUNodes
+	if g.scoreNonGPUPod(cpuNode) <= g.scoreNonGPUPod(newFakeNodeInfo("gpu-node", 2)) {
+		t.Errorf("expected the node without GPUs to score higher")
+	}
+}
+
+func TestPreemptionFreesGPUs(t *testing.T) {
+	g := newTestGenius(t)
+	metrics := &types.GPUMetricsWithProm{
+		"node": {GPUs: []*scraper.MetricsSnapshotPerGPU{{
+			StaticAttr:       scraper.GPUStaticAttr{UUID: "GPU-0", MemorySizeMB: 16000},
+			FreeGlobalMemory: 2000,
+			UsedGlobalMemory: 14000,
+		}}},
+	}
+	one := 1
+	state := newCycleState(metrics, one)
+	state.Write(requirementsKey, &filter.GPURequirements{Count: &one, MemoryEach: 8000})
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod"}}
+	victim := &v1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Name: "victim",
+		Annotations: map[string]string{
+			"genius/" + filter.GPURequirementsAnnotation: "{count: 1, memoryEach: 14000}",
+			"genius/" + reserve.AssignedGPUsAnnotation:   "GPU-0",
+		},
+	}}
+	nodeInfo := newFakeNodeInfo("node", 1)
+
+	if status := g.Filter(context.Background(), state, pod, nodeInfo); status.IsSuccess() {
+		t.Fatalf("expected the pod not to fit before preemption")
+	}
+
+	preempted := state.Clone()
+	if status := g.PreFilterExtensions().RemovePod(context.Background(), preempted, pod, victim, nodeInfo); !status.IsSuccess() {
+		t.Fatalf("expected removing the victim to succeed, got %v", status.Message())
+	}
+	if status := g.Filter(context.Background(), preempted, pod, nodeInfo); !status.IsSuccess() {
+		t.Errorf("expected the pod to fit once the victim is removed, got %v", status.Message())
+	}
+	if status := g.Filter(context.Background(), state, pod, nodeInfo); status.IsSuccess() {
+		t.Errorf("expected the original cycle state not to be modified")
+	}
+
+	if status := g.PreFilterExtensions().AddPod(context.Background(), preempted, pod, victim, nodeInfo); !status.IsSuccess() {
+		t.Fatalf("expected adding the victim back to succeed, got %v", status.Message())
+	}
+	if status := g.Filter(context.Background(), preempted, pod, nodeInfo); status.IsSuccess() {
+		t.Errorf("expected the pod not to fit once the victim is added back")
+	}
+}
+
+func TestPreemptionFreesReservedGPUs(t *testing.T) {
+	g := newTestGenius(t)
+	gpu := &scraper.MetricsSnapshotPerGPU{
+		StaticAttr:       scraper.GPUStaticAttr{UUID: "GPU-0", MemorySizeMB: 16000},
+		FreeGlobalMemory: 16000,
+	}
+	// The GPU reserved as a whole by the victim is left out of the metrics of
+	// the cycle, and is restored from the metrics the cycle is built from.
+	state := newCycleState(&types.GPUMetricsWithProm{"node": {}}, 1)
+	state.Write(rawMetricsKey, rawMetrics{&types.GPUMetricsWithProm{"node": {GPUs: []*scraper.MetricsSnapshotPerGPU{gpu}}}})
+	victim := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "victim", UID: "victim"}}
+	g.ledger.Reserve(victim.UID, &reserve.Reservation{
+		Pod:  "default/victim",
+		Node: "node",
+		GPUs: []reserve.GPU{{UUID: "GPU-0", MemoryMB: 16000, Whole: true}},
+	})
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod"}}
+	nodeInfo := newFakeNodeInfo("node", 1)
+
+	if status := g.Filter(context.Background(), state, pod, nodeInfo); status.Code() != framework.Unschedulable {
+		t.Fatalf("expected the pod not to fit before preemption, got %v: %v", status.Code(), status.Message())
+	}
+	if status := g.PreFilterExtensions().RemovePod(context.Background(), state, pod, victim, nodeInfo); !status.IsSuccess() {
+		t.Fatalf("expected removing the victim to succeed, got %v", status.Message())
+	}
+	if status := g.Filter(context.Background(), state, pod, nodeInfo); !status.IsSuccess() {
+		t.Errorf("expected the pod to fit once the victim is removed, got %v", status.Message())
+	}
+}
+
+// scoreHandle serves the nodes scored. The other methods of framework.Handle
+// are not implemented.
+type scoreHandle struct {
+	framework.Handle
+	framework.NodeInfoLister
+	nodes []*framework.NodeInfo
+}
+
+func (h *scoreHandle) SnapshotSharedLister() framework.SharedLister {
+	return h
+}
+
+func (h *scoreHandle) NodeInfos() framework.NodeInfoLister {
+	return h
+}
+
+func (h *scoreHandle) Get(nodeName string) (*framework.NodeInfo, error) {
+	for _, nodeInfo := range h.nodes {
+		if nodeInfo.Node().Name == nodeName {
+			return nodeInfo, nil
+		}
+	}
+	return nil, fmt.Errorf("node %v not found", nodeName)
+}
+
+func TestScoreWithinRange(t *testing.T) {
+	newGPUs := func(n int) *scraper.GPUMetrics {
+		gpuMetrics := &scraper.GPUMetrics{}
+		for i := 0; i < n; i++ {
+			gpuMetrics.GPUs = append(gpuMetrics.GPUs, &scraper.MetricsSnapshotPerGPU{
+				StaticAttr:       scraper.GPUStaticAttr{ID: uint(i), MemorySizeMB: 16000, MultiprocessorCount: 40, Bandwidth: 320},
+				FreeGlobalMemory: 16000,
+				Power:            70,
+			})
+		}
+		return gpuMetrics
+	}
+	metrics := &types.GPUMetricsWithProm{"big": newGPUs(16), "small": newGPUs(2)}
+
+	g := newTestGenius(t)
+	g.handle = &scoreHandle{nodes: []*framework.NodeInfo{newFakeNodeInfo("big", 16), newFakeNodeInfo("small", 2)}}
+	state := newCycleState(metrics, 1)
+	state.Write(weightsKey, &score.Weights{
+		Static: 100, Dynamic: 100,
+		Memory: 100, Multiprocessor: 100, SharedDecoder: 100, SharedEncoder: 100, Bandwidth: 100,
+		FreeMemory: 100, Power: 100, EncoderUtilization: 100, DecoderUtilization: 100,
+	})
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod"}}
+
+	var scores framework.NodeScoreList
+	for _, node := range []string{"big", "small"} {
+		sc, status := g.Score(context.Background(), state, pod, node)
+		if !status.IsSuccess() {
+			t.Fatalf("scoring node %v error: %v", node, status.Message())
+		}
+		scores = append(scores, framework.NodeScore{Name: node, Score: sc})
+	}
+	if scores[0].Score <= framework.MaxNodeScore {
+		t.Fatalf("expected the raw score of the big node to exceed %v, got %v", framework.MaxNodeScore, scores[0].Score)
+	}
+
+	if status := g.ScoreExtensions().NormalizeScore(context.Background(), state, pod, scores); !status.IsSuccess() {
+		t.Fatalf("normalizing scores error: %v", status.Message())
+	}
+	for _, sc := range scores {
+		if sc.Score < framework.MinNodeScore || sc.Score > framework.MaxNodeScore {
+			t.Errorf("expected the score of node %v within [%v, %v], got %v", sc.Name, framework.MinNodeScore, framework.MaxNodeScore, sc.Score)
+		}
+	}
+	if scores[0].Score != framework.MaxNodeScore || scores[1].Score >= scores[0].Score {
+		t.Errorf("expected the big node to score the highest, got %+v", scores)
+	}
+}
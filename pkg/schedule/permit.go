@@ -0,0 +1,124 @@
+package schedule
+
+import (
+	"context"
+	"fmt"
+	"github.com/genius/pkg/schedule/gang"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+	"k8s.io/kubernetes/pkg/scheduler/framework"
+	"time"
+)
+
+// Permit holds a pod of a pod group until the min-available of the group
+// have reserved their nodes or are running, and then allows all of them to
+// be bound. The pods not in a group are allowed at once.
+func (g *Genius) Permit(ctx context.Context, state *framework.CycleState, pod *v1.Pod, nodeName string) (*framework.Status, time.Duration) {
+	group, err := gang.PodGroupOf(*g.args.LabelPrefix, pod)
+	if err != nil || group == nil {
+		return framework.NewStatus(framework.Success), 0
+	}
+
+	// The pod itself has been counted in Reserve. The running pods of the
+	// group count too, such as when a worker of a running group is recreated.
+	reserved := g.reservedPods.Count(group.Key()) + g.boundPods(group)
+	if reserved < group.MinAvailable {
+		klog.V(3).Infof("pod %v waits on node %v for pod group %v, %v of its min-available %v pods have reserved nodes",
+			pod.Name, nodeName, group.Key(), reserved, group.MinAvailable)
+		return framework.NewStatus(framework.Wait, fmt.Sprintf("waiting for pod group %v", group.Key())), g.args.Gang.Timeout.Duration
+	}
+
+	klog.V(3).Infof("%v of the min-available %v pods of pod group %v have reserved nodes, allowing them",
+		reserved, group.MinAvailable, group.Key())
+	g.handle.IterateOverWaitingPods(func(waitingPod framework.WaitingPod) {
+		if group.Contains(*g.args.LabelPrefix, waitingPod.GetPod()) {
+			waitingPod.Allow(g.Name())
+		}
+	})
+	return framework.NewStatus(framework.Success), 0
+}
+
+// reservePodGroup counts the pod toward the min-available of its group until
+// it is bound, or fails to be.
+func (g *Genius) reservePodGroup(pod *v1.Pod) {
+	if group, err := gang.PodGroupOf(*g.args.LabelPrefix, pod); err == nil && group != nil {
+		g.reservedPods.Add(group.Key(), pod.UID)
+	}
+}
+
+// unreservePodGroup stops counting the pod toward the min-available of its
+// group.
+func (g *Genius) unreservePodGroup(pod *v1.Pod) {
+	if group, err := gang.PodGroupOf(*g.args.LabelPrefix, pod); err == nil && group != nil {
+		g.reservedPods.Remove(group.Key(), pod.UID)
+	}
+}
+
+// boundPods counts the live pods of the group bound to nodes, other than
+// those still counted as reserved.
+func (g *Genius) boundPods(group *gang.PodGroup) int {
+	pods, err := g.podLister.Pods(group.Namespace).List(group.Selector(*g.args.LabelPrefix))
+	if err != nil {
+		klog.Errorf("listing the pods of group %v error: %v", group.Key(), err)
+		return 0
+	}
+	count := 0
+	for _, p := range pods {
+		if p.Spec.NodeName != "" && livePod(p) && !g.reservedPods.Contains(group.Key(), p.UID) {
+			count++
+		}
+	}
+	return count
+}
+
+// livePod judges whether the pod is neither being deleted nor terminated.
+func livePod(pod *v1.Pod) bool {
+	return pod.DeletionTimestamp == nil && pod.Status.Phase != v1.PodSucceeded && pod.Status.Phase != v1.PodFailed
+}
+
+// checkPodGroup rejects the pod if its group is invalid, or has fewer pods
+// than its min-available, so that the group could never be complete.
+func (g *Genius) checkPodGroup(pod *v1.Pod) *framework.Status {
+	group, err := gang.PodGroupOf(*g.args.LabelPrefix, pod)
+	if err != nil {
+		klog.Errorf("prefilter pod %v error: %v", pod.Name, err)
+		g.handle.EventRecorder().Eventf(pod, nil, v1.EventTypeWarning, "InvalidPodGroup", "Scheduling", "%v", err)
+		return framework.NewStatus(framework.UnschedulableAndUnresolvable, err.Error())
+	}
+	if group == nil {
+		return framework.NewStatus(framework.Success)
+	}
+
+	pods, err := g.podLister.Pods(group.Namespace).List(group.Selector(*g.args.LabelPrefix))
+	if err != nil {
+		klog.Errorf("listing the pods of group %v error: %v", group.Key(), err)
+		return framework.NewStatus(framework.Error, err.Error())
+	}
+	total := 0
+	for _, p := range pods {
+		if livePod(p) {
+			total++
+		}
+	}
+	if total < group.MinAvailable {
+		return framework.NewStatus(framework.UnschedulableAndUnresolvable,
+			fmt.Sprintf("pod group %v has %v pods, fewer than its min-available %v", group.Key(), total, group.MinAvailable))
+	}
+	return framework.NewStatus(framework.Success)
+}
+
+// rejectPodGroup rejects the pods of the group of the pod waiting in the
+// permit phase, once the pod fails, such as when it has waited for the
+// timeout, so that the group does not hold the nodes while it is incomplete.
+func (g *Genius) rejectPodGroup(pod *v1.Pod) {
+	group, err := gang.PodGroupOf(*g.args.LabelPrefix, pod)
+	if err != nil || group == nil {
+		return
+	}
+	g.handle.IterateOverWaitingPods(func(waitingPod framework.WaitingPod) {
+		if p := waitingPod.GetPod(); p.UID != pod.UID && group.Contains(*g.args.LabelPrefix, p) {
+			klog.V(3).Infof("rejecting pod %v of group %v along with pod %v", p.Name, group.Key(), pod.Name)
+			waitingPod.Reject(fmt.Sprintf("pod group %v is rejected along with pod %v", group.Key(), pod.Name))
+		}
+	})
+}
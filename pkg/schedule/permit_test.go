@@ -0,0 +1,166 @@
+package schedule
+
+import (
+	"context"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/kubernetes/pkg/scheduler/framework"
+	"testing"
+	"time"
+)
+
+// fakeHandle serves the nodes and the waiting pods. The other methods of
+// framework.Handle are not implemented.
+type fakeHandle struct {
+	framework.Handle
+	nodes   []*framework.NodeInfo
+	waiting []*fakeWaitingPod
+}
+
+func (h *fakeHandle) SnapshotSharedLister() framework.SharedLister {
+	return &fakeSharedLister{nodes: h.nodes}
+}
+
+func (h *fakeHandle) IterateOverWaitingPods(callback func(framework.WaitingPod)) {
+	for _, wp := range h.waiting {
+		callback(wp)
+	}
+}
+
+type fakeSharedLister struct {
+	framework.NodeInfoLister
+	nodes []*framework.NodeInfo
+}
+
+func (l *fakeSharedLister) NodeInfos() framework.NodeInfoLister {
+	return l
+}
+
+func (l *fakeSharedLister) List() ([]*framework.NodeInfo, error) {
+	return l.nodes, nil
+}
+
+type fakeWaitingPod struct {
+	pod      *v1.Pod
+	allowed  bool
+	rejected bool
+}
+
+func (w *fakeWaitingPod) GetPod() *v1.Pod             { return w.pod }
+func (w *fakeWaitingPod) GetPendingPlugins() []string { return nil }
+func (w *fakeWaitingPod) Allow(pluginName string)     { w.allowed = true }
+func (w *fakeWaitingPod) Reject(msg string)           { w.rejected = true }
+
+func newGroupPod(name, group string) *v1.Pod {
+	return &v1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Name:      name,
+		Namespace: "default",
+		UID:       k8stypes.UID(name),
+		Labels:    map[string]string{"genius/pod-group": group, "genius/min-available": "3"},
+	}}
+}
+
+// newBoundPod returns the pod of the group bound to a node.
+func newBoundPod(name, group string) *v1.Pod {
+	pod := newGroupPod(name, group)
+	pod.Spec.NodeName = "node"
+	pod.Status.Phase = v1.PodRunning
+	return pod
+}
+
+func newPodLister(t *testing.T, pods ...*v1.Pod) corelisters.PodLister {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	for _, pod := range pods {
+		if err := indexer.Add(pod); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return corelisters.NewPodLister(indexer)
+}
+
+func TestPermitPodGroup(t *testing.T) {
+	worker0, worker1, worker2 := newGroupPod("worker-0", "job"), newGroupPod("worker-1", "job"), newGroupPod("worker-2", "job")
+	other := newGroupPod("other-0", "other")
+	// The pods of an earlier run of the group, which have finished or are
+	// being deleted, are not counted.
+	finished, deleting := newBoundPod("finished-0", "job"), newBoundPod("deleting-0", "job")
+	finished.Status.Phase = v1.PodSucceeded
+	deleting.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+
+	g := newTestGenius(t)
+	g.podLister = newPodLister(t, worker0, worker1, worker2, other, finished, deleting)
+	handle := &fakeHandle{}
+	g.handle = handle
+	state := framework.NewCycleState()
+	state.Write(skipKey, skipState{})
+	reserve := func(pod *v1.Pod) (*framework.Status, time.Duration) {
+		if status := g.Reserve(context.Background(), state, pod, "node"); !status.IsSuccess() {
+			t.Fatalf("reserving pod %v error: %v", pod.Name, status.Message())
+		}
+		status, timeout := g.Permit(context.Background(), state, pod, "node")
+		if status.Code() == framework.Wait {
+			handle.waiting = append(handle.waiting, &fakeWaitingPod{pod: pod})
+		}
+		return status, timeout
+	}
+
+	for _, pod := range []*v1.Pod{other, worker0, worker1} {
+		status, timeout := reserve(pod)
+		if status.Code() != framework.Wait || timeout != g.args.Gang.Timeout.Duration {
+			t.Fatalf("expected pod %v to wait for %v, got %v for %v", pod.Name, g.args.Gang.Timeout.Duration, status.Code(), timeout)
+		}
+	}
+	if status, _ := reserve(worker2); !status.IsSuccess() {
+		t.Fatalf("expected the last pod of the group to be allowed, got %v", status.Code())
+	}
+	for _, wp := range handle.waiting {
+		if wp.allowed != (wp.pod != other) {
+			t.Errorf("expected only the pods of the group to be allowed, pod %v allowed %v", wp.pod.Name, wp.allowed)
+		}
+	}
+
+	g.Unreserve(context.Background(), state, worker2, "node")
+	if got := g.reservedPods.Count("default/job"); got != 2 {
+		t.Errorf("expected the unreserved pod not to be counted, got %v pods", got)
+	}
+
+	single := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "single", Namespace: "default"}}
+	if status, _ := g.Permit(context.Background(), framework.NewCycleState(), single, "node"); !status.IsSuccess() {
+		t.Errorf("expected a pod in no group to be allowed, got %v", status.Code())
+	}
+}
+
+func TestPermitRecreatedPod(t *testing.T) {
+	worker0, worker1, worker2 := newBoundPod("worker-0", "job"), newBoundPod("worker-1", "job"), newGroupPod("worker-2", "job")
+
+	g := newTestGenius(t)
+	g.podLister = newPodLister(t, worker0, worker1, worker2)
+	g.handle = &fakeHandle{}
+	state := framework.NewCycleState()
+	state.Write(skipKey, skipState{})
+	if status := g.Reserve(context.Background(), state, worker2, "node"); !status.IsSuccess() {
+		t.Fatalf("reserving pod %v error: %v", worker2.Name, status.Message())
+	}
+	if status, _ := g.Permit(context.Background(), state, worker2, "node"); !status.IsSuccess() {
+		t.Errorf("expected the pod recreated in a running group to be allowed, got %v", status.Code())
+	}
+}
+
+func TestUnreserveRejectsPodGroup(t *testing.T) {
+	worker0, worker1 := newGroupPod("worker-0", "job"), newGroupPod("worker-1", "job")
+	other := newGroupPod("other-0", "other")
+
+	g := newTestGenius(t)
+	handle := &fakeHandle{waiting: []*fakeWaitingPod{{pod: worker0}, {pod: worker1}, {pod: other}}}
+	g.handle = handle
+
+	g.Unreserve(context.Background(), framework.NewCycleState(), worker1, "node")
+	for _, wp := range handle.waiting {
+		if wp.rejected != (wp.pod == worker0) {
+			t.Errorf("expected only the rest of the group to be rejected, pod %v rejected %v", wp.pod.Name, wp.rejected)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package preempt
+
+import (
+	v1 "k8s.io/api/core/v1"
+	policy "k8s.io/api/policy/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// groupByPDBViolation splits the pods into those whose PodDisruptionBudgets
+// would be violated if all the pods before them were preempted, and the
+// others. The order of the pods is kept in both groups.
+func groupByPDBViolation(pods []*v1.Pod, pdbs []*policy.PodDisruptionBudget) (violating, nonViolating []*v1.Pod) {
+	allowed := make([]int32, len(pdbs))
+	for i, pdb := range pdbs {
+		allowed[i] = pdb.Status.DisruptionsAllowed
+	}
+
+	for _, pod := range pods {
+		violated := false
+		for i, pdb := range pdbs {
+			if pdb.Namespace != pod.Namespace || len(pod.Labels) == 0 {
+				continue
+			}
+			selector, err := metav1.LabelSelectorAsSelector(pdb.Spec.Selector)
+			// A budget with a nil or empty selector selects nothing.
+			if err != nil || selector.Empty() || !selector.Matches(labels.Set(pod.Labels)) {
+				continue
+			}
+			// A pod which has been disrupted is already counted by the budget.
+			if _, ok := pdb.Status.DisruptedPods[pod.Name]; ok {
+				continue
+			}
+			allowed[i]--
+			if allowed[i] < 0 {
+				violated = true
+			}
+		}
+		if violated {
+			violating = append(violating, pod)
+		} else {
+			nonViolating = append(nonViolating, pod)
+		}
+	}
+	return violating, nonViolating
+}
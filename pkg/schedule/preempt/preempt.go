@@ -0,0 +1,149 @@
+package preempt
+
+import (
+	gsort "github.com/genius/pkg/schedule/sort"
+	v1 "k8s.io/api/core/v1"
+	policy "k8s.io/api/policy/v1beta1"
+	corev1helpers "k8s.io/component-helpers/scheduling/corev1"
+	extenderv1 "k8s.io/kube-scheduler/extender/v1"
+	"k8s.io/kubernetes/pkg/scheduler/util"
+	"sort"
+)
+
+// Candidate is a node the preemptor fits once the victims are preempted. It
+// implements defaultpreemption.Candidate, so that it can be prepared in the
+// same way as the candidates of the default preemption.
+type Candidate struct {
+	NodeName         string
+	Pods             []*v1.Pod
+	NumPDBViolations int
+}
+
+func (c *Candidate) Name() string {
+	return c.NodeName
+}
+
+func (c *Candidate) Victims() *extenderv1.Victims {
+	return &extenderv1.Victims{
+		Pods:             c.Pods,
+		NumPDBViolations: int64(c.NumPDBViolations),
+	}
+}
+
+// Simulator simulates removing the pods from a node and adding them back,
+// and tells whether the preemptor fits the node.
+type Simulator interface {
+	RemovePod(pod *v1.Pod) error
+	AddPod(pod *v1.Pod) error
+	Fits() bool
+}
+
+// CanPreempt judges whether the preemptor may preempt the victim, that is,
+// the victim has a lower priority in its priority label, see sort.Less, and
+// its pod priority is not higher than the preemptor's.
+func CanPreempt(labelPrefix string, preemptor, victim *v1.Pod) bool {
+	return gsort.Priority(labelPrefix, victim) < gsort.Priority(labelPrefix, preemptor) &&
+		corev1helpers.PodPriority(victim) <= corev1helpers.PodPriority(preemptor)
+}
+
+// moreImportant orders the pods by the priority label, then by the pod
+// priority and the start time.
+func moreImportant(labelPrefix string, pod1, pod2 *v1.Pod) bool {
+	p1, p2 := gsort.Priority(labelPrefix, pod1), gsort.Priority(labelPrefix, pod2)
+	if p1 != p2 {
+		return p1 > p2
+	}
+	return util.MoreImportantPod(pod1, pod2)
+}
+
+// SelectVictims finds a minimal set of the potential victims on a node whose
+// removal lets the preemptor fit, that is, none of the victims can be spared.
+// All the potential victims are removed first, and then they are added back
+// one by one as long as the preemptor still fits, starting from those whose
+// PodDisruptionBudgets would be violated, and from the more important ones in
+// either group. It returns the victims and the number of them violating their
+// budgets, or false if the preemptor does not fit even without any of them.
+func SelectVictims(labelPrefix string, potentialVictims []*v1.Pod, pdbs []*policy.PodDisruptionBudget, sim Simulator) ([]*v1.Pod, int, bool) {
+	if len(potentialVictims) == 0 {
+		return nil, 0, false
+	}
+	for _, p := range potentialVictims {
+		if err := sim.RemovePod(p); err != nil {
+			return nil, 0, false
+		}
+	}
+	if !sim.Fits() {
+		return nil, 0, false
+	}
+
+	sorted := append([]*v1.Pod(nil), potentialVictims...)
+	sort.SliceStable(sorted, func(i, j int) bool { return moreImportant(labelPrefix, sorted[i], sorted[j]) })
+	violating, nonViolating := groupByPDBViolation(sorted, pdbs)
+
+	var victims []*v1.Pod
+	numViolating := 0
+	reprieve := func(p *v1.Pod) (bool, error) {
+		if err := sim.AddPod(p); err != nil {
+			return false, err
+		}
+		if sim.Fits() {
+			return true, nil
+		}
+		if err := sim.RemovePod(p); err != nil {
+			return false, err
+		}
+		victims = append(victims, p)
+		return false, nil
+	}
+	for _, p := range violating {
+		fits, err := reprieve(p)
+		if err != nil {
+			return nil, 0, false
+		}
+		if !fits {
+			numViolating++
+		}
+	}
+	for _, p := range nonViolating {
+		if _, err := reprieve(p); err != nil {
+			return nil, 0, false
+		}
+	}
+	return victims, numViolating, true
+}
+
+// SelectCandidate picks the candidate violating the fewest budgets, then the
+// one whose most important victim has the lowest priority label, then the one
+// with the fewest victims. nil is returned if there is no candidate.
+func SelectCandidate(labelPrefix string, candidates []*Candidate) *Candidate {
+	var best *Candidate
+	for _, c := range candidates {
+		if best == nil || better(labelPrefix, c, best) {
+			best = c
+		}
+	}
+	return best
+}
+
+func better(labelPrefix string, c1, c2 *Candidate) bool {
+	if c1.NumPDBViolations != c2.NumPDBViolations {
+		return c1.NumPDBViolations < c2.NumPDBViolations
+	}
+	if p1, p2 := highestPriority(labelPrefix, c1.Pods), highestPriority(labelPrefix, c2.Pods); p1 != p2 {
+		return p1 < p2
+	}
+	if len(c1.Pods) != len(c2.Pods) {
+		return len(c1.Pods) < len(c2.Pods)
+	}
+	return c1.NodeName < c2.NodeName
+}
+
+func highestPriority(labelPrefix string, pods []*v1.Pod) int {
+	highest := 0
+	for i, p := range pods {
+		if priority := gsort.Priority(labelPrefix, p); i == 0 || priority > highest {
+			highest = priority
+		}
+	}
+	return highest
+}
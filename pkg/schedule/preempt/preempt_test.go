@@ -0,0 +1,119 @@
+package preempt
+
+import (
+	v1 "k8s.io/api/core/v1"
+	policy "k8s.io/api/policy/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+// fakeSimulator counts the free GPUs of a node, where each pod holds the
+// GPUs in gpus.
+type fakeSimulator struct {
+	free int
+	need int
+	gpus map[string]int
+}
+
+func (s *fakeSimulator) RemovePod(pod *v1.Pod) error {
+	s.free += s.gpus[pod.Name]
+	return nil
+}
+
+func (s *fakeSimulator) AddPod(pod *v1.Pod) error {
+	s.free -= s.gpus[pod.Name]
+	return nil
+}
+
+func (s *fakeSimulator) Fits() bool {
+	return s.free >= s.need
+}
+
+func newPod(name string, priority int, labels map[string]string) *v1.Pod {
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Name:      name,
+		Namespace: "default",
+		Labels:    map[string]string{"genius/priority": strconv.Itoa(priority)},
+	}}
+	for k, v := range labels {
+		pod.Labels[k] = v
+	}
+	return pod
+}
+
+func names(pods []*v1.Pod) []string {
+	var res []string
+	for _, pod := range pods {
+		res = append(res, pod.Name)
+	}
+	return res
+}
+
+func TestCanPreempt(t *testing.T) {
+	preemptor := newPod("preemptor", 5, nil)
+	if !CanPreempt("genius/", preemptor, newPod("low", 1, nil)) {
+		t.Errorf("expected a pod of a lower priority label to be preemptable")
+	}
+	if CanPreempt("genius/", preemptor, newPod("same", 5, nil)) {
+		t.Errorf("expected a pod of the same priority label not to be preemptable")
+	}
+
+	critical := newPod("critical", 1, nil)
+	priority := int32(1000)
+	critical.Spec.Priority = &priority
+	if CanPreempt("genius/", preemptor, critical) {
+		t.Errorf("expected a pod of a higher pod priority not to be preemptable")
+	}
+}
+
+func TestSelectVictims(t *testing.T) {
+	low, mid, high := newPod("low", 1, nil), newPod("mid", 2, map[string]string{"app": "mid"}), newPod("high", 3, nil)
+	gpus := map[string]int{"low": 1, "mid": 2, "high": 1}
+	pdb := &policy.PodDisruptionBudget{
+		ObjectMeta: metav1.ObjectMeta{Name: "mid", Namespace: "default"},
+		Spec:       policy.PodDisruptionBudgetSpec{Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "mid"}}},
+	}
+
+	tests := []struct {
+		name          string
+		need          int
+		pdbs          []*policy.PodDisruptionBudget
+		wantVictims   []string
+		wantViolating int
+		wantOK        bool
+	}{
+		{"minimal", 2, nil, []string{"mid"}, 0, true},
+		{"spare the budget", 2, []*policy.PodDisruptionBudget{pdb}, []string{"high", "low"}, 0, true},
+		{"violate the budget", 4, []*policy.PodDisruptionBudget{pdb}, []string{"mid", "high", "low"}, 1, true},
+		{"not enough", 5, nil, nil, 0, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sim := &fakeSimulator{need: test.need, gpus: gpus}
+			victims, violating, ok := SelectVictims("genius/", []*v1.Pod{low, mid, high}, test.pdbs, sim)
+			if ok != test.wantOK {
+				t.Fatalf("expected ok %v, got %v", test.wantOK, ok)
+			}
+			if got := names(victims); !reflect.DeepEqual(got, test.wantVictims) || violating != test.wantViolating {
+				t.Errorf("expected victims %v with %v violating, got %v with %v", test.wantVictims, test.wantViolating, got, violating)
+			}
+		})
+	}
+}
+
+func TestSelectCandidate(t *testing.T) {
+	if SelectCandidate("genius/", nil) != nil {
+		t.Errorf("expected no candidate")
+	}
+	candidates := []*Candidate{
+		{NodeName: "violating", Pods: []*v1.Pod{newPod("a", 1, nil)}, NumPDBViolations: 1},
+		{NodeName: "high", Pods: []*v1.Pod{newPod("b", 3, nil)}},
+		{NodeName: "many", Pods: []*v1.Pod{newPod("c", 1, nil), newPod("d", 1, nil)}},
+		{NodeName: "few", Pods: []*v1.Pod{newPod("e", 1, nil)}},
+	}
+	if got := SelectCandidate("genius/", candidates); got.Name() != "few" {
+		t.Errorf("expected node few, got %v", got.Name())
+	}
+}
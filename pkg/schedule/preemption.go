@@ -0,0 +1,150 @@
+package schedule
+
+import (
+	"context"
+	"github.com/genius/pkg/schedule/filter"
+	"github.com/genius/pkg/schedule/preempt"
+	"github.com/genius/pkg/schedule/reserve"
+	v1 "k8s.io/api/core/v1"
+	policy "k8s.io/api/policy/v1beta1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/klog/v2"
+	"k8s.io/kubernetes/pkg/scheduler/core"
+	"k8s.io/kubernetes/pkg/scheduler/framework"
+	"k8s.io/kubernetes/pkg/scheduler/framework/plugins/defaultpreemption"
+)
+
+// PostFilter preempts the pods holding GPUs on a single node, if the pod fits
+// the node once they are gone, and nominates the node for the pod. Only the
+// pods with a lower priority label, see sort.Less, are preempted, and the
+// victims are chosen by all the filters on the GPUs they free, which follow
+// the removed pods through RemovePod. A pod requesting no GPU is left to the
+// default preemption.
+func (g *Genius) PostFilter(ctx context.Context, state *framework.CycleState, pod *v1.Pod, m framework.NodeToStatusMap) (*framework.PostFilterResult, *framework.Status) {
+	if g.skipped(state) {
+		return nil, framework.NewStatus(framework.Unschedulable, "pod requests no GPU")
+	}
+
+	// The nominated node of the pod may have been updated by the last preemption.
+	latest, err := g.podLister.Pods(pod.Namespace).Get(pod.Name)
+	if err != nil {
+		klog.Errorf("getting pod %v to preempt for error: %v", pod.Name, err)
+		return nil, framework.AsStatus(err)
+	}
+	pod = latest
+	nodeInfos := g.handle.SnapshotSharedLister().NodeInfos()
+	if !defaultpreemption.PodEligibleToPreemptOthers(pod, nodeInfos, m[pod.Status.NominatedNodeName]) {
+		return nil, framework.NewStatus(framework.Unschedulable, "pod is not eligible to preempt")
+	}
+
+	candidates, err := g.findCandidates(ctx, state, pod, m)
+	if err != nil {
+		klog.Errorf("finding the nodes to preempt for pod %v error: %v", pod.Name, err)
+		return nil, framework.AsStatus(err)
+	}
+	best := preempt.SelectCandidate(*g.args.LabelPrefix, candidates)
+	if best == nil {
+		return nil, framework.NewStatus(framework.Unschedulable, "preempting the pods holding GPUs does not help")
+	}
+
+	klog.Infof("preempting %v pod(s) holding GPUs on node %v for pod %v", len(best.Pods), best.NodeName, pod.Name)
+	if err := defaultpreemption.PrepareCandidate(best, g.handle, g.handle.ClientSet(), pod); err != nil {
+		klog.Errorf("preempting pods on node %v for pod %v error: %v", best.NodeName, pod.Name, err)
+		return nil, framework.AsStatus(err)
+	}
+	return &framework.PostFilterResult{NominatedNodeName: best.NodeName}, framework.NewStatus(framework.Success)
+}
+
+// findCandidates finds the victims on each node which the filters do not
+// reject for good.
+func (g *Genius) findCandidates(ctx context.Context, state *framework.CycleState, pod *v1.Pod, m framework.NodeToStatusMap) ([]*preempt.Candidate, error) {
+	nodes, err := g.handle.SnapshotSharedLister().NodeInfos().List()
+	if err != nil {
+		return nil, err
+	}
+	var pdbs []*policy.PodDisruptionBudget
+	if g.pdbLister != nil {
+		if pdbs, err = g.pdbLister.List(labels.Everything()); err != nil {
+			return nil, err
+		}
+	}
+
+	var candidates []*preempt.Candidate
+	for _, nodeInfo := range nodes {
+		if m[nodeInfo.Node().Name].Code() == framework.UnschedulableAndUnresolvable {
+			continue
+		}
+		var potentialVictims []*v1.Pod
+		for _, p := range nodeInfo.Pods {
+			if g.holdsGPUs(p.Pod) && preempt.CanPreempt(*g.args.LabelPrefix, pod, p.Pod) {
+				potentialVictims = append(potentialVictims, p.Pod)
+			}
+		}
+		if len(potentialVictims) == 0 {
+			continue
+		}
+
+		sim := &nodeSimulator{
+			ctx:      ctx,
+			handle:   g.handle.PreemptHandle(),
+			state:    state.Clone(),
+			pod:      pod,
+			nodeInfo: nodeInfo.Clone(),
+		}
+		victims, numViolating, ok := preempt.SelectVictims(*g.args.LabelPrefix, potentialVictims, pdbs, sim)
+		if !ok {
+			continue
+		}
+		klog.V(4).Infof("pod %v fits node %v by preempting %v pod(s)", pod.Name, nodeInfo.Node().Name, len(victims))
+		candidates = append(candidates, &preempt.Candidate{
+			NodeName:         nodeInfo.Node().Name,
+			Pods:             victims,
+			NumPDBViolations: numViolating,
+		})
+	}
+	return candidates, nil
+}
+
+// holdsGPUs judges whether the pod holds any GPU, by the extended resource it
+// requests, the GPUs assigned to it, or its reservation.
+func (g *Genius) holdsGPUs(pod *v1.Pod) bool {
+	if filter.PodGPURequest(v1.ResourceName(g.args.GPUResourceName), pod) > 0 {
+		return true
+	}
+	if pod.GetAnnotations()[*g.args.LabelPrefix+reserve.AssignedGPUsAnnotation] != "" {
+		return true
+	}
+	return g.ledger.Get(pod.UID) != nil
+}
+
+// nodeSimulator simulates the preemption on a copy of the node info and the
+// cycle state, running the filters of all the plugins.
+type nodeSimulator struct {
+	ctx      context.Context
+	handle   framework.PreemptHandle
+	state    *framework.CycleState
+	pod      *v1.Pod
+	nodeInfo *framework.NodeInfo
+}
+
+var _ preempt.Simulator = &nodeSimulator{}
+
+func (s *nodeSimulator) RemovePod(pod *v1.Pod) error {
+	if err := s.nodeInfo.RemovePod(pod); err != nil {
+		return err
+	}
+	return s.handle.RunPreFilterExtensionRemovePod(s.ctx, s.state, s.pod, pod, s.nodeInfo).AsError()
+}
+
+func (s *nodeSimulator) AddPod(pod *v1.Pod) error {
+	s.nodeInfo.AddPod(pod)
+	return s.handle.RunPreFilterExtensionAddPod(s.ctx, s.state, s.pod, pod, s.nodeInfo).AsError()
+}
+
+func (s *nodeSimulator) Fits() bool {
+	fits, _, err := core.PodPassesFiltersOnNode(s.ctx, s.handle, s.state, s.pod, s.nodeInfo)
+	if err != nil {
+		klog.Warningf("simulating preemption on node %v for pod %v error: %v", s.nodeInfo.Node().Name, s.pod.Name, err)
+	}
+	return fits
+}
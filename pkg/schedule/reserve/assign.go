@@ -0,0 +1,101 @@
+package reserve
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/genius/pkg/schedule/filter"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/kubernetes/pkg/scheduler/framework"
+	"strconv"
+	"strings"
+)
+
+const (
+	// AssignedGPUsAnnotation is the name of the pod annotation listing the
+	// GPUs assigned to the pod, prefixed by the label prefix set in the plugin
+	// args. Its value fits NVIDIA_VISIBLE_DEVICES, such as "GPU-uuid1,GPU-uuid2".
+	AssignedGPUsAnnotation = "assigned-gpus"
+)
+
+// Clone implements framework.StateData, so that the reservation of a pod can
+// be passed from the reserve phase to the pre-bind phase.
+func (r *Reservation) Clone() framework.StateData {
+	c := *r
+	c.GPUs = append([]GPU(nil), r.GPUs...)
+	return &c
+}
+
+// Devices returns the reserved GPUs in the format of NVIDIA_VISIBLE_DEVICES.
+// The GPUs are listed by UUID, or by index if any of the UUIDs is unknown.
+func (r *Reservation) Devices() string {
+	uuids := make([]string, 0, len(r.GPUs))
+	indices := make([]string, 0, len(r.GPUs))
+	for _, gpu := range r.GPUs {
+		uuids = append(uuids, gpu.UUID)
+		indices = append(indices, strconv.FormatUint(uint64(gpu.ID), 10))
+	}
+	for _, uuid := range uuids {
+		if uuid == "" {
+			return strings.Join(indices, ",")
+		}
+	}
+	return strings.Join(uuids, ",")
+}
+
+// AssignGPUs patches the reserved GPUs into the annotation of the pod, so that
+// a device plugin or a container runtime hook can expose exactly these GPUs
+// to the containers.
+func AssignGPUs(ctx context.Context, client kubernetes.Interface, pod *v1.Pod, annotation string, r *Reservation) error {
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]string{
+				annotation: r.Devices(),
+			},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("encoding patch error: %v", err)
+	}
+
+	_, err = client.CoreV1().Pods(pod.Namespace).Patch(ctx, pod.Name, k8stypes.MergePatchType, patch, metav1.PatchOptions{})
+	if err != nil {
+		return fmt.Errorf("patching annotation %v of pod %v/%v error: %v", annotation, pod.Namespace, pod.Name, err)
+	}
+	return nil
+}
+
+// AssignedGPUs returns the GPUs listed in the annotation of the pod, along
+// with the part of each GPU the pod takes according to its requirements, as
+// SelectGPUs reserves them. The memory-total is shared evenly by the GPUs,
+// since how it has been split is not recorded. nil is returned if the pod
+// has not been assigned any GPU.
+func AssignedGPUs(pod *v1.Pod, annotation string, req *filter.GPURequirements) []GPU {
+	var gpus []GPU
+	for _, device := range strings.Split(pod.GetAnnotations()[annotation], ",") {
+		device = strings.TrimSpace(device)
+		if device == "" {
+			continue
+		}
+		if id, err := strconv.ParseUint(device, 10, 0); err == nil {
+			gpus = append(gpus, GPU{ID: uint(id)})
+		} else {
+			gpus = append(gpus, GPU{UUID: device})
+		}
+	}
+
+	for i := range gpus {
+		switch {
+		case req.MemoryEach != 0:
+			gpus[i].MemoryMB = req.MemoryEach
+		case req.MemoryTotal != 0:
+			gpus[i].MemoryMB = (req.MemoryTotal + uint64(len(gpus)) - 1) / uint64(len(gpus))
+		default:
+			gpus[i].Whole = true
+		}
+	}
+	return gpus
+}
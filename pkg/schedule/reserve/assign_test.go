@@ -0,0 +1,84 @@
+package reserve
+
+import (
+	"context"
+	"github.com/genius/pkg/schedule/filter"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"reflect"
+	"testing"
+)
+
+func TestReservationDevices(t *testing.T) {
+	tests := []struct {
+		name string
+		gpus []GPU
+		want string
+	}{
+		{"uuids", []GPU{{UUID: "GPU-a", ID: 1}, {UUID: "GPU-b", ID: 0}}, "GPU-a,GPU-b"},
+		{"indices", []GPU{{UUID: "GPU-a", ID: 1}, {ID: 0}}, "1,0"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := &Reservation{GPUs: test.gpus}
+			if got := r.Devices(); got != test.want {
+				t.Errorf("expected %q, got %q", test.want, got)
+			}
+		})
+	}
+}
+
+func TestAssignGPUs(t *testing.T) {
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Name:        "pod",
+		Namespace:   "default",
+		Annotations: map[string]string{"foo": "bar"},
+	}}
+	client := fake.NewSimpleClientset(pod)
+
+	r := &Reservation{GPUs: []GPU{{UUID: "GPU-a"}, {UUID: "GPU-b"}}}
+	if err := AssignGPUs(context.TODO(), client, pod, "genius/assigned-gpus", r); err != nil {
+		t.Fatalf("assigning GPUs error: %v", err)
+	}
+
+	got, err := client.CoreV1().Pods("default").Get(context.TODO(), "pod", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("getting pod error: %v", err)
+	}
+	if got.Annotations["genius/assigned-gpus"] != "GPU-a,GPU-b" || got.Annotations["foo"] != "bar" {
+		t.Errorf("unexpected annotations %v", got.Annotations)
+	}
+
+	missing := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "missing", Namespace: "default"}}
+	if err := AssignGPUs(context.TODO(), client, missing, "genius/assigned-gpus", r); err == nil {
+		t.Errorf("expected an error assigning GPUs to a missing pod")
+	}
+}
+
+func TestAssignedGPUs(t *testing.T) {
+	tests := []struct {
+		name       string
+		annotation string
+		req        *filter.GPURequirements
+		want       []GPU
+	}{
+		{"none", "", &filter.GPURequirements{}, nil},
+		{"uuids", "GPU-a, GPU-b", &filter.GPURequirements{MemoryEach: 4000},
+			[]GPU{{UUID: "GPU-a", MemoryMB: 4000}, {UUID: "GPU-b", MemoryMB: 4000}}},
+		{"indices", "1,0", &filter.GPURequirements{MemoryTotal: 5000},
+			[]GPU{{ID: 1, MemoryMB: 2500}, {ID: 0, MemoryMB: 2500}}},
+		{"whole", "GPU-a", &filter.GPURequirements{}, []GPU{{UUID: "GPU-a", Whole: true}}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{}}}
+			if test.annotation != "" {
+				pod.Annotations["genius/assigned-gpus"] = test.annotation
+			}
+			if got := AssignedGPUs(pod, "genius/assigned-gpus", test.req); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("expected %+v, got %+v", test.want, got)
+			}
+		})
+	}
+}
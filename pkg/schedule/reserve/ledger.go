@@ -0,0 +1,232 @@
+package reserve
+
+import (
+	"github.com/genius/pkg/types"
+	"github.com/observerward/pkg/scraper"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+	"sync"
+	"time"
+)
+
+// GPU is the part of a GPU reserved by a pod.
+type GPU struct {
+	UUID string
+	ID   uint
+	// MemoryMB is the reserved memory of the GPU.
+	MemoryMB uint64
+	// Whole tells that the pod takes the whole GPU, so that the GPU is hidden
+	// from the other pods until the reservation is released.
+	Whole bool
+}
+
+// Reservation is the GPUs reserved by an assumed or bound pod on a node.
+type Reservation struct {
+	// Pod is the namespaced name of the pod, for logging.
+	Pod        string
+	Node       string
+	GPUs       []GPU
+	ReservedAt time.Time
+}
+
+// Ledger keeps the GPUs reserved by the pods which have been assumed or bound,
+// but whose usage has not shown up in the GPU metrics yet. Without it, all
+// the pods scheduled between two metrics refreshes would see the same free
+// GPU memory, and a burst of pods would land on the same GPU.
+type Ledger struct {
+	lock         sync.RWMutex
+	reservations map[k8stypes.UID]*Reservation
+}
+
+// NewLedger returns an empty ledger.
+func NewLedger() *Ledger {
+	return &Ledger{
+		reservations: map[k8stypes.UID]*Reservation{},
+	}
+}
+
+// Reserve records the reservation of the pod, replacing the previous one if any.
+func (l *Ledger) Reserve(uid k8stypes.UID, r *Reservation) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.reservations[uid] = r
+	klog.V(3).Infof("reserved %v GPU(s) on node %v for pod %v", len(r.GPUs), r.Node, r.Pod)
+}
+
+// Release removes the reservation of the pod. It is a no-op if the pod has
+// reserved nothing.
+func (l *Ledger) Release(uid k8stypes.UID) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if r, ok := l.reservations[uid]; ok {
+		delete(l.reservations, uid)
+		klog.V(3).Infof("released the GPU reservation on node %v of pod %v", r.Node, r.Pod)
+	}
+}
+
+// Get returns the reservation of the pod, or nil if there is none.
+func (l *Ledger) Get(uid k8stypes.UID) *Reservation {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+	return l.reservations[uid]
+}
+
+// Len returns the number of reservations.
+func (l *Ledger) Len() int {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+	return len(l.reservations)
+}
+
+// Expire releases the reservations which the metrics collected at collectedAt
+// have caught up with, that is, those made more than grace before collectedAt.
+// The grace period covers the time a pod takes to start using its GPUs.
+func (l *Ledger) Expire(collectedAt time.Time, grace time.Duration) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	for uid, r := range l.reservations {
+		if collectedAt.After(r.ReservedAt.Add(grace)) {
+			delete(l.reservations, uid)
+			klog.V(3).Infof("GPU metrics have caught up with the reservation on node %v of pod %v, releasing it", r.Node, r.Pod)
+		}
+	}
+}
+
+// Adjust returns a view of the metrics with the reserved memory subtracted
+// from the free memory, and the GPUs reserved as a whole left out. The
+// metrics are never modified: the nodes with reservations are copied, while
+// the others are shared with the metrics.
+func (l *Ledger) Adjust(metrics *types.GPUMetricsWithProm) *types.GPUMetricsWithProm {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+	if len(l.reservations) == 0 {
+		return metrics
+	}
+
+	reserved := map[string][]GPU{}
+	for _, r := range l.reservations {
+		reserved[r.Node] = append(reserved[r.Node], r.GPUs...)
+	}
+
+	adjusted := make(types.GPUMetricsWithProm, len(*metrics))
+	for node, gpuMetrics := range *metrics {
+		gpus, ok := reserved[node]
+		if !ok || gpuMetrics == nil {
+			adjusted[node] = gpuMetrics
+			continue
+		}
+		adjusted[node] = adjustGPUs(gpuMetrics, gpus)
+	}
+	return &adjusted
+}
+
+func adjustGPUs(gpuMetrics *scraper.GPUMetrics, reserved []GPU) *scraper.GPUMetrics {
+	res := &scraper.GPUMetrics{}
+	for _, gpu := range gpuMetrics.GPUs {
+		c := *gpu
+		whole := false
+		for _, r := range reserved {
+			if !r.matches(gpu) {
+				continue
+			}
+			whole = whole || r.Whole
+			if r.MemoryMB >= c.FreeGlobalMemory {
+				c.UsedGlobalMemory += c.FreeGlobalMemory
+				c.FreeGlobalMemory = 0
+			} else {
+				c.UsedGlobalMemory += r.MemoryMB
+				c.FreeGlobalMemory -= r.MemoryMB
+			}
+		}
+		if !whole {
+			res.GPUs = append(res.GPUs, &c)
+		}
+	}
+	return res
+}
+
+// AddGPUs returns a view of the metrics with the GPUs taken by a pod on the
+// node subtracted, in the same way as Adjust. The metrics are never modified.
+func AddGPUs(metrics *types.GPUMetricsWithProm, node string, gpus []GPU) *types.GPUMetricsWithProm {
+	gpuMetrics := (*metrics)[node]
+	if gpuMetrics == nil || len(gpus) == 0 {
+		return metrics
+	}
+	return withNode(metrics, node, adjustGPUs(gpuMetrics, gpus))
+}
+
+// RemoveGPUs returns a view of the metrics with the GPUs taken by a pod on the
+// node given back, that is, the memory taken is freed, and a GPU taken as a
+// whole is entirely free. Since a GPU taken as a whole may have been left out
+// by Adjust, it is added back from available, the GPUs of the node before
+// any reservation, if it is not in the metrics. The metrics are never modified.
+func RemoveGPUs(metrics *types.GPUMetricsWithProm, node string, gpus []GPU, available []*scraper.MetricsSnapshotPerGPU) *types.GPUMetricsWithProm {
+	gpuMetrics := (*metrics)[node]
+	if gpuMetrics == nil || len(gpus) == 0 {
+		return metrics
+	}
+
+	res := &scraper.GPUMetrics{}
+	found := make([]bool, len(gpus))
+	for _, gpu := range gpuMetrics.GPUs {
+		c := *gpu
+		for i := range gpus {
+			if gpus[i].matches(gpu) {
+				found[i] = true
+				gpus[i].release(&c)
+			}
+		}
+		res.GPUs = append(res.GPUs, &c)
+	}
+	for i := range gpus {
+		if found[i] || !gpus[i].Whole {
+			continue
+		}
+		for _, gpu := range available {
+			if gpus[i].matches(gpu) {
+				c := *gpu
+				gpus[i].release(&c)
+				res.GPUs = append(res.GPUs, &c)
+				break
+			}
+		}
+	}
+	return withNode(metrics, node, res)
+}
+
+// release frees the part of the GPU taken. The used memory is never freed
+// below zero, since the metrics may not have caught up with the usage.
+func (g *GPU) release(gpu *scraper.MetricsSnapshotPerGPU) {
+	if g.Whole {
+		if gpu.StaticAttr.MemorySizeMB != 0 {
+			gpu.FreeGlobalMemory = gpu.StaticAttr.MemorySizeMB
+		} else {
+			gpu.FreeGlobalMemory += gpu.UsedGlobalMemory
+		}
+		gpu.UsedGlobalMemory = 0
+		return
+	}
+	freed := min(g.MemoryMB, gpu.UsedGlobalMemory)
+	gpu.UsedGlobalMemory -= freed
+	gpu.FreeGlobalMemory += freed
+}
+
+// withNode returns a copy of the metrics with the metrics of the node replaced.
+// The other nodes are shared with the metrics.
+func withNode(metrics *types.GPUMetricsWithProm, node string, gpuMetrics *scraper.GPUMetrics) *types.GPUMetricsWithProm {
+	res := make(types.GPUMetricsWithProm, len(*metrics))
+	for k, v := range *metrics {
+		res[k] = v
+	}
+	res[node] = gpuMetrics
+	return &res
+}
+
+// matches tells whether the reservation is of the GPU. The GPUs are told
+// apart by UUID, and by ID if the metrics source does not report the UUIDs.
+func (g *GPU) matches(gpu *scraper.MetricsSnapshotPerGPU) bool {
+	if g.UUID != "" && gpu.StaticAttr.UUID != "" {
+		return g.UUID == gpu.StaticAttr.UUID
+	}
+	return g.ID == gpu.StaticAttr.ID
+}
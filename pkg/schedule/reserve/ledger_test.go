@@ -0,0 +1,120 @@
+package reserve
+
+import (
+	"github.com/genius/pkg/types"
+	"github.com/observerward/pkg/scraper"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	"testing"
+	"time"
+)
+
+func newGPU(id uint, uuid string, free uint64) *scraper.MetricsSnapshotPerGPU {
+	return &scraper.MetricsSnapshotPerGPU{
+		StaticAttr:       scraper.GPUStaticAttr{ID: id, UUID: uuid, MemorySizeMB: 16000, Model: "Tesla T4"},
+		FreeGlobalMemory: free,
+		UsedGlobalMemory: 16000 - free,
+	}
+}
+
+func newMetrics() *types.GPUMetricsWithProm {
+	return &types.GPUMetricsWithProm{
+		"node-a": {GPUs: []*scraper.MetricsSnapshotPerGPU{newGPU(0, "GPU-a0", 10000), newGPU(1, "GPU-a1", 8000)}},
+		"node-b": {GPUs: []*scraper.MetricsSnapshotPerGPU{newGPU(0, "GPU-b0", 16000)}},
+	}
+}
+
+func TestLedgerAdjust(t *testing.T) {
+	l := NewLedger()
+	metrics := newMetrics()
+	if got := l.Adjust(metrics); got != metrics {
+		t.Errorf("expected the metrics to be returned as is without reservations")
+	}
+
+	l.Reserve("pod-1", &Reservation{Pod: "default/pod-1", Node: "node-a", GPUs: []GPU{{UUID: "GPU-a0", MemoryMB: 4000}}})
+	l.Reserve("pod-2", &Reservation{Pod: "default/pod-2", Node: "node-a", GPUs: []GPU{{UUID: "GPU-a0", MemoryMB: 7000}}})
+	l.Reserve("pod-3", &Reservation{Pod: "default/pod-3", Node: "node-a", GPUs: []GPU{{UUID: "GPU-a1", MemoryMB: 8000, Whole: true}}})
+
+	adjusted := l.Adjust(metrics)
+	gpus := (*adjusted)["node-a"].GPUs
+	if len(gpus) != 1 {
+		t.Fatalf("expected the whole reserved GPU to be left out, got %v GPUs", len(gpus))
+	}
+	if gpus[0].FreeGlobalMemory != 0 || gpus[0].UsedGlobalMemory != 16000 {
+		t.Errorf("expected the over-reserved GPU to have no free memory, got free %v, used %v",
+			gpus[0].FreeGlobalMemory, gpus[0].UsedGlobalMemory)
+	}
+	if (*adjusted)["node-b"] != (*metrics)["node-b"] {
+		t.Errorf("expected the node without reservations to be shared")
+	}
+
+	// The metrics must not be modified.
+	if got := (*metrics)["node-a"].GPUs; len(got) != 2 || got[0].FreeGlobalMemory != 10000 {
+		t.Errorf("the metrics have been modified")
+	}
+
+	l.Release("pod-2")
+	l.Release("pod-3")
+	gpus = (*l.Adjust(metrics))["node-a"].GPUs
+	if len(gpus) != 2 || gpus[0].FreeGlobalMemory != 6000 {
+		t.Errorf("expected 2 GPUs with 6000MB free memory on the first one after releasing, got %v", len(gpus))
+	}
+}
+
+func TestLedgerAdjustByID(t *testing.T) {
+	l := NewLedger()
+	metrics := &types.GPUMetricsWithProm{
+		"node-a": {GPUs: []*scraper.MetricsSnapshotPerGPU{newGPU(0, "", 10000), newGPU(1, "", 10000)}},
+	}
+	l.Reserve("pod-1", &Reservation{Node: "node-a", GPUs: []GPU{{ID: 1, MemoryMB: 3000}}})
+
+	gpus := (*l.Adjust(metrics))["node-a"].GPUs
+	if gpus[0].FreeGlobalMemory != 10000 || gpus[1].FreeGlobalMemory != 7000 {
+		t.Errorf("expected the GPU to be matched by ID, got free memory %v and %v",
+			gpus[0].FreeGlobalMemory, gpus[1].FreeGlobalMemory)
+	}
+}
+
+func TestLedgerExpire(t *testing.T) {
+	l := NewLedger()
+	now := time.Now()
+	l.Reserve("old", &Reservation{Node: "node-a", ReservedAt: now.Add(-2 * time.Minute)})
+	l.Reserve("new", &Reservation{Node: "node-a", ReservedAt: now.Add(-30 * time.Second)})
+
+	l.Expire(now, time.Minute)
+	if l.Len() != 1 || l.Get(k8stypes.UID("new")) == nil {
+		t.Errorf("expected only the reservation within the grace period to be kept, got %v", l.Len())
+	}
+}
+
+func TestAddRemoveGPUs(t *testing.T) {
+	metrics := newMetrics()
+	if got := AddGPUs(metrics, "node-c", []GPU{{ID: 0, MemoryMB: 1000}}); got != metrics {
+		t.Errorf("expected the metrics to be returned as is for a node without GPU data")
+	}
+
+	gpus := []GPU{{UUID: "GPU-a0", MemoryMB: 4000}, {UUID: "GPU-a1", MemoryMB: 8000, Whole: true}}
+	added := AddGPUs(metrics, "node-a", gpus)
+	if got := (*added)["node-a"].GPUs; len(got) != 1 || got[0].FreeGlobalMemory != 6000 {
+		t.Fatalf("expected 1 GPU with 6000MB free memory after adding, got %v GPUs", len(got))
+	}
+
+	removed := RemoveGPUs(added, "node-a", gpus, (*metrics)["node-a"].GPUs)
+	got := (*removed)["node-a"].GPUs
+	if len(got) != 2 {
+		t.Fatalf("expected the whole GPU to be added back, got %v GPUs", len(got))
+	}
+	if got[0].FreeGlobalMemory != 10000 || got[0].UsedGlobalMemory != 6000 {
+		t.Errorf("expected the memory taken to be freed, got free %v, used %v", got[0].FreeGlobalMemory, got[0].UsedGlobalMemory)
+	}
+	if got[1].FreeGlobalMemory != 16000 || got[1].UsedGlobalMemory != 0 {
+		t.Errorf("expected the whole GPU to be entirely free, got free %v, used %v", got[1].FreeGlobalMemory, got[1].UsedGlobalMemory)
+	}
+	if (*removed)["node-b"] != (*metrics)["node-b"] {
+		t.Errorf("expected the other nodes to be shared")
+	}
+
+	// The metrics must not be modified.
+	if got := (*metrics)["node-a"].GPUs; len(got) != 2 || got[0].FreeGlobalMemory != 10000 || got[1].FreeGlobalMemory != 8000 {
+		t.Errorf("the metrics have been modified")
+	}
+}
@@ -0,0 +1,155 @@
+package reserve
+
+import (
+	"fmt"
+	"github.com/genius/pkg/apis/v1beta1"
+	"github.com/genius/pkg/models"
+	"github.com/genius/pkg/schedule/filter"
+	"github.com/observerward/pkg/scraper"
+	"sort"
+)
+
+// SelectGPUs picks the GPUs of a node for a pod with the requirements, and
+// returns the part of each GPU the pod reserves. Only the GPUs satisfying all
+// the per-GPU requirements are picked, in the descending order of their free
+// memory, or in the ascending order if the pod packs its GPUs. If the pod
+// requires the GPUs of the same model, they are picked from the first model
+// which has enough of them.
+//   - If the pod requires a number of GPUs, it reserves on each of them the
+//     larger of the memory-each and an even share of the memory-total, which
+//     each GPU must have free, or else the whole GPUs.
+//   - If the pod only requires the memory-total, it reserves the memory from
+//     the GPUs in order, until the memory-total is reached.
+//   - Otherwise the pod reserves nothing.
+//
+// The requirements of the disabled filters are not checked, while the memory
+// is reserved all the same. Fewer GPUs than required are returned if the node
+// cannot satisfy the pod, which Satisfies tells. The labels of the GPUs on the
+// node are keyed by the GPU id.
+func SelectGPUs(req *filter.GPURequirements, gpus []*scraper.MetricsSnapshotPerGPU, gpuLabels map[uint]map[string]string, registry *models.Registry,
+	enabled v1beta1.FilterArgs) []GPU {
+	number, _ := req.Number()
+	memory := memoryEach(req.MemoryEach, req.MemoryTotal, number)
+	// The memory each GPU must have free, by the enabled filters.
+	var checkedEach, checkedTotal uint64
+	if filter.Enabled(enabled.MemoryEach) {
+		checkedEach = req.MemoryEach
+	}
+	if filter.Enabled(enabled.MemoryTotal) {
+		checkedTotal = req.MemoryTotal
+	}
+	required := memoryEach(checkedEach, checkedTotal, number)
+	var candidates []*scraper.MetricsSnapshotPerGPU
+	for _, gpu := range gpus {
+		if !filter.GPUFits(gpu, req, gpuLabels[gpu.StaticAttr.ID], registry, enabled) {
+			continue
+		}
+		if required != 0 && !filter.GPUFitsMemory(gpu, required) {
+			continue
+		}
+		candidates = append(candidates, gpu)
+	}
+	pack := req.Affinity.Placement == filter.PackPlacement
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].FreeGlobalMemory != candidates[j].FreeGlobalMemory {
+			return (candidates[i].FreeGlobalMemory > candidates[j].FreeGlobalMemory) != pack
+		}
+		return candidates[i].StaticAttr.ID < candidates[j].StaticAttr.ID
+	})
+	if req.Affinity.SameModel && filter.Enabled(enabled.Model) {
+		candidates = sameModel(candidates, number, req.MemoryTotal)
+	}
+
+	var selected []GPU
+	switch {
+	case number > 0:
+		for _, gpu := range candidates {
+			if len(selected) == number {
+				break
+			}
+			g := GPU{UUID: gpu.StaticAttr.UUID, ID: gpu.StaticAttr.ID, MemoryMB: min(memory, gpu.FreeGlobalMemory)}
+			if memory == 0 {
+				g.MemoryMB, g.Whole = gpu.FreeGlobalMemory, true
+			}
+			selected = append(selected, g)
+		}
+	case req.MemoryTotal != 0:
+		remaining := req.MemoryTotal
+		for _, gpu := range candidates {
+			if remaining == 0 {
+				break
+			}
+			if gpu.FreeGlobalMemory == 0 {
+				continue
+			}
+			memory := min(remaining, gpu.FreeGlobalMemory)
+			selected = append(selected, GPU{UUID: gpu.StaticAttr.UUID, ID: gpu.StaticAttr.ID, MemoryMB: memory})
+			remaining -= memory
+		}
+	}
+	return selected
+}
+
+// Satisfies judges whether the GPUs selected by SelectGPUs satisfy the pod,
+// namely there are as many of them as required, and they hold the
+// memory-total, unless the filters of them are disabled. If not, the reason
+// tells what is short.
+func Satisfies(req *filter.GPURequirements, gpus []GPU, enabled v1beta1.FilterArgs) (bool, string) {
+	if number, _ := req.Number(); filter.Enabled(enabled.GPUNumber) && len(gpus) < number {
+		return false, fmt.Sprintf("only %v/%v GPUs satisfy the requirements together", len(gpus), number)
+	}
+	memory := uint64(0)
+	for _, gpu := range gpus {
+		memory += gpu.MemoryMB
+	}
+	if filter.Enabled(enabled.MemoryTotal) && memory < req.MemoryTotal {
+		return false, fmt.Sprintf("only %vMB of the memory-total %vMB could be reserved", memory, req.MemoryTotal)
+	}
+	return true, ""
+}
+
+// memoryEach returns the memory the pod requiring the number of GPUs reserves
+// on each of them, or 0 if it reserves the whole GPUs.
+func memoryEach(each, total uint64, number int) uint64 {
+	if number == 0 {
+		return each
+	}
+	share := (total + uint64(number) - 1) / uint64(number)
+	if each > share {
+		return each
+	}
+	return share
+}
+
+// sameModel returns the ordered candidates of the first model which has the
+// number of GPUs, or the memory-total if no number is required.
+func sameModel(candidates []*scraper.MetricsSnapshotPerGPU, number int, memoryTotal uint64) []*scraper.MetricsSnapshotPerGPU {
+	var models []string
+	byModel := map[string][]*scraper.MetricsSnapshotPerGPU{}
+	for _, gpu := range candidates {
+		model := gpu.StaticAttr.Model
+		if _, ok := byModel[model]; !ok {
+			models = append(models, model)
+		}
+		byModel[model] = append(byModel[model], gpu)
+	}
+
+	for _, model := range models {
+		gpus := byModel[model]
+		free := uint64(0)
+		for _, gpu := range gpus {
+			free += gpu.FreeGlobalMemory
+		}
+		if (number > 0 && len(gpus) >= number) || (number == 0 && free >= memoryTotal) {
+			return gpus
+		}
+	}
+	return nil
+}
+
+func min(a, b uint64) uint64 {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,145 @@
+package reserve
+
+import (
+	"github.com/genius/pkg/apis/v1beta1"
+	"github.com/genius/pkg/schedule/filter"
+	"github.com/genius/pkg/types"
+	"github.com/observerward/pkg/scraper"
+	"reflect"
+	"testing"
+)
+
+func count(n int) *int {
+	return &n
+}
+
+func TestSelectGPUs(t *testing.T) {
+	t4 := newGPU(2, "GPU-2", 12000)
+	t4.StaticAttr.Model = "Tesla T4"
+	gpus := []*scraper.MetricsSnapshotPerGPU{
+		newGPU(0, "GPU-0", 4000),
+		newGPU(1, "GPU-1", 9000),
+		t4,
+	}
+	gpus[0].StaticAttr.Model = "Tesla V100"
+	gpus[1].StaticAttr.Model = "Tesla V100"
+	gpuLabels := map[uint]map[string]string{
+		0: {types.ComputeCapabilityLabel: "7.0"},
+		1: {types.ComputeCapabilityLabel: "8.0"},
+		2: {types.ComputeCapabilityLabel: "7.5"},
+	}
+
+	tests := []struct {
+		name string
+		req  *filter.GPURequirements
+		want []GPU
+	}{
+		{
+			name: "nothing required",
+			req:  &filter.GPURequirements{},
+		},
+		{
+			name: "whole GPUs",
+			req:  &filter.GPURequirements{Count: count(2)},
+			want: []GPU{
+				{UUID: "GPU-2", ID: 2, MemoryMB: 12000, Whole: true},
+				{UUID: "GPU-1", ID: 1, MemoryMB: 9000, Whole: true},
+			},
+		},
+		{
+			name: "memory each",
+			req:  &filter.GPURequirements{Count: count(2), MemoryEach: 5000},
+			want: []GPU{
+				{UUID: "GPU-2", ID: 2, MemoryMB: 5000},
+				{UUID: "GPU-1", ID: 1, MemoryMB: 5000},
+			},
+		},
+		{
+			name: "memory total shared by the GPUs",
+			req:  &filter.GPURequirements{Count: count(3), MemoryTotal: 9000},
+			want: []GPU{
+				{UUID: "GPU-2", ID: 2, MemoryMB: 3000},
+				{UUID: "GPU-1", ID: 1, MemoryMB: 3000},
+				{UUID: "GPU-0", ID: 0, MemoryMB: 3000},
+			},
+		},
+		{
+			name: "memory each below the share of the memory total",
+			req:  &filter.GPURequirements{Count: count(2), MemoryEach: 4000, MemoryTotal: 10000},
+			want: []GPU{
+				{UUID: "GPU-2", ID: 2, MemoryMB: 5000},
+				{UUID: "GPU-1", ID: 1, MemoryMB: 5000},
+			},
+		},
+		{
+			name: "memory total only",
+			req:  &filter.GPURequirements{MemoryTotal: 15000},
+			want: []GPU{
+				{UUID: "GPU-2", ID: 2, MemoryMB: 12000},
+				{UUID: "GPU-1", ID: 1, MemoryMB: 3000},
+			},
+		},
+		{
+			name: "model",
+			req:  &filter.GPURequirements{Count: count(2), Models: filter.ModelRequirements{Allow: []string{"v100"}}},
+			want: []GPU{
+				{UUID: "GPU-1", ID: 1, MemoryMB: 9000, Whole: true},
+				{UUID: "GPU-0", ID: 0, MemoryMB: 4000, Whole: true},
+			},
+		},
+		{
+			name: "pack",
+			req:  &filter.GPURequirements{Count: count(1), MemoryEach: 3000, Affinity: filter.GPUAffinity{Placement: filter.PackPlacement}},
+			want: []GPU{{UUID: "GPU-0", ID: 0, MemoryMB: 3000}},
+		},
+		{
+			name: "same model",
+			req:  &filter.GPURequirements{Count: count(2), Affinity: filter.GPUAffinity{SameModel: true}},
+			want: []GPU{
+				{UUID: "GPU-1", ID: 1, MemoryMB: 9000, Whole: true},
+				{UUID: "GPU-0", ID: 0, MemoryMB: 4000, Whole: true},
+			},
+		},
+		{
+			name: "compute capability",
+			req:  &filter.GPURequirements{Count: count(1), MinComputeCapability: "7.5", Architectures: []string{"ampere"}},
+			want: []GPU{{UUID: "GPU-1", ID: 1, MemoryMB: 9000, Whole: true}},
+		},
+		{
+			name: "attributes",
+			req:  &filter.GPURequirements{Count: count(2), MinBandwidth: 500},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := SelectGPUs(test.req, gpus, gpuLabels, nil, v1beta1.FilterArgs{})
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("expected %+v, got %+v", test.want, got)
+			}
+		})
+	}
+}
+
+func TestSelectGPUsDisabledFilters(t *testing.T) {
+	gpus := []*scraper.MetricsSnapshotPerGPU{newGPU(0, "GPU-0", 3000), newGPU(1, "GPU-1", 3000)}
+	req := &filter.GPURequirements{Count: count(2), MemoryEach: 2000, MemoryTotal: 8000, Models: filter.ModelRequirements{Allow: []string{"v100"}}}
+
+	if got := SelectGPUs(req, gpus, nil, nil, v1beta1.FilterArgs{}); len(got) != 0 {
+		t.Errorf("expected no GPU of the model with the memory, got %+v", got)
+	}
+
+	disabled := false
+	enabled := v1beta1.FilterArgs{Model: &disabled, MemoryTotal: &disabled}
+	got := SelectGPUs(req, gpus, nil, nil, enabled)
+	want := []GPU{{UUID: "GPU-0", ID: 0, MemoryMB: 3000}, {UUID: "GPU-1", ID: 1, MemoryMB: 3000}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %+v with the model and memory-total filters disabled, got %+v", want, got)
+	}
+	if ok, reason := Satisfies(req, got, enabled); !ok {
+		t.Errorf("expected the GPUs to satisfy the enabled filters, got %v", reason)
+	}
+	if ok, _ := Satisfies(req, got, v1beta1.FilterArgs{}); ok {
+		t.Errorf("expected the GPUs short of the memory-total not to satisfy all the filters")
+	}
+}
@@ -2,48 +2,58 @@ package score
 
 import "github.com/observerward/pkg/scraper"
 
-const (
-	usedMemoryWeight         = 2
-	powerWeight              = 1
-	encoderUtilizationWeight = 1
-	decoderUtilizationWeight = 1
-)
-
-func computeDynamicScore(gpuMetrics *scraper.GPUMetrics, aggregatedMetrics *clusterAggregatedMetrics) float32 {
-	return scoreAgainstFreeMemory(gpuMetrics, aggregatedMetrics) + scoreAgainstPower(gpuMetrics, aggregatedMetrics) +
-		scoreAgainstDecoderUtilization(gpuMetrics, aggregatedMetrics) + scoreAgainstEncoderUtilization(gpuMetrics, aggregatedMetrics)
+// computeDynamicScore averages the scores of the GPUs on the node, so the node
+// must have at least one GPU.
+func computeDynamicScore(gpuMetrics *scraper.GPUMetrics, aggregatedMetrics *clusterAggregatedMetrics, weights *Weights) float32 {
+	if gpuMetrics == nil || len(gpuMetrics.GPUs) == 0 {
+		return 0
+	}
+	return scoreAgainstFreeMemory(gpuMetrics, aggregatedMetrics, weights) + scoreAgainstPower(gpuMetrics, aggregatedMetrics, weights) +
+		scoreAgainstDecoderUtilization(gpuMetrics, aggregatedMetrics, weights) + scoreAgainstEncoderUtilization(gpuMetrics, aggregatedMetrics, weights)
 }
 
-func scoreAgainstFreeMemory(gpuMetrics *scraper.GPUMetrics, aggregatedMetrics *clusterAggregatedMetrics) float32 {
+func scoreAgainstFreeMemory(gpuMetrics *scraper.GPUMetrics, aggregatedMetrics *clusterAggregatedMetrics, weights *Weights) float32 {
+	if weights.FreeMemory == 0 {
+		return 0
+	}
 	score := float32(0)
 	for _, gpu := range gpuMetrics.GPUs {
-		score += float32(gpu.FreeGlobalMemory) / float32(aggregatedMetrics.dynamic.freeGlobalMemory) * float32(aggregatedMetrics.cardsCount)
+		score += ratio(float32(gpu.FreeGlobalMemory), float32(aggregatedMetrics.dynamic.freeGlobalMemory)) * float32(aggregatedMetrics.cardsCount)
 	}
-	return score * usedMemoryWeight / float32(len(gpuMetrics.GPUs))
+	return score * float32(weights.FreeMemory) / float32(len(gpuMetrics.GPUs))
 }
 
-func scoreAgainstPower(gpuMetrics *scraper.GPUMetrics, aggregatedMetrics *clusterAggregatedMetrics) float32 {
+func scoreAgainstPower(gpuMetrics *scraper.GPUMetrics, aggregatedMetrics *clusterAggregatedMetrics, weights *Weights) float32 {
+	if weights.Power == 0 {
+		return 0
+	}
 	score := float32(0)
 	for _, gpu := range gpuMetrics.GPUs {
-		score += float32(gpu.Power) / float32(aggregatedMetrics.dynamic.power) * float32(aggregatedMetrics.cardsCount)
+		score += ratio(float32(gpu.Power), float32(aggregatedMetrics.dynamic.power)) * float32(aggregatedMetrics.cardsCount)
 	}
-	return score * powerWeight / float32(len(gpuMetrics.GPUs))
+	return score * float32(weights.Power) / float32(len(gpuMetrics.GPUs))
 }
 
-func scoreAgainstEncoderUtilization(gpuMetrics *scraper.GPUMetrics, aggregatedMetrics *clusterAggregatedMetrics) float32 {
+func scoreAgainstEncoderUtilization(gpuMetrics *scraper.GPUMetrics, aggregatedMetrics *clusterAggregatedMetrics, weights *Weights) float32 {
+	if weights.EncoderUtilization == 0 {
+		return 0
+	}
 	score := float32(0)
 	for _, gpu := range gpuMetrics.GPUs {
-		score += (1 - float32(gpu.EncoderUtilization)) / float32(aggregatedMetrics.cardsCount-aggregatedMetrics.dynamic.encoderUtilization) *
+		score += ratio(1-float32(gpu.EncoderUtilization), float32(aggregatedMetrics.cardsCount-aggregatedMetrics.dynamic.encoderUtilization)) *
 			float32(aggregatedMetrics.cardsCount)
 	}
-	return score * encoderUtilizationWeight / float32(len(gpuMetrics.GPUs))
+	return score * float32(weights.EncoderUtilization) / float32(len(gpuMetrics.GPUs))
 }
 
-func scoreAgainstDecoderUtilization(gpuMetrics *scraper.GPUMetrics, aggregatedMetrics *clusterAggregatedMetrics) float32 {
+func scoreAgainstDecoderUtilization(gpuMetrics *scraper.GPUMetrics, aggregatedMetrics *clusterAggregatedMetrics, weights *Weights) float32 {
+	if weights.DecoderUtilization == 0 {
+		return 0
+	}
 	score := float32(0)
 	for _, gpu := range gpuMetrics.GPUs {
-		score += (1 - float32(gpu.DecoderUtilization)) / float32(aggregatedMetrics.cardsCount-aggregatedMetrics.dynamic.decoderUtilization) *
+		score += ratio(1-float32(gpu.DecoderUtilization), float32(aggregatedMetrics.cardsCount-aggregatedMetrics.dynamic.decoderUtilization)) *
 			float32(aggregatedMetrics.cardsCount)
 	}
-	return score * decoderUtilizationWeight / float32(len(gpuMetrics.GPUs))
+	return score * float32(weights.DecoderUtilization) / float32(len(gpuMetrics.GPUs))
 }
@@ -0,0 +1,205 @@
+package score
+
+import (
+	"fmt"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/kubernetes/pkg/scheduler/framework"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// The names of the pod annotations overriding the score weights, prefixed by
+// the label prefix set in the plugin args, "genius/" by default.
+const (
+	// ScoreWeightsAnnotation sets the weights as comma separated name=value
+	// pairs, such as "memory=3,encoder=0,power=1".
+	ScoreWeightsAnnotation = "score-weights"
+	// WorkloadProfileAnnotation selects one of the preset weights, such as "training".
+	WorkloadProfileAnnotation = "workload-profile"
+)
+
+// The workload profiles, each of which presets the weights matching a kind
+// of GPU workload.
+const (
+	TrainingProfile  = "training"
+	InferenceProfile = "inference"
+	TranscodeProfile = "transcode"
+)
+
+var (
+	// workloadProfiles presets the weights of each profile on top of the
+	// configured weights.
+	workloadProfiles = map[string]map[string]int64{
+		// Training jobs keep the GPUs busy for long and need large memory and
+		// plenty of multiprocessors, while they never use the codecs.
+		TrainingProfile: {
+			"memory":         4,
+			"multiprocessor": 4,
+			"bandwidth":      3,
+			"freeMemory":     3,
+			"encoder":        0,
+			"decoder":        0,
+		},
+		// Inference services are latency sensitive, so they prefer the idle GPUs.
+		InferenceProfile: {
+			"multiprocessor": 3,
+			"freeMemory":     4,
+			"power":          2,
+			"encoder":        0,
+			"decoder":        0,
+		},
+		// Transcode jobs are bound by the hardware codecs.
+		TranscodeProfile: {
+			"memory":         1,
+			"multiprocessor": 1,
+			"sharedEncoder":  4,
+			"sharedDecoder":  4,
+			"freeMemory":     1,
+			"encoder":        4,
+			"decoder":        4,
+		},
+	}
+)
+
+// Bounds bounds the weights a pod can set through its annotations.
+type Bounds struct {
+	// Enabled tells whether pods can override the weights at all.
+	Enabled bool
+	// MaxWeight is the maximum of each weight set by a pod.
+	MaxWeight int64
+}
+
+// Clone implements framework.StateData, so that the weights of a pod can be
+// kept in the cycle state.
+func (w *Weights) Clone() framework.StateData {
+	c := *w
+	return &c
+}
+
+// fields returns the weights by the names used in the plugin args and the pod
+// annotations. "encoder" and "decoder" set both the static and the dynamic
+// weights of the codecs.
+func (w *Weights) fields() map[string][]*int64 {
+	return map[string][]*int64{
+		"static":             {&w.Static},
+		"dynamic":            {&w.Dynamic},
+		"memory":             {&w.Memory},
+		"multiprocessor":     {&w.Multiprocessor},
+		"sharedDecoder":      {&w.SharedDecoder},
+		"sharedEncoder":      {&w.SharedEncoder},
+		"bandwidth":          {&w.Bandwidth},
+		"freeMemory":         {&w.FreeMemory},
+		"power":              {&w.Power},
+		"encoderUtilization": {&w.EncoderUtilization},
+		"decoderUtilization": {&w.DecoderUtilization},
+		"encoder":            {&w.SharedEncoder, &w.EncoderUtilization},
+		"decoder":            {&w.SharedDecoder, &w.DecoderUtilization},
+	}
+}
+
+// OverridesWeights judges whether the pod sets its own weights through the
+// annotations.
+func OverridesWeights(labelPrefix string, pod *v1.Pod) bool {
+	_, hasProfile := pod.Annotations[labelPrefix+WorkloadProfileAnnotation]
+	_, hasOverrides := pod.Annotations[labelPrefix+ScoreWeightsAnnotation]
+	return hasProfile || hasOverrides
+}
+
+// PodWeights merges the weights the pod sets through its annotations with the
+// configured weights. The workload profile is applied first, then the weights
+// set one by one. Preset weights are capped by bounds.MaxWeight, while a weight
+// set explicitly above it is an error, as are unknown names and profiles.
+// The configured weights are returned as is if the pod sets no annotation, or
+// if the overrides are disabled, in which case the annotations are ignored.
+func PodWeights(labelPrefix string, pod *v1.Pod, defaults Weights, bounds Bounds) (Weights, error) {
+	profile, hasProfile := pod.Annotations[labelPrefix+WorkloadProfileAnnotation]
+	overrides, hasOverrides := pod.Annotations[labelPrefix+ScoreWeightsAnnotation]
+	if !hasProfile && !hasOverrides || !bounds.Enabled {
+		return defaults, nil
+	}
+
+	weights := defaults
+	fields := weights.fields()
+	if hasProfile {
+		preset, ok := workloadProfiles[profile]
+		if !ok {
+			return defaults, fmt.Errorf("unknown workload profile %q in annotation %v, must be one of %v",
+				profile, labelPrefix+WorkloadProfileAnnotation, profileNames())
+		}
+		for name, value := range preset {
+			if value > bounds.MaxWeight {
+				value = bounds.MaxWeight
+			}
+			for _, field := range fields[name] {
+				*field = value
+			}
+		}
+	}
+
+	if hasOverrides {
+		values, err := parseWeights(overrides, bounds.MaxWeight)
+		if err != nil {
+			return defaults, fmt.Errorf("parsing annotation %v error: %v", labelPrefix+ScoreWeightsAnnotation, err)
+		}
+		for _, value := range values {
+			for _, field := range fields[value.name] {
+				*field = value.value
+			}
+		}
+	}
+
+	staticTotal := weights.Memory + weights.Multiprocessor + weights.SharedDecoder + weights.SharedEncoder + weights.Bandwidth
+	dynamicTotal := weights.FreeMemory + weights.Power + weights.EncoderUtilization + weights.DecoderUtilization
+	if weights.Static*staticTotal == 0 && weights.Dynamic*dynamicTotal == 0 {
+		return defaults, fmt.Errorf("at least one of the static and dynamic scores must have a positive weight")
+	}
+	return weights, nil
+}
+
+type namedWeight struct {
+	name  string
+	value int64
+}
+
+// parseWeights parses the comma separated name=value pairs of weights. The
+// weights are returned in the order they are set, so that "encoder=0,sharedEncoder=2"
+// disables the encoder utilization only.
+func parseWeights(s string, maxWeight int64) ([]namedWeight, error) {
+	known := (&Weights{}).fields()
+	seen := map[string]bool{}
+	var values []namedWeight
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("%q is not of the form name=value", pair)
+		}
+		name := strings.TrimSpace(kv[0])
+		if _, ok := known[name]; !ok {
+			return nil, fmt.Errorf("unknown weight %q", name)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("weight %q is set more than once", name)
+		}
+		seen[name] = true
+		value, err := strconv.ParseInt(strings.TrimSpace(kv[1]), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("parsing weight %q error: %v", name, err)
+		}
+		if value < 0 || value > maxWeight {
+			return nil, fmt.Errorf("weight %q must be in the range of [0, %v], got %v", name, maxWeight, value)
+		}
+		values = append(values, namedWeight{name, value})
+	}
+	return values, nil
+}
+
+func profileNames() []string {
+	var names []string
+	for name := range workloadProfiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,122 @@
+package score
+
+import (
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"strings"
+	"testing"
+)
+
+func newAnnotatedPod(annotations map[string]string) *v1.Pod {
+	return &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod", Annotations: annotations}}
+}
+
+func TestPodWeights(t *testing.T) {
+	defaults := Weights{
+		Static: 1, Dynamic: 2,
+		Memory: 2, Multiprocessor: 2, SharedDecoder: 1, SharedEncoder: 1, Bandwidth: 2,
+		FreeMemory: 2, Power: 1, EncoderUtilization: 1, DecoderUtilization: 1,
+	}
+	bounds := Bounds{Enabled: true, MaxWeight: 10}
+
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		bounds      Bounds
+		want        func(w *Weights)
+	}{
+		{
+			name:   "no annotations",
+			bounds: Bounds{},
+		},
+		{
+			name:        "disabled",
+			annotations: map[string]string{"genius/score-weights": "memory=latency", "genius/workload-profile": "gaming"},
+			bounds:      Bounds{},
+		},
+		{
+			name:        "weights",
+			annotations: map[string]string{"genius/score-weights": "memory=3, encoder=0,power=1"},
+			bounds:      bounds,
+			want: func(w *Weights) {
+				w.Memory, w.SharedEncoder, w.EncoderUtilization, w.Power = 3, 0, 0, 1
+			},
+		},
+		{
+			name:        "weights applied in order",
+			annotations: map[string]string{"genius/score-weights": "encoder=0,sharedEncoder=5"},
+			bounds:      bounds,
+			want: func(w *Weights) {
+				w.SharedEncoder, w.EncoderUtilization = 5, 0
+			},
+		},
+		{
+			name:        "profile",
+			annotations: map[string]string{"genius/workload-profile": "transcode"},
+			bounds:      Bounds{Enabled: true, MaxWeight: 3},
+			want: func(w *Weights) {
+				w.Memory, w.Multiprocessor, w.FreeMemory = 1, 1, 1
+				w.SharedEncoder, w.SharedDecoder, w.EncoderUtilization, w.DecoderUtilization = 3, 3, 3, 3
+			},
+		},
+		{
+			name: "profile and weights",
+			annotations: map[string]string{
+				"genius/workload-profile": "training",
+				"genius/score-weights":    "bandwidth=0",
+			},
+			bounds: bounds,
+			want: func(w *Weights) {
+				w.Memory, w.Multiprocessor, w.Bandwidth, w.FreeMemory = 4, 4, 0, 3
+				w.SharedEncoder, w.SharedDecoder, w.EncoderUtilization, w.DecoderUtilization = 0, 0, 0, 0
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := PodWeights("genius/", newAnnotatedPod(test.annotations), defaults, test.bounds)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			want := defaults
+			if test.want != nil {
+				test.want(&want)
+			}
+			if got != want {
+				t.Errorf("expected weights %+v, got %+v", want, got)
+			}
+		})
+	}
+}
+
+func TestPodWeightsError(t *testing.T) {
+	bounds := Bounds{Enabled: true, MaxWeight: 10}
+	defaults := Weights{Static: 1, Memory: 1}
+
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		bounds      Bounds
+		wantErr     string
+	}{
+		{"unknown profile", map[string]string{"genius/workload-profile": "gaming"}, bounds, "unknown workload profile"},
+		{"not a pair", map[string]string{"genius/score-weights": "memory"}, bounds, "not of the form"},
+		{"empty pair", map[string]string{"genius/score-weights": "memory=1,"}, bounds, "not of the form"},
+		{"unknown weight", map[string]string{"genius/score-weights": "latency=1"}, bounds, "unknown weight"},
+		{"duplicate weight", map[string]string{"genius/score-weights": "memory=1,memory=2"}, bounds, "more than once"},
+		{"not a number", map[string]string{"genius/score-weights": "memory=high"}, bounds, "parsing weight"},
+		{"above bound", map[string]string{"genius/score-weights": "memory=11"}, bounds, "range"},
+		{"negative", map[string]string{"genius/score-weights": "memory=-1"}, bounds, "range"},
+		{"all zero", map[string]string{"genius/score-weights": "memory=0"}, bounds, "positive weight"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := PodWeights("genius/", newAnnotatedPod(test.annotations), defaults, test.bounds)
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("expected error containing %q, got %v", test.wantErr, err)
+			}
+		})
+	}
+}
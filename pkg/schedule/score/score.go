@@ -1,9 +1,11 @@
 package score
 
 import (
+	"github.com/genius/pkg/apis/v1beta1"
 	"github.com/genius/pkg/types"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/kubernetes/pkg/scheduler/framework"
+	"math"
 )
 
 type clusterAggregatedMetrics struct {
@@ -29,21 +31,82 @@ type dynamicMetrics struct {
 	memoryUtilization  uint
 }
 
-const (
-	dynamicWeight = 2
-	staticWeight  = 1
-)
+// Weights weights the static and dynamic scores of a node, and each of the
+// GPU attributes and metrics the scores are computed from.
+type Weights struct {
+	Static  int64
+	Dynamic int64
+
+	Memory         int64
+	Multiprocessor int64
+	SharedDecoder  int64
+	SharedEncoder  int64
+	Bandwidth      int64
+
+	FreeMemory         int64
+	Power              int64
+	EncoderUtilization int64
+	DecoderUtilization int64
+}
+
+// NewWeights returns the weights set in the defaulted plugin args.
+func NewWeights(args *v1beta1.ScoreWeights) Weights {
+	return Weights{
+		Static:             *args.Static,
+		Dynamic:            *args.Dynamic,
+		Memory:             *args.Memory,
+		Multiprocessor:     *args.Multiprocessor,
+		SharedDecoder:      *args.SharedDecoder,
+		SharedEncoder:      *args.SharedEncoder,
+		Bandwidth:          *args.Bandwidth,
+		FreeMemory:         *args.FreeMemory,
+		Power:              *args.Power,
+		EncoderUtilization: *args.EncoderUtilization,
+		DecoderUtilization: *args.DecoderUtilization,
+	}
+}
+
+// NeutralScore is the score of a node without GPU data, which is neither
+// preferred nor avoided.
+const NeutralScore = 0
+
+// ComputeScore scores the node by the GPU metrics. A node without GPU data
+// gets NeutralScore.
+func ComputeScore(pod *v1.Pod, nodeInfo *framework.NodeInfo, metrics *types.GPUMetricsWithProm, weights Weights) (uint64, error) {
+	nodename := nodeInfo.Node().Name
+	if !metrics.HasGPUData(nodename) {
+		return NeutralScore, nil
+	}
 
-func ComputeScore(pod *v1.Pod, nodeInfo *framework.NodeInfo, metrics *types.GPUMetricsWithProm) (uint64, error) {
 	aggregatedMetrics := aggregateMetrics(metrics)
-	staticScore := computeStaticScore((*metrics)[nodeInfo.Node().Name], aggregatedMetrics)
-	dynamicScore := computeDynamicScore((*metrics)[nodeInfo.Node().Name], aggregatedMetrics)
-	return uint64(staticScore*staticWeight + dynamicScore*dynamicWeight), nil
+	score := float32(0)
+	if weights.Static != 0 {
+		score += computeStaticScore((*metrics)[nodename], aggregatedMetrics, &weights) * float32(weights.Static)
+	}
+	if weights.Dynamic != 0 {
+		score += computeDynamicScore((*metrics)[nodename], aggregatedMetrics, &weights) * float32(weights.Dynamic)
+	}
+	if score < 0 || math.IsNaN(float64(score)) || math.IsInf(float64(score), 0) {
+		return NeutralScore, nil
+	}
+	return uint64(score), nil
+}
+
+// ratio returns part/total, or 0 if total is 0, such as when none of the GPUs
+// in the cluster reports the metric.
+func ratio(part, total float32) float32 {
+	if total == 0 {
+		return 0
+	}
+	return part / total
 }
 
 func aggregateMetrics(metrics *types.GPUMetricsWithProm) *clusterAggregatedMetrics {
 	res := &clusterAggregatedMetrics{}
 	for _, v := range *metrics {
+		if v == nil {
+			continue
+		}
 		for _, gpu := range v.GPUs {
 			res.cardsCount++
 			res.static.memorySize += gpu.StaticAttr.MemorySizeMB
@@ -62,3 +125,15 @@ func aggregateMetrics(metrics *types.GPUMetricsWithProm) *clusterAggregatedMetri
 	}
 	return res
 }
+
+// PenalizeDegraded lowers the score of a node by penalty percent if all of its
+// GPUs are degraded, and in proportion to the degraded GPUs otherwise.
+func PenalizeDegraded(score uint64, degraded, total int, penalty int64) uint64 {
+	if degraded <= 0 || total <= 0 || penalty <= 0 {
+		return score
+	}
+	if degraded > total {
+		degraded = total
+	}
+	return score - score*uint64(penalty)*uint64(degraded)/(100*uint64(total))
+}
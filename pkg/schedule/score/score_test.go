@@ -0,0 +1,134 @@
+package score
+
+import (
+	"github.com/genius/pkg/apis/v1beta1"
+	"github.com/genius/pkg/types"
+	"github.com/observerward/pkg/scraper"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/kubernetes/pkg/scheduler/framework"
+	"testing"
+)
+
+func newGPU(memorySize, freeMemory uint64) *scraper.MetricsSnapshotPerGPU {
+	return &scraper.MetricsSnapshotPerGPU{
+		StaticAttr: scraper.GPUStaticAttr{
+			MemorySizeMB:        memorySize,
+			MultiprocessorCount: 40,
+			SharedDecoderCount:  1,
+			SharedEncoderCount:  1,
+			Bandwidth:           300,
+		},
+		FreeGlobalMemory: freeMemory,
+		UsedGlobalMemory: memorySize - freeMemory,
+		Power:            100,
+	}
+}
+
+func newNodeInfo(name string) *framework.NodeInfo {
+	nodeInfo := framework.NewNodeInfo()
+	nodeInfo.SetNode(&v1.Node{ObjectMeta: metav1.ObjectMeta{Name: name}})
+	return nodeInfo
+}
+
+func TestComputeScoreWeights(t *testing.T) {
+	// node-a has the larger GPU, while node-b has more free memory.
+	metrics := &types.GPUMetricsWithProm{
+		"node-a": {GPUs: []*scraper.MetricsSnapshotPerGPU{newGPU(16000, 2000)}},
+		"node-b": {GPUs: []*scraper.MetricsSnapshotPerGPU{newGPU(8000, 6000)}},
+	}
+
+	defaults := v1beta1.GeniusArgs{}
+	v1beta1.SetDefaultsGeniusArgs(&defaults)
+
+	tests := []struct {
+		name    string
+		weights Weights
+		want    string
+	}{
+		{
+			name:    "memory size only",
+			weights: Weights{Static: 1, Memory: 1},
+			want:    "node-a",
+		},
+		{
+			name:    "free memory only",
+			weights: Weights{Dynamic: 1, FreeMemory: 1},
+			want:    "node-b",
+		},
+		{
+			name:    "default weights",
+			weights: NewWeights(&defaults.ScoreWeights),
+			want:    "node-b",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			scores := map[string]uint64{}
+			for _, node := range []string{"node-a", "node-b"} {
+				sc, err := ComputeScore(&v1.Pod{}, newNodeInfo(node), metrics, test.weights)
+				if err != nil {
+					t.Fatalf("computing score of %v error: %v", node, err)
+				}
+				scores[node] = sc
+			}
+
+			other := "node-a"
+			if test.want == other {
+				other = "node-b"
+			}
+			if scores[test.want] <= scores[other] {
+				t.Errorf("expected %v to score higher than %v, got %v", test.want, other, scores)
+			}
+		})
+	}
+}
+
+func TestComputeScoreWithoutGPUData(t *testing.T) {
+	defaults := v1beta1.GeniusArgs{}
+	v1beta1.SetDefaultsGeniusArgs(&defaults)
+	weights := NewWeights(&defaults.ScoreWeights)
+
+	// None of the GPUs reports the dynamic metrics, so their totals are 0.
+	metrics := &types.GPUMetricsWithProm{
+		"gpu-node":   {GPUs: []*scraper.MetricsSnapshotPerGPU{{StaticAttr: scraper.GPUStaticAttr{MemorySizeMB: 16000}}}},
+		"nil-node":   nil,
+		"empty-node": {},
+	}
+	for _, node := range []string{"missing-node", "nil-node", "empty-node"} {
+		t.Run(node, func(t *testing.T) {
+			sc, err := ComputeScore(&v1.Pod{}, newNodeInfo(node), metrics, weights)
+			if err != nil || sc != NeutralScore {
+				t.Errorf("expected the neutral score, got %v, %v", sc, err)
+			}
+		})
+	}
+
+	sc, err := ComputeScore(&v1.Pod{}, newNodeInfo("gpu-node"), metrics, weights)
+	if err != nil || sc == NeutralScore {
+		t.Errorf("expected the node with GPU data to be scored by its memory size, got %v, %v", sc, err)
+	}
+}
+
+func TestPenalizeDegraded(t *testing.T) {
+	tests := []struct {
+		name             string
+		degraded, total  int
+		penalty          int64
+		score, wantScore uint64
+	}{
+		{"no degraded gpu", 0, 4, 50, 1000, 1000},
+		{"all degraded", 4, 4, 50, 1000, 500},
+		{"partly degraded", 1, 4, 50, 1000, 875},
+		{"no penalty", 4, 4, 0, 1000, 1000},
+		{"no gpu", 1, 0, 50, 1000, 1000},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := PenalizeDegraded(test.score, test.degraded, test.total, test.penalty); got != test.wantScore {
+				t.Errorf("expected %v, got %v", test.wantScore, got)
+			}
+		})
+	}
+}
@@ -4,17 +4,12 @@ import (
 	"github.com/observerward/pkg/scraper"
 )
 
-const (
-	memoryWeight             = 2
-	multiprocessorWeight     = 2
-	sharedDecoderCountWeight = 1
-	sharedEncoderCountWeight = 1
-	bandwidthWeight          = 2
-)
-
 type staticMetricsOnNode staticMetrics
 
-func computeStaticScore(gpuMetrics *scraper.GPUMetrics, aggregatedMetrics *clusterAggregatedMetrics) float32 {
+func computeStaticScore(gpuMetrics *scraper.GPUMetrics, aggregatedMetrics *clusterAggregatedMetrics, weights *Weights) float32 {
+	if gpuMetrics == nil {
+		return 0
+	}
 	smn := &staticMetricsOnNode{}
 	for _, gpu := range gpuMetrics.GPUs {
 		smn.memorySize += gpu.StaticAttr.MemorySizeMB
@@ -23,27 +18,42 @@ func computeStaticScore(gpuMetrics *scraper.GPUMetrics, aggregatedMetrics *clust
 		smn.sharedDecoderCount += uint64(gpu.StaticAttr.SharedDecoderCount)
 		smn.sharedEncoderCount += uint64(gpu.StaticAttr.SharedEncoderCount)
 	}
-	return scoreAgainstMemory(smn, aggregatedMetrics) + scoreAgainstMultiprocessor(smn, aggregatedMetrics) +
-		scoreAgainstSharedDecoder(smn, aggregatedMetrics) + scoreAgainstSharedEncoder(smn, aggregatedMetrics) +
-		scoreAgainstBandwidth(smn, aggregatedMetrics)
+	return scoreAgainstMemory(smn, aggregatedMetrics, weights) + scoreAgainstMultiprocessor(smn, aggregatedMetrics, weights) +
+		scoreAgainstSharedDecoder(smn, aggregatedMetrics, weights) + scoreAgainstSharedEncoder(smn, aggregatedMetrics, weights) +
+		scoreAgainstBandwidth(smn, aggregatedMetrics, weights)
 }
 
-func scoreAgainstMemory(metricsOnNode *staticMetricsOnNode, aggregatedMetrics *clusterAggregatedMetrics) float32 {
-	return float32(metricsOnNode.memorySize) / float32(aggregatedMetrics.static.memorySize) * float32(aggregatedMetrics.cardsCount) * memoryWeight
+func scoreAgainstMemory(metricsOnNode *staticMetricsOnNode, aggregatedMetrics *clusterAggregatedMetrics, weights *Weights) float32 {
+	if weights.Memory == 0 {
+		return 0
+	}
+	return ratio(float32(metricsOnNode.memorySize), float32(aggregatedMetrics.static.memorySize)) * float32(aggregatedMetrics.cardsCount) * float32(weights.Memory)
 }
 
-func scoreAgainstMultiprocessor(metricsOnNode *staticMetricsOnNode, aggregatedMetrics *clusterAggregatedMetrics) float32 {
-	return float32(metricsOnNode.multiprocessorCount) / float32(aggregatedMetrics.static.multiprocessorCount) * float32(aggregatedMetrics.cardsCount) * multiprocessorWeight
+func scoreAgainstMultiprocessor(metricsOnNode *staticMetricsOnNode, aggregatedMetrics *clusterAggregatedMetrics, weights *Weights) float32 {
+	if weights.Multiprocessor == 0 {
+		return 0
+	}
+	return ratio(float32(metricsOnNode.multiprocessorCount), float32(aggregatedMetrics.static.multiprocessorCount)) * float32(aggregatedMetrics.cardsCount) * float32(weights.Multiprocessor)
 }
 
-func scoreAgainstSharedDecoder(metricsOnNode *staticMetricsOnNode, aggregatedMetrics *clusterAggregatedMetrics) float32 {
-	return float32(metricsOnNode.sharedDecoderCount) / float32(aggregatedMetrics.static.sharedDecoderCount) * float32(aggregatedMetrics.cardsCount) * sharedDecoderCountWeight
+func scoreAgainstSharedDecoder(metricsOnNode *staticMetricsOnNode, aggregatedMetrics *clusterAggregatedMetrics, weights *Weights) float32 {
+	if weights.SharedDecoder == 0 {
+		return 0
+	}
+	return ratio(float32(metricsOnNode.sharedDecoderCount), float32(aggregatedMetrics.static.sharedDecoderCount)) * float32(aggregatedMetrics.cardsCount) * float32(weights.SharedDecoder)
 }
 
-func scoreAgainstSharedEncoder(metricsOnNode *staticMetricsOnNode, aggregatedMetrics *clusterAggregatedMetrics) float32 {
-	return float32(metricsOnNode.sharedEncoderCount) / float32(aggregatedMetrics.static.sharedEncoderCount) * float32(aggregatedMetrics.cardsCount) * sharedEncoderCountWeight
+func scoreAgainstSharedEncoder(metricsOnNode *staticMetricsOnNode, aggregatedMetrics *clusterAggregatedMetrics, weights *Weights) float32 {
+	if weights.SharedEncoder == 0 {
+		return 0
+	}
+	return ratio(float32(metricsOnNode.sharedEncoderCount), float32(aggregatedMetrics.static.sharedEncoderCount)) * float32(aggregatedMetrics.cardsCount) * float32(weights.SharedEncoder)
 }
 
-func scoreAgainstBandwidth(metricsOnNode *staticMetricsOnNode, aggregatedMetrics *clusterAggregatedMetrics) float32 {
-	return float32(metricsOnNode.bandwidth) / float32(aggregatedMetrics.static.bandwidth) * float32(aggregatedMetrics.cardsCount) * bandwidthWeight
+func scoreAgainstBandwidth(metricsOnNode *staticMetricsOnNode, aggregatedMetrics *clusterAggregatedMetrics, weights *Weights) float32 {
+	if weights.Bandwidth == 0 {
+		return 0
+	}
+	return ratio(float32(metricsOnNode.bandwidth), float32(aggregatedMetrics.static.bandwidth)) * float32(aggregatedMetrics.cardsCount) * float32(weights.Bandwidth)
 }
@@ -1,16 +1,57 @@
 package sort
 
 import (
+	"github.com/genius/pkg/schedule/gang"
+	v1 "k8s.io/api/core/v1"
 	"k8s.io/kubernetes/pkg/scheduler/framework"
 	"strconv"
+	"time"
 )
 
-func Less(podInfo1, podInfo2 *framework.QueuedPodInfo) bool {
-	return priority(podInfo1) > priority(podInfo2)
+const (
+	// PriorityLabel is the name of the pod label specifying the priority,
+	// prefixed by the label prefix set in the plugin args.
+	PriorityLabel = "priority"
+)
+
+// Less orders the pods by their priority labels. The pods of the same
+// priority are ordered by the time they are first queued, where the pods of a
+// pod group take the time of the group, so that they are kept together. The
+// pods queued at the same time are ordered by the group or the pod names.
+func Less(labelPrefix string, queueTimes *gang.QueueTimes, podInfo1, podInfo2 *framework.QueuedPodInfo) bool {
+	p1, p2 := priority(labelPrefix, podInfo1), priority(labelPrefix, podInfo2)
+	if p1 != p2 {
+		return p1 > p2
+	}
+	t1, key1 := queueTime(labelPrefix, queueTimes, podInfo1)
+	t2, key2 := queueTime(labelPrefix, queueTimes, podInfo2)
+	if !t1.Equal(t2) {
+		return t1.Before(t2)
+	}
+	return key1 < key2
+}
+
+// queueTime returns the time the pod or its group is first queued, along with
+// the namespaced name of the group or the pod. The time of a group is
+// recorded by the first of its pods ordered, and never changes afterwards, so
+// that the order of the pods already queued is kept. A pod with an invalid
+// group is ordered by itself, since it is rejected in the pre-filter phase
+// anyway.
+func queueTime(labelPrefix string, queueTimes *gang.QueueTimes, podInfo *framework.QueuedPodInfo) (time.Time, string) {
+	if group, err := gang.PodGroupOf(labelPrefix, podInfo.Pod); err == nil && group != nil {
+		return queueTimes.Record(group.Key(), podInfo.InitialAttemptTimestamp), group.Key()
+	}
+	return podInfo.InitialAttemptTimestamp, podInfo.Pod.Namespace + "/" + podInfo.Pod.Name
+}
+
+func priority(labelPrefix string, podInfo *framework.QueuedPodInfo) int {
+	return Priority(labelPrefix, podInfo.Pod)
 }
 
-func priority(podInfo *framework.QueuedPodInfo) int {
-	if p, ok := podInfo.Pod.Labels["genius/priority"]; ok {
+// Priority returns the priority of the pod in its priority label, or 0 if
+// the label is not set.
+func Priority(labelPrefix string, pod *v1.Pod) int {
+	if p, ok := pod.Labels[labelPrefix+PriorityLabel]; ok {
 		pInt, _ := strconv.Atoi(p)
 		return pInt
 	}
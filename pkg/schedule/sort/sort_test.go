@@ -0,0 +1,53 @@
+package sort
+
+import (
+	"github.com/genius/pkg/schedule/gang"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/kubernetes/pkg/scheduler/framework"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestLess(t *testing.T) {
+	start := time.Now()
+	newPodInfo := func(name string, labels map[string]string, queued time.Duration) *framework.QueuedPodInfo {
+		return &framework.QueuedPodInfo{
+			Pod:                     &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", Labels: labels}},
+			InitialAttemptTimestamp: start.Add(queued),
+		}
+	}
+	job := map[string]string{"genius/pod-group": "job", "genius/min-available": "2"}
+
+	podInfos := []*framework.QueuedPodInfo{
+		newPodInfo("worker-1", job, 3*time.Second),
+		newPodInfo("late", nil, 4*time.Second),
+		newPodInfo("early", nil, time.Second),
+		newPodInfo("worker-0", job, 0),
+		newPodInfo("urgent", map[string]string{"genius/priority": "10"}, 5*time.Second),
+		newPodInfo("between", nil, 2*time.Second),
+	}
+	// The first worker was ordered as it was queued, before the rest.
+	queueTimes := gang.NewQueueTimes()
+	queueTimes.Record("default/job", start)
+	sort.SliceStable(podInfos, func(i, j int) bool { return Less("genius/", queueTimes, podInfos[i], podInfos[j]) })
+
+	want := []string{"urgent", "worker-1", "worker-0", "early", "between", "late"}
+	for i, podInfo := range podInfos {
+		if podInfo.Pod.Name != want[i] {
+			var got []string
+			for _, podInfo := range podInfos {
+				got = append(got, podInfo.Pod.Name)
+			}
+			t.Fatalf("expected the order %v, got %v", want, got)
+		}
+	}
+
+	// A worker queued earlier, such as after a backoff, takes the time of the
+	// group, so that the pods already queued keep their order.
+	early, before := newPodInfo("worker-2", job, -time.Second), newPodInfo("before", nil, -time.Second/2)
+	if Less("genius/", queueTimes, early, before) {
+		t.Errorf("expected the worker to take the time of the group rather than its own")
+	}
+}
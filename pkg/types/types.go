@@ -19,11 +19,12 @@ const (
 	GPUMultiprocessorCount
 	GPUSharedDecoderCount
 	GPUSharedEncoderCount
+	GPUTemperature
 )
 
 const (
 	// MetricsTypesCount must match all constant variables of the type MetricType.
-	MetricsTypesCount = 10
+	MetricsTypesCount = 11
 )
 
 // GPUMetricsWithProm
@@ -31,10 +32,91 @@ const (
 // value: metrics of GPUs on this node
 type GPUMetricsWithProm map[string]*scraper.GPUMetrics
 
+// Clone implements framework.StateData. The GPU snapshots are copied by value
+// rather than by scraper.GPUMetrics.Clone, which drops some of the fields
+// such as the free memory and the model.
 func (g *GPUMetricsWithProm) Clone() framework.StateData {
-	res := make(GPUMetricsWithProm)
+	res := make(GPUMetricsWithProm, len(*g))
 	for k, v := range *g {
-		res[k] = v.Clone()
+		res[k] = CloneGPUMetrics(v)
 	}
 	return &res
 }
+
+// CloneGPUMetrics returns a copy of the GPU metrics of a node, or nil if
+// gpuMetrics is nil.
+func CloneGPUMetrics(gpuMetrics *scraper.GPUMetrics) *scraper.GPUMetrics {
+	if gpuMetrics == nil {
+		return nil
+	}
+	res := &scraper.GPUMetrics{GPUs: make([]*scraper.MetricsSnapshotPerGPU, 0, len(gpuMetrics.GPUs))}
+	for _, gpu := range gpuMetrics.GPUs {
+		c := *gpu
+		res.GPUs = append(res.GPUs, &c)
+	}
+	return res
+}
+
+// GPUs returns the GPUs on the node, or nil if there is no GPU data of the
+// node, which is the case if its exporter is not running or not reporting.
+// It is safe to call on nil metrics.
+func (g *GPUMetricsWithProm) GPUs(nodename string) []*scraper.MetricsSnapshotPerGPU {
+	if g == nil {
+		return nil
+	}
+	if gpuMetrics := (*g)[nodename]; gpuMetrics != nil {
+		return gpuMetrics.GPUs
+	}
+	return nil
+}
+
+// HasGPUData judges whether there is data of at least one GPU on the node.
+func (g *GPUMetricsWithProm) HasGPUData(nodename string) bool {
+	return len(g.GPUs(nodename)) > 0
+}
+
+// The labels of the GPU samples which the GPU snapshots have no field for.
+// They are attached by the exporters or by the relabeling of Prometheus.
+const (
+	// ComputeCapabilityLabel is the CUDA compute capability, such as "8.0".
+	ComputeCapabilityLabel = "compute_capability"
+	// ArchitectureLabel is the architecture family, such as "ampere".
+	ArchitectureLabel = "architecture"
+	// DriverVersionLabel is the version of the NVIDIA driver, such as "535.104.05".
+	DriverVersionLabel = "driver_version"
+	// CUDAVersionLabel is the highest CUDA version the driver supports, such as "12.2".
+	CUDAVersionLabel = "cuda_version"
+)
+
+var (
+	// GPULabelNames are the labels kept in GPULabels.
+	GPULabelNames = []string{ComputeCapabilityLabel, ArchitectureLabel, DriverVersionLabel, CUDAVersionLabel}
+)
+
+// GPULabels holds the labels of the GPUs, which are read along with the
+// GPUMetricsWithProm.
+// key: nodename, then GPU id
+// value: labels of the GPU
+type GPULabels map[string]map[uint]map[string]string
+
+// Set sets the label of the GPU.
+func (l GPULabels) Set(nodename string, id uint, name, value string) {
+	if _, ok := l[nodename]; !ok {
+		l[nodename] = make(map[uint]map[string]string)
+	}
+	if _, ok := l[nodename][id]; !ok {
+		l[nodename][id] = make(map[string]string)
+	}
+	l[nodename][id][name] = value
+}
+
+// Get returns the label of the GPU, or "" if it is not set.
+func (l GPULabels) Get(nodename string, id uint, name string) string {
+	return l[nodename][id][name]
+}
+
+// Clone implements framework.StateData. The labels are never modified once
+// read, so they are shared rather than copied.
+func (l GPULabels) Clone() framework.StateData {
+	return l
+}
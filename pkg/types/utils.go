@@ -1,20 +1,6 @@
 package types
 
-import (
-	"k8s.io/klog/v2"
-	"regexp"
-	"strconv"
-	"strings"
-)
-
-var (
-	valueRegex      = regexp.MustCompile(`.+=>\s(\d+.?\d*).*`)
-	nodeNameRegex   = regexp.MustCompile(`kubernetes_node="([^"]*)"`)
-	metricTypeRegex = regexp.MustCompile(`observerward_(\w+)\{`)
-	uuidRegex       = regexp.MustCompile(`uuid="([^"]+)"`)
-	idRegex         = regexp.MustCompile(`gpu="(\d*)"`)
-	modelRegex      = regexp.MustCompile(`model="([^"]+)"`)
-)
+import "strings"
 
 const (
 	decoderUtilizationStr  = "dynamic_gpu_decoder_utilization"
@@ -29,6 +15,11 @@ const (
 	sharedEncoderCountStr  = "static_gpu_shared_encoder_count"
 )
 
+const (
+	// ObserverWardMetricPrefix is the prefix of all the metric names exported by ObserverWard.
+	ObserverWardMetricPrefix = "observerward_"
+)
+
 var (
 	metricTypeMap = map[string]MetricType{
 		decoderUtilizationStr:  GPUDecoderUtilization,
@@ -44,59 +35,13 @@ var (
 	}
 )
 
-func ExtractMetricTypeFromProm(val string) MetricType {
-	match := metricTypeRegex.FindStringSubmatch(val)
-	if len(match) != 2 {
-		klog.Errorf("Extracting Metric Type From Prometheus Query Result Error")
-		return -1
-	}
-	return metricTypeMap[match[1]]
-}
-
-func ExtractValueFromProm(val string) uint64 {
-	match := valueRegex.FindStringSubmatch(val)
-	if len(match) != 2 {
-		klog.Errorf("Extracting Value From Prometheus Query Result Error")
-		return 0
-	}
-	strVal := strings.Trim(match[1], " ")
-	intVal, _ := strconv.ParseUint(strVal, 10, 64)
-	return intVal
-}
-
-func ExtractNodeNameFromProm(val string) string {
-	match := nodeNameRegex.FindStringSubmatch(val)
-	if len(match) != 2 {
-		klog.Errorf("Extracting NodeName From Prometheus Query Result Error")
-		return ""
-	}
-	return match[1]
-}
-
-func ExtractUUIDFromProm(val string) string {
-	match := uuidRegex.FindStringSubmatch(val)
-	if len(match) != 2 {
-		klog.Errorf("extracting uuid from prometheus query result error")
-		return ""
-	}
-	return match[1]
-}
-
-func ExtractIDFromProm(val string) uint {
-	match := idRegex.FindStringSubmatch(val)
-	if len(match) != 2 {
-		klog.Errorf("extracting nodename from prometheus query error")
-		return 0
-	}
-	res, _ := strconv.Atoi(match[1])
-	return uint(res)
-}
-
-func ExtractModelFromProm(val string) string {
-	match := modelRegex.FindStringSubmatch(val)
-	if len(match) != 2 {
-		klog.Errorf("extracting model from prometheus query error")
-		return ""
+// MetricTypeFromName returns the metric type of an ObserverWard metric name,
+// such as "observerward_dynamic_gpu_power_usage_W". The second return value
+// is false if the name is not a known ObserverWard metric.
+func MetricTypeFromName(name string) (MetricType, bool) {
+	if !strings.HasPrefix(name, ObserverWardMetricPrefix) {
+		return 0, false
 	}
-	return match[1]
+	t, ok := metricTypeMap[strings.TrimPrefix(name, ObserverWardMetricPrefix)]
+	return t, ok
 }
@@ -4,32 +4,25 @@ import (
 	"testing"
 )
 
-func TestExtractValueFromProm(t *testing.T) {
-	raw := "observerward_gpu_decoder_utilization{UUID=\"GPU-8763e6c0-e8b9-ac77-91ba-407ee16f5493\", gpu=\"1\", hostname=\"observerward-4k68z\", instance=\"192.168.205.76:9909\", job=\"gpu-metrics\", kubernetes_node=\"linww-poweredge-t630\"} => 123 @[1620363129.316]"
-	println(ExtractValueFromProm(raw))
-}
-
-func TestExtractNodeNameFromProm(t *testing.T) {
-	raw := "observerward_gpu_decoder_utilization{UUID=\"GPU-8763e6c0-e8b9-ac77-91ba-407ee16f5493\", gpu=\"1\", hostname=\"observerward-4k68z\", instance=\"192.168.205.76:9909\", job=\"gpu-metrics\", kubernetes_node=\"linww-poweredge-t630\"} => 123 @[1620363129.316]"
-	println(ExtractNodeNameFromProm(raw))
-}
-
-func TestExtractIDFromProm(t *testing.T) {
-	raw := `observerward_gpu_used_global_memory_MiB{UUID="GPU-8763e6c0-e8b9-ac77-91ba-407ee16f5493", gpu="1", hostname="observerward-4k68z", instance="192.168.205.76:9909", job="gpu-metrics", kubernetes_node="linww-poweredge-t630"} => 3740 @[1620548893.055]`
-	println(ExtractIDFromProm(raw))
-}
-
-func TestExtractUUIDFromProm(t *testing.T) {
-	raw := `observerward_gpu_used_global_memory_MiB{UUID="GPU-8763e6c0-e8b9-ac77-91ba-407ee16f5493", gpu="1", hostname="observerward-4k68z", instance="192.168.205.76:9909", job="gpu-metrics", kubernetes_node="linww-poweredge-t630"} => 3740 @[1620548893.055]`
-	println(ExtractUUIDFromProm(raw))
-}
-
-func TestExtractMetricTypeFromProm(t *testing.T) {
-	raw := `observerward_gpu_power_usage_W{UUID="GPU-8763e6c0-e8b9-ac77-91ba-407ee16f5493", gpu="1", hostname="observerward-4k68z", instance="192.168.205.76:9909", job="gpu-metrics", kubernetes_node="linww-poweredge-t630"} => 3740 @[1620548893.055]`
-	println(ExtractMetricTypeFromProm(raw))
-}
+func TestMetricTypeFromName(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected MetricType
+		ok       bool
+	}{
+		{"observerward_dynamic_gpu_decoder_utilization", GPUDecoderUtilization, true},
+		{"observerward_dynamic_gpu_power_usage_W", GPUPowerUsage, true},
+		{"observerward_static_gpu_shared_encoder_count", GPUSharedEncoderCount, true},
+		{"dynamic_gpu_power_usage_W", 0, false},
+		{"observerward_dynamic_gpu_temperature", 0, false},
+	}
 
-func TestExtractModelFromProm(t *testing.T) {
-	raw := `observerward_dynamic_gpu_decoder_utilization{id="0", instance="192.168.205.114:9909", job="gpu-metrics", kubernetes_node="linww-poweredge-t630", model="GeForce GTX 1080 Ti", uuid="GPU-49764fc0-5afa-9237-a573-d226351369f9"} => 0 @[1621255638.419]`
-	println(ExtractModelFromProm(raw))
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			typ, ok := MetricTypeFromName(test.name)
+			if typ != test.expected || ok != test.ok {
+				t.Errorf("expected (%v, %v), got (%v, %v)", test.expected, test.ok, typ, ok)
+			}
+		})
+	}
 }